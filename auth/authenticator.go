@@ -0,0 +1,251 @@
+// Package auth authenticates the callers of the API with static API keys or HS256/RS256 JWT bearer tokens, verified with local keys
+package auth
+
+import (
+	"example/library_project/config"
+	"example/library_project/utils"
+
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// APIKeyHeader is the header carrying an API key
+const APIKeyHeader = "X-API-Key"
+
+// minHS256KeyLength is the minimum length of an HS256 key, which RFC 7518 requires to be at least as long as the hash
+const minHS256KeyLength = 32
+
+// clockSkew is how far the clocks of the token issuer and of the API may disagree when checking exp, nbf and iat
+const clockSkew = 30 * time.Second
+
+// ErrMissingCredentials is returned when a request carries neither an API key nor a bearer token
+var ErrMissingCredentials = errors.New("missing credentials")
+
+// ErrInvalidCredentials is returned when the API key or bearer token of a request is not valid
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator checks the credentials of requests
+type Authenticator struct {
+	// apiKeys maps the SHA-256 of each API key to the principal it authenticates as, so that the keys themselves are not kept in memory
+	apiKeys map[[sha256.Size]byte]Principal
+
+	hs256Key []byte
+	rs256Key *rsa.PublicKey
+	issuer string
+	audience string
+
+	// DateTimeInterface is the clock against which token expiry is checked
+	DateTimeInterface utils.DateTimeProvider
+}
+
+// NewAuthenticator accepts the given API keys, and the tokens signed with hs256Key or rs256Key, either of which may be nil. Empty issuer and audience are not checked
+func NewAuthenticator(apiKeys []config.APIKeyConfig, hs256Key []byte, rs256Key *rsa.PublicKey, issuer string, audience string) *Authenticator {
+	hashedAPIKeys := make(map[[sha256.Size]byte]Principal, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		hashedAPIKeys[sha256.Sum256([]byte(apiKey.Key))] = Principal{
+			Subject: apiKey.Name,
+			Method: MethodAPIKey,
+			Role: apiKey.Role,
+			CustomerID: apiKey.CustomerID,
+		}
+	}
+
+	return &Authenticator{
+		apiKeys: hashedAPIKeys,
+		hs256Key: hs256Key,
+		rs256Key: rs256Key,
+		issuer: issuer,
+		audience: audience,
+		DateTimeInterface: &utils.ProductionDateTimeProvider{},
+	}
+}
+
+// Load reads the API keys file and the JWT keys named by the validated configuration
+func Load(authConfig config.AuthConfig) (*Authenticator, error) {
+	apiKeys := append([]config.APIKeyConfig{}, authConfig.APIKeys...)
+	if authConfig.APIKeysFile != "" {
+		fileAPIKeys, err := loadAPIKeysFile(authConfig.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, fileAPIKeys...)
+	}
+
+	names := make(map[string]bool, len(apiKeys))
+	keys := make(map[string]bool, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		if names[apiKey.Name] {
+			return nil, fmt.Errorf("API key name %q is used by more than one key", apiKey.Name)
+		}
+		if keys[apiKey.Key] {
+			return nil, fmt.Errorf("API key %q has the same key as another one", apiKey.Name)
+		}
+		names[apiKey.Name] = true
+		keys[apiKey.Key] = true
+	}
+
+	var hs256Key []byte
+	if authConfig.JWT.HS256KeyFile != "" {
+		contents, err := os.ReadFile(authConfig.JWT.HS256KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the HS256 key: %w", err)
+		}
+
+		// Editors and secret managers often leave a trailing newline, which is not part of the key
+		hs256Key = bytes.TrimSpace(contents)
+		if len(hs256Key) < minHS256KeyLength {
+			return nil, fmt.Errorf("the HS256 key in %s must be at least %d bytes long", authConfig.JWT.HS256KeyFile, minHS256KeyLength)
+		}
+	}
+
+	var rs256Key *rsa.PublicKey
+	if authConfig.JWT.RS256PublicKeyFile != "" {
+		contents, err := os.ReadFile(authConfig.JWT.RS256PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the RS256 public key: %w", err)
+		}
+
+		rs256Key, err = jwt.ParseRSAPublicKeyFromPEM(contents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the RS256 public key in %s: %w", authConfig.JWT.RS256PublicKeyFile, err)
+		}
+	}
+
+	return NewAuthenticator(apiKeys, hs256Key, rs256Key, authConfig.JWT.Issuer, authConfig.JWT.Audience), nil
+}
+
+// loadAPIKeysFile reads a YAML list of API keys, each with a name and a key
+func loadAPIKeysFile(path string) ([]config.APIKeyConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the API keys file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	var apiKeys []config.APIKeyConfig
+	if err := decoder.Decode(&apiKeys); err != nil {
+		return nil, fmt.Errorf("failed to parse the API keys file %s: %w", path, err)
+	}
+
+	for i, apiKey := range apiKeys {
+		if apiKey.Name == "" {
+			return nil, fmt.Errorf("API key %d in %s has no name", i, path)
+		}
+		if len(apiKey.Key) < config.MinAPIKeyLength {
+			return nil, fmt.Errorf("API key %q in %s must be at least %d characters long", apiKey.Name, path, config.MinAPIKeyLength)
+		}
+		if !validRole(apiKey.Role) {
+			return nil, fmt.Errorf("API key %q in %s must have a role of %s, got %q", apiKey.Name, path, strings.Join(config.Roles, ", "), apiKey.Role)
+		}
+		if apiKey.Role == RolePatron && apiKey.CustomerID == "" {
+			return nil, fmt.Errorf("API key %q in %s is for a patron, and needs a customer_id", apiKey.Name, path)
+		}
+	}
+
+	return apiKeys, nil
+}
+
+// Authenticate returns the caller of r, identified by the X-API-Key header or else by a bearer token in the Authorization header.
+// It returns an error wrapping ErrMissingCredentials or ErrInvalidCredentials if the caller cannot be identified
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+		return a.authenticateAPIKey(apiKey)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, fmt.Errorf("%w: expected an Authorization header of the form \"Bearer <token>\"", ErrInvalidCredentials)
+	}
+
+	return a.authenticateToken(strings.TrimSpace(token))
+}
+
+// authenticateAPIKey looks up the name of apiKey
+func (a *Authenticator) authenticateAPIKey(apiKey string) (*Principal, error) {
+	principal, ok := a.apiKeys[sha256.Sum256([]byte(apiKey))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+
+	return &principal, nil
+}
+
+// tokenClaims are the claims read from bearer tokens. Role is one of the roles, and CustomerID is required for patrons
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+	CustomerID string `json:"customer_id"`
+}
+
+// authenticateToken verifies the signature and the claims of a JWT. Only the algorithms with a configured key are accepted, so that an RS256 public key can never be used as an HS256 secret
+func (a *Authenticator) authenticateToken(token string) (*Principal, error) {
+	var validMethods []string
+	if a.hs256Key != nil {
+		validMethods = append(validMethods, jwt.SigningMethodHS256.Alg())
+	}
+	if a.rs256Key != nil {
+		validMethods = append(validMethods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(validMethods) == 0 {
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(validMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(func() time.Time { return *a.DateTimeInterface.GetCurrentTime() }),
+	}
+	if a.issuer != "" {
+		options = append(options, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		options = append(options, jwt.WithAudience(a.audience))
+	}
+
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method {
+		case jwt.SigningMethodHS256:
+			return a.hs256Key, nil
+		case jwt.SigningMethodRS256:
+			return a.rs256Key, nil
+		default:
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no sub claim", ErrInvalidCredentials)
+	}
+	if !validRole(claims.Role) {
+		return nil, fmt.Errorf("%w: token has no valid role claim", ErrInvalidCredentials)
+	}
+	if claims.Role == RolePatron && claims.CustomerID == "" {
+		return nil, fmt.Errorf("%w: patron token has no customer_id claim", ErrInvalidCredentials)
+	}
+
+	return &Principal{Subject: claims.Subject, Method: MethodJWT, Role: claims.Role, CustomerID: claims.CustomerID}, nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"example/library_project/logging"
+	"example/library_project/models"
+
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware rejects requests without valid credentials with 401, and stores the caller of the others in the request's context for PrincipalFromContext
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := a.Authenticate(c.Request)
+		if err != nil {
+			slog.InfoContext(c.Request.Context(), "authentication failed", slog.String("error", err.Error()))
+
+			message := "Invalid credentials."
+			challenge := `Bearer realm="library", error="invalid_token"`
+			if errors.Is(err, ErrMissingCredentials) {
+				message = "Missing credentials: send an API key in the X-API-Key header or a bearer token in the Authorization header."
+				challenge = `Bearer realm="library"`
+			}
+
+			errorResponse := models.ErrorResponse{
+				Message: &message,
+				RequestID: nil,
+			}
+			if requestID := logging.RequestID(c.Request.Context()); requestID != "" {
+				errorResponse.RequestID = &requestID
+			}
+
+			c.Header("WWW-Authenticate", challenge)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse)
+			return
+		}
+
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(semconv.EnduserID(principal.Subject), semconv.EnduserRole(principal.Role))
+
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), principal))
+
+		c.Next()
+	}
+}
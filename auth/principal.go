@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+)
+
+// The ways a caller can authenticate
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT = "jwt"
+)
+
+// The roles of callers, from the least to the most privileged. Each role may do everything the previous ones may
+const (
+	// RolePatron may read books, and check out, hold, return and release books for its own customer ID only
+	RolePatron = "patron"
+	// RoleLibrarian may also act for any customer
+	RoleLibrarian = "librarian"
+	// RoleAdmin may also create and delete books
+	RoleAdmin = "admin"
+)
+
+// roleRanks orders the roles by privilege
+var roleRanks = map[string]int{
+	RolePatron: 1,
+	RoleLibrarian: 2,
+	RoleAdmin: 3,
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject identifies the caller: the name of its API key, or the sub claim of its token
+	Subject string
+
+	// Method is how the caller authenticated, MethodAPIKey or MethodJWT
+	Method string
+
+	// Role is one of RolePatron, RoleLibrarian or RoleAdmin
+	Role string
+
+	// CustomerID is the customer a patron acts for. It may be empty for the other roles
+	CustomerID string
+}
+
+// HasRole reports whether the principal has role, or a more privileged one
+func (p *Principal) HasRole(role string) bool {
+	rank, ok := roleRanks[p.Role]
+	return ok && rank >= roleRanks[role]
+}
+
+// CanActFor reports whether the principal may check out, hold, return or release books on behalf of customerID
+func (p *Principal) CanActFor(customerID string) bool {
+	if p.HasRole(RoleLibrarian) {
+		return true
+	}
+
+	return p.HasRole(RolePatron) && p.CustomerID != "" && p.CustomerID == customerID
+}
+
+// validRole reports whether role is one of the known roles
+func validRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// principalContextKey is the key of the principal in a context
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by the middleware, and false if the request was not authenticated
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
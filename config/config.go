@@ -0,0 +1,924 @@
+// Package config loads the settings of the library API from an optional YAML file, then from environment variables, which take precedence.
+// The result is validated up front, so that the server refuses to start with a precise message instead of failing later
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable holding the path of the YAML configuration file. Without it, only defaults and environment variables are used
+const ConfigFileEnv = "LIBRARY_CONFIG_FILE"
+
+// Drivers lists the valid values of database.driver, one per storage solution
+var Drivers = []string{"inmemory", "mysql", "postgres", "sqlite"}
+
+// LogLevels lists the valid values of log.level, from the most to the least verbose
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// TracingExporters lists the valid values of tracing.exporter
+var TracingExporters = []string{"none", "stdout", "otlp"}
+
+// Roles lists the valid roles of API keys, from the least to the most privileged
+var Roles = []string{"patron", "librarian", "admin"}
+
+// MinAPIKeyLength is the length below which an API key is considered too easy to guess
+const MinAPIKeyLength = 16
+
+// redacted replaces secrets when the configuration is printed
+const redacted = "REDACTED"
+
+// Config holds every setting of the library API
+type Config struct {
+	Server ServerConfig `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Log LogConfig `yaml:"log"`
+	Tracing TracingConfig `yaml:"tracing"`
+	Auth AuthConfig `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Loans LoansConfig `yaml:"loans"`
+	Fines FinesConfig `yaml:"fines"`
+
+	// TestMode "integration" fills the library with the integration test data on startup
+	TestMode string `yaml:"test_mode"`
+}
+
+// ServerConfig holds the settings of the HTTP server
+type ServerConfig struct {
+	// Host is the interface to listen on. An empty host listens on every interface, which is needed to be reachable from outside a container
+	Host string `yaml:"host"`
+	Port int `yaml:"port"`
+
+	// ReadTimeout bounds reading a whole request, WriteTimeout bounds writing the response, and IdleTimeout bounds waiting for the next request on a keep-alive connection. Zero means no limit
+	ReadTimeout time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+
+	// MaxHeaderBytes bounds the size of the request headers, and MaxBodyBytes the size of request bodies, which are rejected with 413 beyond it
+	MaxHeaderBytes int `yaml:"max_header_bytes"`
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+
+	// TrustedProxies lists the addresses or CIDR ranges of the proxies whose X-Forwarded-For header gives the client IP. By default the client IP is the address of the connection
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// TLSCertFile and TLSKeyFile are PEM files. Setting both serves HTTPS instead of HTTP
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile string `yaml:"tls_key_file"`
+
+	// ShutdownTimeout is how long in-flight requests get to finish on SIGTERM or SIGINT
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// RateLimitConfig holds the token buckets that throttle each client of the /books and /customers routes, identified by its API key or token subject, or else by its IP, and the failed authentications of each IP
+type RateLimitConfig struct {
+	// Enabled rejects the requests of a client whose bucket is empty with 429
+	Enabled bool `yaml:"enabled"`
+
+	// Read applies to the GET requests, and Write to the POST, PATCH and DELETE requests. Each client has a bucket of each kind
+	Read RateConfig `yaml:"read"`
+	Write RateConfig `yaml:"write"`
+
+	// AuthFailures applies to the requests answered with 401 when authentication is enabled. Each client IP has a bucket, checked before its credentials
+	AuthFailures RateConfig `yaml:"auth_failures"`
+}
+
+// RateConfig is a token bucket
+type RateConfig struct {
+	// RequestsPerSecond is the rate at which the bucket refills, and Burst its size, which is how many requests a client may make at once
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst int `yaml:"burst"`
+}
+
+// LoansConfig holds the lending policy applied when books are checked out or placed on-hold
+type LoansConfig struct {
+	// Period is how long after checkout a book is due back, after which it is reported as overdue. Each renewal extends the due date by another period
+	Period time.Duration `yaml:"period"`
+
+	// MaxRenewals is how many times a loan may be renewed. Zero disables renewals
+	MaxRenewals int `yaml:"max_renewals"`
+
+	// HoldPickupWindow is how long an on-hold book waits to be checked out before its hold expires
+	HoldPickupWindow time.Duration `yaml:"hold_pickup_window"`
+
+	// HoldSweepInterval is how often expired holds are looked for and released
+	HoldSweepInterval time.Duration `yaml:"hold_sweep_interval"`
+}
+
+// FinesConfig holds the fines charged when books are returned after their due date. Amounts are in the smallest unit of the currency, such as cents
+type FinesConfig struct {
+	// DailyRate is charged for every day, or part of a day, a book is returned late. Zero disables fines
+	DailyRate int `yaml:"daily_rate"`
+
+	// Cap bounds the fine charged for a single late return. Zero means no cap
+	Cap int `yaml:"cap"`
+
+	// CheckoutThreshold refuses checkouts to customers whose outstanding fines add up to at least this balance. Zero never refuses checkouts
+	CheckoutThreshold int `yaml:"checkout_threshold"`
+}
+
+// LogConfig holds the settings of the JSON logs written to standard output
+type LogConfig struct {
+	// Level is the least severe level that is logged, one of LogLevels
+	Level string `yaml:"level"`
+}
+
+// TracingConfig holds the settings of the OpenTelemetry traces
+type TracingConfig struct {
+	// Exporter is "none" to disable tracing, "stdout" to write spans as JSON lines, or "otlp" to send them to an OTLP/HTTP collector
+	Exporter string `yaml:"exporter"`
+
+	// OTLPEndpoint is the host and port of the collector. Empty uses OTEL_EXPORTER_OTLP_ENDPOINT, or localhost:4318
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	// OTLPInsecure sends spans over HTTP instead of HTTPS
+	OTLPInsecure bool `yaml:"otlp_insecure"`
+
+	// ServiceName identifies the API in the traces
+	ServiceName string `yaml:"service_name"`
+
+	// SampleRatio is the fraction of new traces that are recorded. Requests belonging to a trace started upstream follow the upstream decision
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// AuthConfig holds the settings of the authentication of the /books routes
+type AuthConfig struct {
+	// Enabled requires every request to /books to present a valid API key or JWT. At least one kind of credential must be configured.
+	// It is true by default, so that the API is only left open when a deployment opts out explicitly
+	Enabled bool `yaml:"enabled"`
+
+	// APIKeys are accepted in the X-API-Key header. APIKeysFile names a YAML file holding more of them in the same format, so that keys can be kept out of the main file
+	APIKeys []APIKeyConfig `yaml:"api_keys"`
+	APIKeysFile string `yaml:"api_keys_file"`
+
+	JWT JWTConfig `yaml:"jwt"`
+}
+
+// APIKeyConfig is a static API key, and the name and role it authenticates as
+type APIKeyConfig struct {
+	Name string `yaml:"name"`
+	Key string `yaml:"key"`
+
+	// Role is one of Roles. CustomerID is the customer a patron acts for, and is required for patrons only
+	Role string `yaml:"role"`
+	CustomerID string `yaml:"customer_id"`
+}
+
+// JWTConfig holds the keys that verify bearer tokens. Setting either file enables the corresponding algorithm
+type JWTConfig struct {
+	// HS256KeyFile holds the shared secret of HS256 tokens
+	HS256KeyFile string `yaml:"hs256_key_file"`
+	// RS256PublicKeyFile holds the PEM public key of RS256 tokens
+	RS256PublicKeyFile string `yaml:"rs256_public_key_file"`
+
+	// Issuer and Audience, when set, must match the iss and aud claims of every token
+	Issuer string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+// DatabaseConfig holds the settings of the storage solution
+type DatabaseConfig struct {
+	// Driver selects the storage solution, one of Drivers
+	Driver string `yaml:"driver"`
+
+	// Timeout bounds the time a request may spend waiting on the database. Zero means no limit
+	Timeout time.Duration `yaml:"timeout"`
+
+	// DSN is a complete data source name for mysql or postgres. When it is set, the individual connection settings below must be left empty
+	DSN string `yaml:"dsn"`
+
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Host string `yaml:"host"`
+	// Port zero uses the default port of the driver
+	Port int `yaml:"port"`
+	Name string `yaml:"name"`
+
+	// Path is the database file used by the sqlite driver
+	Path string `yaml:"path"`
+
+	// Migrate selects whether the mysql driver applies pending schema migrations on startup ("apply") or refuses to start with pending migrations ("verify")
+	Migrate string `yaml:"migrate"`
+
+	MySQL MySQLConfig `yaml:"mysql"`
+	Pool PoolConfig `yaml:"pool"`
+}
+
+// MySQLConfig holds the connection settings specific to MySQL, which are ignored when a DSN is given
+type MySQLConfig struct {
+	// TLS is "false", "true", "skip-verify" or "preferred"
+	TLS string `yaml:"tls"`
+	Charset string `yaml:"charset"`
+	ParseTime bool `yaml:"parse_time"`
+
+	// Params holds any other connection parameters understood by the MySQL driver
+	Params map[string]string `yaml:"params"`
+}
+
+// PoolConfig holds the connection pool settings of the mysql and postgres drivers. Zero values keep the database/sql defaults
+type PoolConfig struct {
+	MaxOpenConns int `yaml:"max_open_conns"`
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+// Default returns the configuration used for every setting that is neither in the file nor in the environment
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host: "",
+			Port: 8080,
+			ReadTimeout: 15 * time.Second,
+			WriteTimeout: 30 * time.Second,
+			IdleTimeout: 60 * time.Second,
+			MaxHeaderBytes: 1 << 20,
+			MaxBodyBytes: 64 << 10,
+			TrustedProxies: nil,
+			TLSCertFile: "",
+			TLSKeyFile: "",
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver: "",
+			Timeout: 5 * time.Second,
+			DSN: "",
+			Username: "",
+			Password: "",
+			Host: "",
+			Port: 0,
+			Name: "",
+			Path: "library.db",
+			Migrate: "apply",
+			MySQL: MySQLConfig{
+				TLS: "false",
+				Charset: "utf8mb4",
+				ParseTime: false,
+				Params: nil,
+			},
+			Pool: PoolConfig{
+				MaxOpenConns: 0,
+				MaxIdleConns: 0,
+				ConnMaxLifetime: 0,
+				ConnMaxIdleTime: 0,
+			},
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Auth: AuthConfig{
+			Enabled: true,
+			APIKeys: nil,
+			APIKeysFile: "",
+			JWT: JWTConfig{
+				HS256KeyFile: "",
+				RS256PublicKeyFile: "",
+				Issuer: "",
+				Audience: "",
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Read: RateConfig{
+				RequestsPerSecond: 50,
+				Burst: 100,
+			},
+			Write: RateConfig{
+				RequestsPerSecond: 10,
+				Burst: 20,
+			},
+			AuthFailures: RateConfig{
+				RequestsPerSecond: 0.1,
+				Burst: 10,
+			},
+		},
+		Loans: LoansConfig{
+			Period: 14 * 24 * time.Hour,
+			MaxRenewals: 2,
+			HoldPickupWindow: 3 * 24 * time.Hour,
+			HoldSweepInterval: time.Minute,
+		},
+		Fines: FinesConfig{
+			DailyRate: 25,
+			Cap: 1000,
+			CheckoutThreshold: 0,
+		},
+		Tracing: TracingConfig{
+			Exporter: "none",
+			OTLPEndpoint: "",
+			OTLPInsecure: false,
+			ServiceName: "library-api",
+			SampleRatio: 1,
+		},
+		TestMode: "",
+	}
+}
+
+// Load reads the file named by LIBRARY_CONFIG_FILE, if set, applies the environment variable overrides, and validates the result
+func Load() (*Config, error) {
+	return load(os.LookupEnv)
+}
+
+// load is Load with the environment provided by lookupEnv, so that tests do not depend on the real environment
+func load(lookupEnv func(key string) (string, bool)) (*Config, error) {
+	cfg := Default()
+
+	if path, ok := lookupEnv(ConfigFileEnv); ok && path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile overwrites the settings present in the YAML file at path. Unknown keys are rejected, so that a misspelled setting is not silently ignored
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overwrites the settings whose environment variable is set
+func (c *Config) applyEnv(lookupEnv func(key string) (string, bool)) error {
+	var errs []error
+
+	setString := func(key string, target *string) {
+		if value, ok := lookupEnv(key); ok {
+			*target = value
+		}
+	}
+
+	setInt := func(key string, target *int) {
+		if value, ok := lookupEnv(key); ok {
+			parsedValue, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: expected an integer, got %q", key, value))
+				return
+			}
+			*target = parsedValue
+		}
+	}
+
+	setBool := func(key string, target *bool) {
+		if value, ok := lookupEnv(key); ok {
+			parsedValue, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: expected true or false, got %q", key, value))
+				return
+			}
+			*target = parsedValue
+		}
+	}
+
+	setFloat := func(key string, target *float64) {
+		if value, ok := lookupEnv(key); ok {
+			parsedValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: expected a number, got %q", key, value))
+				return
+			}
+			*target = parsedValue
+		}
+	}
+
+	// Lists are comma-separated, and an empty value clears the list
+	setList := func(key string, target *[]string) {
+		if value, ok := lookupEnv(key); ok {
+			*target = nil
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*target = append(*target, item)
+				}
+			}
+		}
+	}
+
+	setDuration := func(key string, target *time.Duration) {
+		if value, ok := lookupEnv(key); ok {
+			parsedValue, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: expected a duration such as 30s or 500ms, got %q", key, value))
+				return
+			}
+			*target = parsedValue
+		}
+	}
+
+	setString("LIBRARY_HOST", &c.Server.Host)
+	setInt("LIBRARY_PORT", &c.Server.Port)
+	setDuration("LIBRARY_READ_TIMEOUT", &c.Server.ReadTimeout)
+	setDuration("LIBRARY_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	setDuration("LIBRARY_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	setInt("LIBRARY_MAX_HEADER_BYTES", &c.Server.MaxHeaderBytes)
+	setInt("LIBRARY_MAX_BODY_BYTES", &c.Server.MaxBodyBytes)
+	setList("LIBRARY_TRUSTED_PROXIES", &c.Server.TrustedProxies)
+	setString("LIBRARY_TLS_CERT_FILE", &c.Server.TLSCertFile)
+	setString("LIBRARY_TLS_KEY_FILE", &c.Server.TLSKeyFile)
+	setDuration("LIBRARY_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	setString("DAO_SELECTION", &c.Database.Driver)
+	setDuration("LIBRARY_DB_TIMEOUT", &c.Database.Timeout)
+	setString("LIBRARY_DB_DSN", &c.Database.DSN)
+	setString("LIBRARY_DB_USERNAME", &c.Database.Username)
+	setString("LIBRARY_DB_PASSWORD", &c.Database.Password)
+	setString("LIBRARY_DB_HOST", &c.Database.Host)
+	setInt("LIBRARY_DB_PORT", &c.Database.Port)
+	setString("LIBRARY_DB_NAME", &c.Database.Name)
+	setString("LIBRARY_DB_PATH", &c.Database.Path)
+	setString("LIBRARY_DB_MIGRATE", &c.Database.Migrate)
+	setString("LIBRARY_DB_TLS", &c.Database.MySQL.TLS)
+	setString("LIBRARY_DB_CHARSET", &c.Database.MySQL.Charset)
+	setBool("LIBRARY_DB_PARSE_TIME", &c.Database.MySQL.ParseTime)
+	setInt("LIBRARY_DB_MAX_OPEN_CONNS", &c.Database.Pool.MaxOpenConns)
+	setInt("LIBRARY_DB_MAX_IDLE_CONNS", &c.Database.Pool.MaxIdleConns)
+	setDuration("LIBRARY_DB_CONN_MAX_LIFETIME", &c.Database.Pool.ConnMaxLifetime)
+	setDuration("LIBRARY_DB_CONN_MAX_IDLE_TIME", &c.Database.Pool.ConnMaxIdleTime)
+
+	setString("LIBRARY_LOG_LEVEL", &c.Log.Level)
+
+	setString("LIBRARY_TRACING_EXPORTER", &c.Tracing.Exporter)
+	setString("LIBRARY_OTLP_ENDPOINT", &c.Tracing.OTLPEndpoint)
+	setBool("LIBRARY_OTLP_INSECURE", &c.Tracing.OTLPInsecure)
+	setString("LIBRARY_TRACING_SERVICE_NAME", &c.Tracing.ServiceName)
+	setFloat("LIBRARY_TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+
+	setBool("LIBRARY_AUTH_ENABLED", &c.Auth.Enabled)
+	setString("LIBRARY_API_KEYS_FILE", &c.Auth.APIKeysFile)
+	setString("LIBRARY_JWT_HS256_KEY_FILE", &c.Auth.JWT.HS256KeyFile)
+	setString("LIBRARY_JWT_RS256_PUBLIC_KEY_FILE", &c.Auth.JWT.RS256PublicKeyFile)
+	setString("LIBRARY_JWT_ISSUER", &c.Auth.JWT.Issuer)
+	setString("LIBRARY_JWT_AUDIENCE", &c.Auth.JWT.Audience)
+
+	setBool("LIBRARY_RATE_LIMIT_ENABLED", &c.RateLimit.Enabled)
+	setFloat("LIBRARY_RATE_LIMIT_READ_RPS", &c.RateLimit.Read.RequestsPerSecond)
+	setInt("LIBRARY_RATE_LIMIT_READ_BURST", &c.RateLimit.Read.Burst)
+	setFloat("LIBRARY_RATE_LIMIT_WRITE_RPS", &c.RateLimit.Write.RequestsPerSecond)
+	setInt("LIBRARY_RATE_LIMIT_WRITE_BURST", &c.RateLimit.Write.Burst)
+	setFloat("LIBRARY_RATE_LIMIT_AUTH_FAILURES_RPS", &c.RateLimit.AuthFailures.RequestsPerSecond)
+	setInt("LIBRARY_RATE_LIMIT_AUTH_FAILURES_BURST", &c.RateLimit.AuthFailures.Burst)
+
+	setDuration("LIBRARY_LOAN_PERIOD", &c.Loans.Period)
+	setInt("LIBRARY_MAX_RENEWALS", &c.Loans.MaxRenewals)
+	setDuration("LIBRARY_HOLD_PICKUP_WINDOW", &c.Loans.HoldPickupWindow)
+	setDuration("LIBRARY_HOLD_SWEEP_INTERVAL", &c.Loans.HoldSweepInterval)
+	setInt("LIBRARY_FINE_DAILY_RATE", &c.Fines.DailyRate)
+	setInt("LIBRARY_FINE_CAP", &c.Fines.Cap)
+	setInt("LIBRARY_FINE_CHECKOUT_THRESHOLD", &c.Fines.CheckoutThreshold)
+
+	setString("TEST_MODE", &c.TestMode)
+
+	return joinErrors(errs)
+}
+
+// Validate reports every invalid setting at once, naming each one as it appears in the config file
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port: must be between 1 and 65535, got %d", c.Server.Port))
+	}
+
+	if c.Server.ReadTimeout < 0 {
+		errs = append(errs, fmt.Errorf("server.read_timeout: must not be negative, got %s", c.Server.ReadTimeout))
+	}
+
+	if c.Server.WriteTimeout < 0 {
+		errs = append(errs, fmt.Errorf("server.write_timeout: must not be negative, got %s", c.Server.WriteTimeout))
+	}
+
+	if c.Server.IdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("server.idle_timeout: must not be negative, got %s", c.Server.IdleTimeout))
+	}
+
+	if c.Server.MaxHeaderBytes < 1 {
+		errs = append(errs, fmt.Errorf("server.max_header_bytes: must be positive, got %d", c.Server.MaxHeaderBytes))
+	}
+
+	if c.Server.MaxBodyBytes < 1 {
+		errs = append(errs, fmt.Errorf("server.max_body_bytes (LIBRARY_MAX_BODY_BYTES): must be positive, got %d", c.Server.MaxBodyBytes))
+	}
+
+	for i, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("server.trusted_proxies[%d] (LIBRARY_TRUSTED_PROXIES): expected an IP address or a CIDR range, got %q", i, proxy))
+			}
+		}
+	}
+
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_timeout: must be positive, got %s", c.Server.ShutdownTimeout))
+	}
+
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("server.tls_cert_file and server.tls_key_file: must be set together to serve HTTPS"))
+	} else if c.Server.TLSEnabled() {
+		if _, err := tls.LoadX509KeyPair(c.Server.TLSCertFile, c.Server.TLSKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls_cert_file and server.tls_key_file: %w", err))
+		}
+	}
+
+	errs = append(errs, c.Database.validate()...)
+
+	// A response cut off by the server's write timeout cannot report that the database timed out
+	if c.Server.WriteTimeout > 0 && c.Database.Timeout > 0 && c.Database.Timeout >= c.Server.WriteTimeout {
+		errs = append(errs, fmt.Errorf("database.timeout: must be shorter than server.write_timeout (%s), got %s", c.Server.WriteTimeout, c.Database.Timeout))
+	}
+
+	if !contains(LogLevels, c.Log.Level) {
+		errs = append(errs, fmt.Errorf("log.level (LIBRARY_LOG_LEVEL): must be one of %s, got %q", strings.Join(LogLevels, ", "), c.Log.Level))
+	}
+
+	if !contains(TracingExporters, c.Tracing.Exporter) {
+		errs = append(errs, fmt.Errorf("tracing.exporter (LIBRARY_TRACING_EXPORTER): must be one of %s, got %q", strings.Join(TracingExporters, ", "), c.Tracing.Exporter))
+	}
+
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, fmt.Errorf("tracing.service_name (LIBRARY_TRACING_SERVICE_NAME): must not be empty"))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio (LIBRARY_TRACING_SAMPLE_RATIO): must be between 0 and 1, got %g", c.Tracing.SampleRatio))
+	}
+
+	errs = append(errs, c.Auth.validate()...)
+	errs = append(errs, c.RateLimit.validate()...)
+	errs = append(errs, c.Loans.validate()...)
+	errs = append(errs, c.Fines.validate()...)
+
+	if c.TestMode != "" && c.TestMode != "integration" {
+		errs = append(errs, fmt.Errorf("test_mode (TEST_MODE): must be empty or \"integration\", got %q", c.TestMode))
+	}
+
+	return joinErrors(errs)
+}
+
+// validate returns an error for every invalid authentication setting. The files are only checked to be readable here, and are parsed by the auth package
+func (a AuthConfig) validate() []error {
+	var errs []error
+
+	if a.Enabled && len(a.APIKeys) == 0 && a.APIKeysFile == "" && a.JWT.HS256KeyFile == "" && a.JWT.RS256PublicKeyFile == "" {
+		errs = append(errs, fmt.Errorf("auth.enabled (LIBRARY_AUTH_ENABLED): requires auth.api_keys, auth.api_keys_file, auth.jwt.hs256_key_file or auth.jwt.rs256_public_key_file, or set it to false to serve the API without authentication"))
+	}
+
+	names := make(map[string]bool, len(a.APIKeys))
+	for i, apiKey := range a.APIKeys {
+		if apiKey.Name == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].name: must not be empty", i))
+		} else if names[apiKey.Name] {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].name: %q is used by another key", i, apiKey.Name))
+		}
+		names[apiKey.Name] = true
+
+		if len(apiKey.Key) < MinAPIKeyLength {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].key: must be at least %d characters long", i, MinAPIKeyLength))
+		}
+
+		if !contains(Roles, apiKey.Role) {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].role: must be one of %s, got %q", i, strings.Join(Roles, ", "), apiKey.Role))
+		} else if apiKey.Role == "patron" && apiKey.CustomerID == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].customer_id: is required for patrons", i))
+		}
+	}
+
+	for _, file := range []struct{ name string; path string }{
+		{"auth.api_keys_file (LIBRARY_API_KEYS_FILE)", a.APIKeysFile},
+		{"auth.jwt.hs256_key_file (LIBRARY_JWT_HS256_KEY_FILE)", a.JWT.HS256KeyFile},
+		{"auth.jwt.rs256_public_key_file (LIBRARY_JWT_RS256_PUBLIC_KEY_FILE)", a.JWT.RS256PublicKeyFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.name, err))
+		}
+	}
+
+	return errs
+}
+
+// validate returns an error for every invalid rate limiting setting. The buckets are only checked when rate limiting is enabled
+func (r RateLimitConfig) validate() []error {
+	var errs []error
+
+	if !r.Enabled {
+		return nil
+	}
+
+	for _, bucket := range []struct{ name string; env string; rate RateConfig }{
+		{"rate_limit.read", "LIBRARY_RATE_LIMIT_READ", r.Read},
+		{"rate_limit.write", "LIBRARY_RATE_LIMIT_WRITE", r.Write},
+		{"rate_limit.auth_failures", "LIBRARY_RATE_LIMIT_AUTH_FAILURES", r.AuthFailures},
+	} {
+		if bucket.rate.RequestsPerSecond <= 0 {
+			errs = append(errs, fmt.Errorf("%s.requests_per_second (%s_RPS): must be positive, got %g", bucket.name, bucket.env, bucket.rate.RequestsPerSecond))
+		}
+		if bucket.rate.Burst < 1 {
+			errs = append(errs, fmt.Errorf("%s.burst (%s_BURST): must be at least 1, got %d", bucket.name, bucket.env, bucket.rate.Burst))
+		}
+	}
+
+	return errs
+}
+
+// validate returns an error for every invalid lending setting
+func (l LoansConfig) validate() []error {
+	var errs []error
+
+	if l.Period <= 0 {
+		errs = append(errs, fmt.Errorf("loans.period (LIBRARY_LOAN_PERIOD): must be positive, got %s", l.Period))
+	}
+
+	if l.MaxRenewals < 0 {
+		errs = append(errs, fmt.Errorf("loans.max_renewals (LIBRARY_MAX_RENEWALS): must not be negative, got %d", l.MaxRenewals))
+	}
+
+	if l.HoldPickupWindow <= 0 {
+		errs = append(errs, fmt.Errorf("loans.hold_pickup_window (LIBRARY_HOLD_PICKUP_WINDOW): must be positive, got %s", l.HoldPickupWindow))
+	}
+
+	if l.HoldSweepInterval <= 0 {
+		errs = append(errs, fmt.Errorf("loans.hold_sweep_interval (LIBRARY_HOLD_SWEEP_INTERVAL): must be positive, got %s", l.HoldSweepInterval))
+	}
+
+	return errs
+}
+
+// validate returns an error for every invalid fine setting
+func (f FinesConfig) validate() []error {
+	var errs []error
+
+	if f.DailyRate < 0 {
+		errs = append(errs, fmt.Errorf("fines.daily_rate (LIBRARY_FINE_DAILY_RATE): must not be negative, got %d", f.DailyRate))
+	}
+
+	if f.Cap < 0 {
+		errs = append(errs, fmt.Errorf("fines.cap (LIBRARY_FINE_CAP): must not be negative, got %d", f.Cap))
+	}
+
+	if f.CheckoutThreshold < 0 {
+		errs = append(errs, fmt.Errorf("fines.checkout_threshold (LIBRARY_FINE_CHECKOUT_THRESHOLD): must not be negative, got %d", f.CheckoutThreshold))
+	}
+
+	return errs
+}
+
+// validate returns an error for every invalid database setting
+func (d DatabaseConfig) validate() []error {
+	var errs []error
+
+	if d.Driver == "" {
+		return append(errs, fmt.Errorf("database.driver (DAO_SELECTION): must be set to one of %s", strings.Join(Drivers, ", ")))
+	}
+
+	if !contains(Drivers, d.Driver) {
+		return append(errs, fmt.Errorf("database.driver (DAO_SELECTION): must be one of %s, got %q", strings.Join(Drivers, ", "), d.Driver))
+	}
+
+	if d.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("database.timeout: must not be negative, got %s", d.Timeout))
+	}
+
+	if d.Migrate != "apply" && d.Migrate != "verify" {
+		errs = append(errs, fmt.Errorf("database.migrate: must be apply or verify, got %q", d.Migrate))
+	} else if d.Migrate == "verify" && d.Driver != "mysql" {
+		errs = append(errs, fmt.Errorf("database.migrate: verify is only supported by the mysql driver"))
+	}
+
+	switch d.Driver {
+	case "mysql", "postgres":
+		errs = append(errs, d.validateConnection()...)
+	case "sqlite":
+		if d.Path == "" {
+			errs = append(errs, fmt.Errorf("database.path (LIBRARY_DB_PATH): required by the sqlite driver"))
+		}
+	}
+
+	return errs
+}
+
+// validateConnection returns an error for every invalid connection setting of the mysql and postgres drivers
+func (d DatabaseConfig) validateConnection() []error {
+	var errs []error
+
+	if d.DSN != "" {
+		var conflictingSettings []string
+		for _, setting := range []struct{ name string; isSet bool }{
+			{"database.username", d.Username != ""},
+			{"database.password", d.Password != ""},
+			{"database.host", d.Host != ""},
+			{"database.port", d.Port != 0},
+			{"database.name", d.Name != ""},
+		} {
+			if setting.isSet {
+				conflictingSettings = append(conflictingSettings, setting.name)
+			}
+		}
+		if len(conflictingSettings) > 0 {
+			errs = append(errs, fmt.Errorf("database.dsn: cannot be combined with %s", strings.Join(conflictingSettings, ", ")))
+		}
+
+		if d.Driver == "mysql" {
+			if _, err := mysql.ParseDSN(d.DSN); err != nil {
+				errs = append(errs, fmt.Errorf("database.dsn: not a valid MySQL DSN: %w", err))
+			}
+		}
+	} else {
+		if d.Username == "" {
+			errs = append(errs, fmt.Errorf("database.username (LIBRARY_DB_USERNAME): required by the %s driver unless database.dsn is set", d.Driver))
+		}
+		if d.Host == "" {
+			errs = append(errs, fmt.Errorf("database.host (LIBRARY_DB_HOST): required by the %s driver unless database.dsn is set", d.Driver))
+		}
+		if d.Name == "" {
+			errs = append(errs, fmt.Errorf("database.name (LIBRARY_DB_NAME): required by the %s driver unless database.dsn is set", d.Driver))
+		}
+	}
+
+	if d.Port < 0 || d.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database.port: must be between 1 and 65535, got %d", d.Port))
+	}
+
+	if d.Driver == "mysql" && !contains([]string{"false", "true", "skip-verify", "preferred"}, d.MySQL.TLS) {
+		errs = append(errs, fmt.Errorf("database.mysql.tls: must be one of false, true, skip-verify, preferred, got %q", d.MySQL.TLS))
+	}
+
+	if d.Pool.MaxOpenConns < 0 || d.Pool.MaxIdleConns < 0 || d.Pool.ConnMaxLifetime < 0 || d.Pool.ConnMaxIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("database.pool: settings must not be negative"))
+	} else if d.Pool.MaxOpenConns > 0 && d.Pool.MaxIdleConns > d.Pool.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("database.pool.max_idle_conns: must not exceed max_open_conns (%d), got %d", d.Pool.MaxOpenConns, d.Pool.MaxIdleConns))
+	}
+
+	return errs
+}
+
+// MySQLDSN returns the data source name for the mysql driver, either the configured DSN or one built from the individual settings
+func (d DatabaseConfig) MySQLDSN() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	port := d.Port
+	if port == 0 {
+		port = 3306
+	}
+
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.User = d.Username
+	mysqlConfig.Passwd = d.Password
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = net.JoinHostPort(d.Host, strconv.Itoa(port))
+	mysqlConfig.DBName = d.Name
+	mysqlConfig.TLSConfig = d.MySQL.TLS
+	mysqlConfig.ParseTime = d.MySQL.ParseTime
+
+	mysqlConfig.Params = make(map[string]string)
+	for key, value := range d.MySQL.Params {
+		mysqlConfig.Params[key] = value
+	}
+	if d.MySQL.Charset != "" {
+		mysqlConfig.Params["charset"] = d.MySQL.Charset
+	}
+
+	return mysqlConfig.FormatDSN()
+}
+
+// PostgresDSN returns the connection string for the postgres driver, either the configured DSN or a URL built from the individual settings
+func (d DatabaseConfig) PostgresDSN() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	port := d.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	dsn := url.URL{
+		Scheme: "postgres",
+		User: url.UserPassword(d.Username, d.Password),
+		Host: net.JoinHostPort(d.Host, strconv.Itoa(port)),
+		Path: "/" + d.Name,
+	}
+
+	return dsn.String()
+}
+
+// Redacted returns a copy of the configuration with the database password, including any password inside the DSN, and the API keys replaced
+func (c *Config) Redacted() *Config {
+	redactedConfig := *c
+
+	if redactedConfig.Database.Password != "" {
+		redactedConfig.Database.Password = redacted
+	}
+
+	if redactedConfig.Database.DSN != "" {
+		redactedConfig.Database.DSN = redactDSN(c.Database.Driver, c.Database.DSN)
+	}
+
+	// The slice is copied, so that the keys of c are left alone
+	if c.Auth.APIKeys != nil {
+		redactedConfig.Auth.APIKeys = make([]APIKeyConfig, 0, len(c.Auth.APIKeys))
+		for _, apiKey := range c.Auth.APIKeys {
+			redactedConfig.Auth.APIKeys = append(redactedConfig.Auth.APIKeys, APIKeyConfig{Name: apiKey.Name, Key: redacted, Role: apiKey.Role, CustomerID: apiKey.CustomerID})
+		}
+	}
+
+	return &redactedConfig
+}
+
+// redactDSN replaces the password inside dsn, or the whole DSN if it cannot be parsed
+func redactDSN(driver string, dsn string) string {
+	if driver == "mysql" {
+		mysqlConfig, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return redacted
+		}
+		if mysqlConfig.Passwd != "" {
+			mysqlConfig.Passwd = redacted
+		}
+		return mysqlConfig.FormatDSN()
+	}
+
+	parsedURL, err := url.Parse(dsn)
+	if err != nil || parsedURL.Scheme == "" {
+		return redacted
+	}
+	if _, hasPassword := parsedURL.User.Password(); hasPassword {
+		parsedURL.User = url.UserPassword(parsedURL.User.Username(), redacted)
+	}
+	return parsedURL.String()
+}
+
+// String returns the configuration as YAML, with secrets redacted, so that it can be logged
+func (c *Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("failed to print configuration: %v", err)
+	}
+
+	return string(out)
+}
+
+// Address returns the host and port to listen on
+func (s ServerConfig) Address() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// TLSEnabled reports whether the server serves HTTPS
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// contains reports whether value is one of values
+func contains(values []string, value string) bool {
+	for _, currentValue := range values {
+		if currentValue == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinErrors combines errs into a single error listing each of them, or returns nil if there are none
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return errors.New(strings.Join(messages, "; "))
+}
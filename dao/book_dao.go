@@ -2,13 +2,38 @@ package dao
 
 import (
 	"example/library_project/models"
+
+	"context"
 )
 
 type BookDAO interface {
 	// once a persistent database is added, these methods will also return an error type
-	Create(newBook *models.Book) error
-	Read(isbn string) (*models.Book, error)
-	ReadAll() ([]*models.Book, error)
-	Update(book *models.Book) error
-	Delete(book *models.Book) error
-}
\ No newline at end of file
+	// Every method takes the context of the request it serves. Once the context is canceled or its deadline passes, the method gives up and returns an error wrapping ctx.Err()
+	// Every implementation stores times with NormalizeTime and empty hold queues as nil, and the books passed to Create and Update are normalized in place to match what is stored.
+	// The behavior of every implementation is checked by the conformance tests in the daotest package
+
+	// Create stores the new book with version 1, and sets newBook.Version accordingly. It returns ErrBookAlreadyExists if the ISBN is in use
+	Create(ctx context.Context, newBook *models.Book) error
+	Read(ctx context.Context, isbn string) (*models.Book, error)
+	ReadAll(ctx context.Context) ([]*models.Book, error)
+
+	// Query returns the books matching the filters of the query, sorted and paginated as requested
+	Query(ctx context.Context, query BookQuery) ([]*models.Book, error)
+
+	// Update stores the book and increments its version, setting book.Version to the new version. It returns ErrBookNotFound if the book does not exist
+	Update(ctx context.Context, book *models.Book) error
+
+	// Delete removes the book with the ISBN of book. Deleting a missing book is not an error.
+	// When book.Version is not 0, the book is only removed if it is still at that version, and ErrVersionMismatch is returned if it was changed or removed since
+	Delete(ctx context.Context, book *models.Book) error
+
+	// UpdateAtomically reads the book with the given ISBN, passes it to modify, and stores the book returned by modify, as a single atomic operation.
+	// No other update to the same book can happen in between the read and the write.
+	// The version is incremented only if modify actually changed the book. The returned book is the book as stored.
+	// It returns ErrBookNotFound if the book does not exist, any error returned by modify unchanged, and ErrConcurrentUpdate if the backend gave up waiting on a concurrent update
+	UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error)
+
+	// UpdateAtomicallyWithFines is UpdateAtomically for the updates that read or charge fines, such as checkouts and returns.
+	// modify is also given the fines, and what it reads and creates through them is part of the same atomic operation: either the book is stored and the fines are created, or neither is
+	UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines FineTx) (*models.Book, error)) (*models.Book, error)
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"example/library_project/models"
+
+	"fmt"
+	"time"
+)
+
+// Fields that books can be sorted by in a BookQuery
+const (
+	SortByISBN = "isbn"
+	SortByTimeCreated = "timecreated"
+	SortByTimeUpdated = "timeupdated"
+	SortByTimeDue = "timedue"
+)
+
+// BookQuery describes which books BookDAO.Query returns, and in which order. Nil filters are not applied
+type BookQuery struct {
+	// State only returns books in the given state
+	State *string
+
+	// CustomerID only returns books that the given customer has on-hold or checked-out
+	CustomerID *string
+
+	// CreatedAfter and CreatedBefore only return books created strictly after, or strictly before, the given times
+	CreatedAfter *time.Time
+	CreatedBefore *time.Time
+
+	// UpdatedAfter only returns books updated strictly after the given time. Books that were never updated are excluded
+	UpdatedAfter *time.Time
+
+	// DueBefore only returns books due strictly before the given time. Books without a due date are excluded
+	DueBefore *time.Time
+
+	// HoldExpiryBefore only returns books whose hold expires strictly before the given time. Books without a hold expiry are excluded
+	HoldExpiryBefore *time.Time
+
+	// SortBy is one of SortByISBN, SortByTimeCreated, SortByTimeUpdated or SortByTimeDue. Books with equal sort keys are ordered by ISBN, in the same direction.
+	// When sorting by time updated or time due, books without that time come first in ascending order, and last in descending order
+	SortBy string
+	SortDescending bool
+
+	// After only returns the books that come strictly after the given key in the order of the query, which is how the next page starts after the last book of the previous one.
+	// Unlike an offset, the key stays valid when books before it are created, deleted or moved, and lets the database seek to it instead of scanning the skipped books
+	After *BookKey
+
+	// Limit is the maximum number of books to return, with zero meaning no limit
+	Limit int
+}
+
+// BookKey is the position of a book in the order of a BookQuery
+type BookKey struct {
+	// SortTime is the time the book is sorted by, which is nil when sorting by ISBN or when the book does not have that time
+	SortTime *time.Time
+
+	ISBN string
+}
+
+// KeyOf returns the position of the book in the order given by sortBy
+func KeyOf(book *models.Book, sortBy string) *BookKey {
+	key := &BookKey{ISBN: *book.ISBN}
+
+	switch sortBy {
+	case SortByTimeCreated:
+		key.SortTime = book.TimeCreated
+	case SortByTimeUpdated:
+		key.SortTime = book.TimeUpdated
+	case SortByTimeDue:
+		key.SortTime = book.TimeDue
+	}
+
+	if key.SortTime != nil {
+		key.SortTime = NormalizeTime(key.SortTime)
+	}
+
+	return key
+}
+
+// KeysetCondition returns the SQL condition selecting the rows that come strictly after query.After, for the SQL DAOs to add to the WHERE clause of Query.
+// sortColumn and isbnColumn are the columns the query is sorted by, placeholder adds an argument and returns its placeholder, and timeArg converts a time into the argument the backend compares with sortColumn.
+// Rows without the sort time come first in ascending order and last in descending order, as documented on BookQuery
+func KeysetCondition(query BookQuery, sortColumn string, isbnColumn string, placeholder func(arg any) string, timeArg func(t *time.Time) any) string {
+	comparison := ">"
+	if query.SortDescending {
+		comparison = "<"
+	}
+
+	if sortColumn == isbnColumn {
+		return fmt.Sprintf("%s %s %s", isbnColumn, comparison, placeholder(query.After.ISBN))
+	}
+
+	// The previous page ended among the rows without the sort time
+	if query.After.SortTime == nil {
+		condition := fmt.Sprintf("(%s IS NULL AND %s %s %s)", sortColumn, isbnColumn, comparison, placeholder(query.After.ISBN))
+		if !query.SortDescending {
+			condition = fmt.Sprintf("(%s OR %s IS NOT NULL)", condition, sortColumn)
+		}
+		return condition
+	}
+
+	sortTime := timeArg(query.After.SortTime)
+	condition := fmt.Sprintf("(%s %s %s OR (%s = %s AND %s %s %s))", sortColumn, comparison, placeholder(sortTime), sortColumn, placeholder(sortTime), isbnColumn, comparison, placeholder(query.After.ISBN))
+	if query.SortDescending {
+		condition = fmt.Sprintf("(%s OR %s IS NULL)", condition, sortColumn)
+	}
+	return condition
+}
@@ -0,0 +1,33 @@
+package dao
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ConnectionPool holds the connection pool settings of the DAO factories backed by a database server. Zero values keep the database/sql defaults
+type ConnectionPool struct {
+	MaxOpenConns int
+	MaxIdleConns int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Apply configures db with the settings that are not zero
+func (p ConnectionPool) Apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
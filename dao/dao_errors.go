@@ -0,0 +1,23 @@
+package dao
+
+import (
+	"errors"
+)
+
+// ErrBookNotFound is returned by BookDAO methods that require the book to already exist
+var ErrBookNotFound = errors.New("book not found")
+
+// ErrConcurrentUpdate is returned when a change could not be applied because another request changed the same book at the same time
+var ErrConcurrentUpdate = errors.New("book was modified concurrently")
+
+// ErrVersionMismatch is returned by Delete when the book is no longer at the version the caller asked to delete
+var ErrVersionMismatch = errors.New("book version does not match")
+
+// ErrBookAlreadyExists is returned by Create when a book with the same ISBN is already stored
+var ErrBookAlreadyExists = errors.New("book already exists")
+
+// ErrFineNotFound is returned by FineDAO methods that require the fine to already exist
+var ErrFineNotFound = errors.New("fine not found")
+
+// ErrFineAlreadyExists is returned by Create when a fine with the same ID is already stored
+var ErrFineAlreadyExists = errors.New("fine already exists")
@@ -1,8 +1,18 @@
 package dao
 
+import (
+	"context"
+)
+
 type DAOFactory interface{
 	BookDAO() BookDAO
+	FineDAO() FineDAO
 	Open() error
 	Close() error
+
+	// Clear removes every book and every fine
 	Clear() error
-}
\ No newline at end of file
+
+	// Ping checks that the storage solution can currently be reached, for readiness checks. It returns an error if the factory is not open
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,34 @@
+package dao
+
+import (
+	"example/library_project/models"
+
+	"time"
+)
+
+// TimePrecision is the precision with which every BookDAO stores times. It is the finest precision supported by all of the storage solutions
+const TimePrecision = time.Microsecond
+
+// NormalizeTime converts t to UTC and truncates it to TimePrecision, which is how every BookDAO returns stored times. A nil time stays nil
+func NormalizeTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	normalizedTime := t.UTC().Truncate(TimePrecision)
+	return &normalizedTime
+}
+
+// NormalizeBookTimes normalizes the times of the book in place with NormalizeTime, so that it matches the book as stored
+func NormalizeBookTimes(book *models.Book) {
+	book.TimeCreated = NormalizeTime(book.TimeCreated)
+	book.TimeUpdated = NormalizeTime(book.TimeUpdated)
+	book.TimeDue = NormalizeTime(book.TimeDue)
+	book.HoldExpiry = NormalizeTime(book.HoldExpiry)
+}
+
+// NormalizeFineTimes normalizes the times of the fine in place with NormalizeTime, so that it matches the fine as stored
+func NormalizeFineTimes(fine *models.Fine) {
+	fine.TimeCreated = NormalizeTime(fine.TimeCreated)
+	fine.TimeUpdated = NormalizeTime(fine.TimeUpdated)
+}
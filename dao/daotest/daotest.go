@@ -0,0 +1,860 @@
+// Package daotest contains the behavioral contract that every implementation of dao.DAOFactory, dao.BookDAO and dao.FineDAO must satisfy.
+// Each implementation runs it from its own tests with RunDAOFactoryTests, so that the storage solutions cannot silently diverge
+package daotest
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// arbitraryTime is a time with microsecond precision in UTC, which every implementation stores exactly
+var arbitraryTime = time.Date(2023, 2, 1, 1, 30, 0, 123456000, time.UTC)
+
+// RunDAOFactoryTests runs the contract against the factories returned by newDAOFactory, which is called once per test and must return a factory that has not been opened yet.
+// Every test opens the factory, clears it, and closes it once the test is over, so factories sharing a database must not be used by anything else in the meantime
+func RunDAOFactoryTests(t *testing.T, newDAOFactory func(t *testing.T) dao.DAOFactory) {
+	tests := []struct{
+		description string
+		test func(t *testing.T, daoFactory dao.DAOFactory)
+	}{
+		{"Create and Read round-trip every field", testCreateAndRead},
+		{"Nil fields round-trip as nil", testNilFields},
+		{"Times are stored in UTC at microsecond precision", testTimePrecision},
+		{"Read returns nil for a missing book", testReadMissing},
+		{"Create returns ErrBookAlreadyExists for an ISBN in use", testCreateDuplicate},
+		{"ReadAll returns every book", testReadAll},
+		{"Update stores the book and increments its version", testUpdate},
+		{"Update returns ErrBookNotFound for a missing book", testUpdateMissing},
+		{"Hold queues keep their order, and empty queues are stored as nil", testHoldQueue},
+		{"Delete removes the book", testDelete},
+		{"Delete with a version only removes the book at that version", testDeleteVersion},
+		{"Modifying returned books does not modify stored books", testDefensiveCopies},
+		{"UpdateAtomically applies modify", testUpdateAtomically},
+		{"UpdateAtomically returns ErrBookNotFound for a missing book", testUpdateAtomicallyMissing},
+		{"UpdateAtomically leaves the book untouched when modify fails", testUpdateAtomicallyError},
+		{"UpdateAtomically only increments the version when the book changed", testUpdateAtomicallyNoChange},
+		{"UpdateAtomically never loses concurrent updates", testUpdateAtomicallyConcurrent},
+		{"Query filters books", testQueryFilters},
+		{"Query sorts books", testQuerySort},
+		{"Query paginates books", testQueryPagination},
+		{"Query pages follow the sort order, through ties and missing times", testQueryKeysetPagination},
+		{"FineDAO Create and Read round-trip every field", testFineCreateAndRead},
+		{"FineDAO ReadByCustomer returns the fines of the customer, oldest first", testFineReadByCustomer},
+		{"FineDAO UpdateAtomically applies modify", testFineUpdateAtomically},
+		{"FineDAO UpdateAtomically never applies concurrent updates to the same fine", testFineUpdateAtomicallyConcurrent},
+		{"UpdateAtomicallyWithFines stores the book and the fines created by modify together", testUpdateAtomicallyWithFines},
+		{"Clear removes every book and every fine", testClear},
+		{"Concurrent calls to every method are safe", testConcurrentAccess},
+		{"Every method fails once the context is canceled", testCanceledContext},
+		{"Ping succeeds while the factory is open", testPing},
+		{"Every method fails once the context deadline has passed", testExpiredContext},
+	}
+
+	for _, currentTestCase := range tests {
+		currentTestCase := currentTestCase
+		t.Run(currentTestCase.description, func(t *testing.T) {
+			daoFactory := newDAOFactory(t)
+
+			if err := daoFactory.Open(); err != nil {
+				t.Fatal("failed to open database connection: ", err)
+			}
+			t.Cleanup(func() {
+				if err := daoFactory.Close(); err != nil {
+					t.Error("failed to close database connection: ", err)
+				}
+			})
+
+			if err := daoFactory.Clear(); err != nil {
+				t.Fatal("failed to clear database: ", err)
+			}
+
+			currentTestCase.test(t, daoFactory)
+		})
+	}
+}
+
+// newAvailableBook returns an available book created at arbitraryTime
+func newAvailableBook(isbn string) *models.Book {
+	return &models.Book{
+		ISBN: utils.ToPtr(isbn),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	}
+}
+
+// mustCreate creates the book, failing the test immediately if it cannot be created
+func mustCreate(t *testing.T, bookDAO dao.BookDAO, book *models.Book) {
+	t.Helper()
+	ctx := context.Background()
+	if err := bookDAO.Create(ctx, book); err != nil {
+		t.Fatal("failed to create book: ", err)
+	}
+}
+
+// isbnsOf returns the ISBNs of the books, in order
+func isbnsOf(books []*models.Book) []string {
+	isbns := make([]string, 0, len(books))
+	for _, book := range books {
+		isbns = append(isbns, *book.ISBN)
+	}
+	return isbns
+}
+
+func testCreateAndRead(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	newBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("02"),
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: utils.ToPtr(arbitraryTime.Add(time.Hour)),
+		TimeDue: utils.ToPtr(arbitraryTime.Add(14 * 24 * time.Hour)),
+		RenewalCount: utils.ToPtr(0),
+		HoldQueue: []string{"03", "01"},
+		HoldExpiry: utils.ToPtr(arbitraryTime.Add(72 * time.Hour)),
+	}
+	assert.Nil(t, bookDAO.Create(ctx, newBook))
+	assert.Equal(t, int64(1), newBook.Version)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("02"),
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: utils.ToPtr(arbitraryTime.Add(time.Hour)),
+		TimeDue: utils.ToPtr(arbitraryTime.Add(14 * 24 * time.Hour)),
+		RenewalCount: utils.ToPtr(0),
+		HoldQueue: []string{"03", "01"},
+		HoldExpiry: utils.ToPtr(arbitraryTime.Add(72 * time.Hour)),
+		Version: 1,
+	}, retrievedBook)
+
+	// A second DAO from the same factory sees the same books
+	retrievedBook, err = daoFactory.BookDAO().Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.NotNil(t, retrievedBook)
+}
+
+func testNilFields(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("01"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	})
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook.CheckedOutCustomerID)
+	assert.Nil(t, retrievedBook.TimeUpdated)
+	assert.Nil(t, retrievedBook.TimeDue)
+	assert.Nil(t, retrievedBook.RenewalCount)
+	assert.Nil(t, retrievedBook.HoldQueue)
+	assert.Nil(t, retrievedBook.HoldExpiry)
+
+	// Setting a field back to nil must be stored too
+	retrievedBook.State = utils.ToPtr("available")
+	retrievedBook.OnHoldCustomerID = nil
+	assert.Nil(t, bookDAO.Update(ctx, retrievedBook))
+
+	retrievedBook, err = bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook.OnHoldCustomerID)
+	assert.Nil(t, retrievedBook.CheckedOutCustomerID)
+	assert.Nil(t, retrievedBook.TimeUpdated)
+}
+
+func testTimePrecision(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	// A time with nanoseconds in a time zone other than UTC
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	preciseTime := time.Date(2023, 2, 1, 6, 30, 0, 123456789, zone)
+	expectedTime := time.Date(2023, 2, 1, 1, 30, 0, 123456000, time.UTC)
+
+	newBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(preciseTime),
+		TimeUpdated: nil,
+	}
+	mustCreate(t, bookDAO, newBook)
+
+	// The book passed to Create is normalized to match what is stored
+	assert.Equal(t, expectedTime, *newBook.TimeCreated)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedTime, *retrievedBook.TimeCreated)
+
+	retrievedBook.TimeUpdated = utils.ToPtr(preciseTime.Add(time.Hour))
+	assert.Nil(t, bookDAO.Update(ctx, retrievedBook))
+	assert.Equal(t, expectedTime.Add(time.Hour), *retrievedBook.TimeUpdated)
+
+	retrievedBook, err = bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedTime, *retrievedBook.TimeCreated)
+	assert.Equal(t, expectedTime.Add(time.Hour), *retrievedBook.TimeUpdated)
+}
+
+func testReadMissing(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	retrievedBook, err := daoFactory.BookDAO().Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook)
+}
+
+func testCreateDuplicate(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	duplicateBook := newAvailableBook("00001")
+	duplicateBook.State = utils.ToPtr("on-hold")
+	duplicateBook.OnHoldCustomerID = utils.ToPtr("01")
+	assert.ErrorIs(t, bookDAO.Create(ctx, duplicateBook), dao.ErrBookAlreadyExists)
+
+	// The original book is untouched
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "available", *retrievedBook.State)
+}
+
+func testReadAll(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	allBooks, err := bookDAO.ReadAll(ctx)
+	assert.Nil(t, err)
+	assert.NotNil(t, allBooks)
+	assert.Empty(t, allBooks)
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+	mustCreate(t, bookDAO, newAvailableBook("00002"))
+	mustCreate(t, bookDAO, newAvailableBook("00003"))
+
+	allBooks, err = bookDAO.ReadAll(ctx)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"00001", "00002", "00003"}, isbnsOf(allBooks))
+}
+
+func testUpdate(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	updatedBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("02"),
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: utils.ToPtr(arbitraryTime.Add(time.Hour)),
+		TimeDue: utils.ToPtr(arbitraryTime.Add(time.Hour + 14 * 24 * time.Hour)),
+		RenewalCount: utils.ToPtr(2),
+		HoldQueue: []string{"01"},
+	}
+	assert.Nil(t, bookDAO.Update(ctx, updatedBook))
+	assert.Equal(t, int64(2), updatedBook.Version)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("02"),
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: utils.ToPtr(arbitraryTime.Add(time.Hour)),
+		TimeDue: utils.ToPtr(arbitraryTime.Add(time.Hour + 14 * 24 * time.Hour)),
+		RenewalCount: utils.ToPtr(2),
+		HoldQueue: []string{"01"},
+		Version: 2,
+	}, retrievedBook)
+}
+
+func testHoldQueue(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("01"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+		HoldQueue: []string{},
+	})
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook.HoldQueue)
+
+	// Customers are kept in the order they joined, not sorted
+	for _, customerID := range []string{"03", "02", "04"} {
+		_, err := bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+			currentBook.HoldQueue = append(currentBook.HoldQueue, customerID)
+			return currentBook, nil
+		})
+		assert.Nil(t, err)
+	}
+
+	retrievedBook, err = bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"03", "02", "04"}, retrievedBook.HoldQueue)
+	assert.Equal(t, int64(4), retrievedBook.Version)
+
+	// Emptying the queue stores it as nil, so replacing a nil queue with an empty one is not a change
+	updatedBook, err := bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		currentBook.HoldQueue = currentBook.HoldQueue[:0]
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, updatedBook.HoldQueue)
+	assert.Equal(t, int64(5), updatedBook.Version)
+
+	updatedBook, err = bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		currentBook.HoldQueue = []string{}
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), updatedBook.Version)
+
+	retrievedBook, err = bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook.HoldQueue)
+}
+
+func testUpdateMissing(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	assert.ErrorIs(t, bookDAO.Update(ctx, newAvailableBook("00001")), dao.ErrBookNotFound)
+
+	// Update must not create the book
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook)
+}
+
+func testDelete(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+	mustCreate(t, bookDAO, newAvailableBook("00002"))
+
+	assert.Nil(t, bookDAO.Delete(ctx, newAvailableBook("00001")))
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook)
+
+	// Other books are untouched
+	retrievedBook, err = bookDAO.Read(ctx, "00002")
+	assert.Nil(t, err)
+	assert.NotNil(t, retrievedBook)
+
+	// Deleting a missing book is not an error
+	assert.Nil(t, bookDAO.Delete(ctx, newAvailableBook("00001")))
+
+	// The ISBN can be reused, starting again from version 1
+	newBook := newAvailableBook("00001")
+	assert.Nil(t, bookDAO.Create(ctx, newBook))
+	assert.Equal(t, int64(1), newBook.Version)
+}
+
+func testDeleteVersion(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	updatedBook := newAvailableBook("00001")
+	updatedBook.State = utils.ToPtr("checked-out")
+	updatedBook.CheckedOutCustomerID = utils.ToPtr("01")
+	assert.Nil(t, bookDAO.Update(ctx, updatedBook))
+
+	// A stale version leaves the book untouched
+	staleBook := newAvailableBook("00001")
+	staleBook.Version = 1
+	assert.ErrorIs(t, bookDAO.Delete(ctx, staleBook), dao.ErrVersionMismatch)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.NotNil(t, retrievedBook)
+
+	// The current version removes the book
+	assert.Nil(t, bookDAO.Delete(ctx, retrievedBook))
+
+	retrievedBook, err = bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook)
+
+	// A book removed since it was read no longer has the version asked for
+	assert.ErrorIs(t, bookDAO.Delete(ctx, updatedBook), dao.ErrVersionMismatch)
+}
+
+func testDefensiveCopies(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	newBook := newAvailableBook("00001")
+	mustCreate(t, bookDAO, newBook)
+
+	// Modify the book passed to Create
+	*newBook.State = "checked-out"
+	newBook.CheckedOutCustomerID = utils.ToPtr("01")
+
+	// Modify the book returned by Read
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "available", *retrievedBook.State)
+	*retrievedBook.State = "on-hold"
+
+	// Modify the books returned by ReadAll and Query
+	allBooks, err := bookDAO.ReadAll(ctx)
+	assert.Nil(t, err)
+	*allBooks[0].State = "on-hold"
+
+	queriedBooks, err := bookDAO.Query(ctx, dao.BookQuery{SortBy: dao.SortByISBN})
+	assert.Nil(t, err)
+	*queriedBooks[0].State = "on-hold"
+
+	// Modify the book returned by UpdateAtomically
+	updatedBook, err := bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+	*updatedBook.State = "on-hold"
+
+	retrievedBook, err = bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "available", *retrievedBook.State)
+	assert.Nil(t, retrievedBook.CheckedOutCustomerID)
+}
+
+func testUpdateAtomically(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	updatedBook, err := bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		assert.Equal(t, "available", *currentBook.State)
+
+		currentBook.State = utils.ToPtr("on-hold")
+		currentBook.OnHoldCustomerID = utils.ToPtr("01")
+		currentBook.TimeUpdated = utils.ToPtr(arbitraryTime.Add(time.Hour))
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+
+	expectedBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("01"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: utils.ToPtr(arbitraryTime.Add(time.Hour)),
+		Version: 2,
+	}
+	assert.Equal(t, expectedBook, updatedBook)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedBook, retrievedBook)
+}
+
+func testUpdateAtomicallyMissing(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	_, err := daoFactory.BookDAO().UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		t.Error("modify must not be called for a missing book")
+		return currentBook, nil
+	})
+	assert.ErrorIs(t, err, dao.ErrBookNotFound)
+}
+
+func testUpdateAtomicallyError(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	modifyErr := errors.New("arbitrary error")
+	_, err := bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		*currentBook.State = "checked-out"
+		currentBook.CheckedOutCustomerID = utils.ToPtr("01")
+		return nil, modifyErr
+	})
+	assert.ErrorIs(t, err, modifyErr)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "available", *retrievedBook.State)
+	assert.Nil(t, retrievedBook.CheckedOutCustomerID)
+	assert.Equal(t, int64(1), retrievedBook.Version)
+}
+
+func testUpdateAtomicallyNoChange(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	unchangedBook, err := bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), unchangedBook.Version)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), retrievedBook.Version)
+}
+
+func testUpdateAtomicallyConcurrent(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	// Every worker moves TimeUpdated forward by one minute. If an update were lost, the final time would be too early
+	numberOfWorkers := 20
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < numberOfWorkers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+				if currentBook.TimeUpdated == nil {
+					currentBook.TimeUpdated = utils.ToPtr(arbitraryTime)
+				}
+				currentBook.TimeUpdated = utils.ToPtr(currentBook.TimeUpdated.Add(time.Minute))
+				return currentBook, nil
+			})
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, arbitraryTime.Add(time.Duration(numberOfWorkers)*time.Minute), *retrievedBook.TimeUpdated)
+	assert.Equal(t, int64(1+numberOfWorkers), retrievedBook.Version)
+}
+
+// createQueryTestBooks creates four books, each created one hour after the previous one. Only the checked-out book is due, and only the first on-hold book has a hold expiry
+func createQueryTestBooks(t *testing.T, bookDAO dao.BookDAO) {
+	t.Helper()
+	mustCreate(t, bookDAO, &models.Book{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime), TimeUpdated: nil})
+	mustCreate(t, bookDAO, &models.Book{ISBN: utils.ToPtr("00002"), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("42"), TimeCreated: utils.ToPtr(arbitraryTime.Add(1 * time.Hour)), TimeUpdated: utils.ToPtr(arbitraryTime.Add(10 * time.Hour)), TimeDue: utils.ToPtr(arbitraryTime.Add(24 * time.Hour))})
+	mustCreate(t, bookDAO, &models.Book{ISBN: utils.ToPtr("00003"), State: utils.ToPtr("on-hold"), OnHoldCustomerID: utils.ToPtr("42"), CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime.Add(2 * time.Hour)), TimeUpdated: utils.ToPtr(arbitraryTime.Add(5 * time.Hour)), HoldExpiry: utils.ToPtr(arbitraryTime.Add(48 * time.Hour))})
+	mustCreate(t, bookDAO, &models.Book{ISBN: utils.ToPtr("00004"), State: utils.ToPtr("on-hold"), OnHoldCustomerID: utils.ToPtr("07"), CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime.Add(3 * time.Hour)), TimeUpdated: nil})
+}
+
+func testQueryFilters(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+	createQueryTestBooks(t, bookDAO)
+
+	tests := []struct{
+		description string
+		query dao.BookQuery
+		expectedISBNs []string
+	}{
+		{"No filters", dao.BookQuery{}, []string{"00001", "00002", "00003", "00004"}},
+		{"State", dao.BookQuery{State: utils.ToPtr("on-hold")}, []string{"00003", "00004"}},
+		{"Customer", dao.BookQuery{CustomerID: utils.ToPtr("42")}, []string{"00002", "00003"}},
+		{"Created after is strict", dao.BookQuery{CreatedAfter: utils.ToPtr(arbitraryTime.Add(1 * time.Hour))}, []string{"00003", "00004"}},
+		{"Created before is strict", dao.BookQuery{CreatedBefore: utils.ToPtr(arbitraryTime.Add(1 * time.Hour))}, []string{"00001"}},
+		{"Updated after excludes books that were never updated", dao.BookQuery{UpdatedAfter: utils.ToPtr(arbitraryTime.Add(6 * time.Hour))}, []string{"00002"}},
+		{"Due before is strict", dao.BookQuery{DueBefore: utils.ToPtr(arbitraryTime.Add(24 * time.Hour))}, []string{}},
+		{"Due before excludes books that are not due", dao.BookQuery{DueBefore: utils.ToPtr(arbitraryTime.Add(25 * time.Hour))}, []string{"00002"}},
+		{"Hold expiry before is strict", dao.BookQuery{HoldExpiryBefore: utils.ToPtr(arbitraryTime.Add(48 * time.Hour))}, []string{}},
+		{"Hold expiry before excludes books without a hold expiry", dao.BookQuery{HoldExpiryBefore: utils.ToPtr(arbitraryTime.Add(49 * time.Hour))}, []string{"00003"}},
+		{"Filters are combined", dao.BookQuery{State: utils.ToPtr("on-hold"), CustomerID: utils.ToPtr("42")}, []string{"00003"}},
+		{"No match", dao.BookQuery{CustomerID: utils.ToPtr("99")}, []string{}},
+	}
+
+	for _, currentTestCase := range tests {
+		t.Log(currentTestCase.description)
+
+		currentTestCase.query.SortBy = dao.SortByISBN
+		books, err := bookDAO.Query(ctx, currentTestCase.query)
+		assert.Nil(t, err)
+		assert.Equal(t, currentTestCase.expectedISBNs, isbnsOf(books))
+	}
+}
+
+func testQuerySort(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+	createQueryTestBooks(t, bookDAO)
+
+	// A fifth book sharing its time created with 00004, to check that ties are broken by ISBN
+	mustCreate(t, bookDAO, &models.Book{ISBN: utils.ToPtr("00000"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime.Add(3 * time.Hour)), TimeUpdated: nil})
+
+	tests := []struct{
+		description string
+		sortBy string
+		sortDescending bool
+		expectedISBNs []string
+	}{
+		{"ISBN ascending", dao.SortByISBN, false, []string{"00000", "00001", "00002", "00003", "00004"}},
+		{"ISBN descending", dao.SortByISBN, true, []string{"00004", "00003", "00002", "00001", "00000"}},
+		{"Time created ascending", dao.SortByTimeCreated, false, []string{"00001", "00002", "00003", "00000", "00004"}},
+		{"Time created descending", dao.SortByTimeCreated, true, []string{"00004", "00000", "00003", "00002", "00001"}},
+		{"Time updated ascending puts books never updated first", dao.SortByTimeUpdated, false, []string{"00000", "00001", "00004", "00003", "00002"}},
+		{"Time updated descending puts books never updated last", dao.SortByTimeUpdated, true, []string{"00002", "00003", "00004", "00001", "00000"}},
+		{"Time due ascending puts books that are not due first", dao.SortByTimeDue, false, []string{"00000", "00001", "00003", "00004", "00002"}},
+		{"Time due descending puts books that are not due last", dao.SortByTimeDue, true, []string{"00002", "00004", "00003", "00001", "00000"}},
+	}
+
+	for _, currentTestCase := range tests {
+		t.Log(currentTestCase.description)
+
+		books, err := bookDAO.Query(ctx, dao.BookQuery{SortBy: currentTestCase.sortBy, SortDescending: currentTestCase.sortDescending})
+		assert.Nil(t, err)
+		assert.Equal(t, currentTestCase.expectedISBNs, isbnsOf(books))
+	}
+}
+
+func testQueryPagination(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	for i := 0; i < 7; i++ {
+		mustCreate(t, bookDAO, newAvailableBook(fmt.Sprintf("%05d", i)))
+	}
+
+	tests := []struct{
+		description string
+		limit int
+		after *dao.BookKey
+		expectedISBNs []string
+	}{
+		{"First page", 3, nil, []string{"00000", "00001", "00002"}},
+		{"Middle page", 3, &dao.BookKey{ISBN: "00002"}, []string{"00003", "00004", "00005"}},
+		{"Last page is partial", 3, &dao.BookKey{ISBN: "00005"}, []string{"00006"}},
+		{"Key of the last book", 3, &dao.BookKey{ISBN: "00006"}, []string{}},
+		{"Key of a book that is no longer stored", 3, &dao.BookKey{ISBN: "00002a"}, []string{"00003", "00004", "00005"}},
+		{"No limit", 0, &dao.BookKey{ISBN: "00003"}, []string{"00004", "00005", "00006"}},
+	}
+
+	for _, currentTestCase := range tests {
+		t.Log(currentTestCase.description)
+
+		books, err := bookDAO.Query(ctx, dao.BookQuery{SortBy: dao.SortByISBN, After: currentTestCase.after, Limit: currentTestCase.limit})
+		assert.Nil(t, err)
+		assert.Equal(t, currentTestCase.expectedISBNs, isbnsOf(books))
+	}
+}
+
+func testQueryKeysetPagination(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+	createQueryTestBooks(t, bookDAO)
+
+	// Books sharing their sort time with 00004, and books without a time updated or a time due, so that pages end on ties and on missing times
+	mustCreate(t, bookDAO, &models.Book{ISBN: utils.ToPtr("00000"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime.Add(3 * time.Hour)), TimeUpdated: nil})
+	mustCreate(t, bookDAO, &models.Book{ISBN: utils.ToPtr("00005"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime.Add(3 * time.Hour)), TimeUpdated: nil})
+
+	for _, sortBy := range []string{dao.SortByISBN, dao.SortByTimeCreated, dao.SortByTimeUpdated, dao.SortByTimeDue} {
+		for _, sortDescending := range []bool{false, true} {
+			t.Logf("Sorting by %s, descending: %v", sortBy, sortDescending)
+
+			allBooks, err := bookDAO.Query(ctx, dao.BookQuery{SortBy: sortBy, SortDescending: sortDescending})
+			assert.Nil(t, err)
+
+			// Following the key of the last book of every page of 2 returns every book once, in the same order
+			pagedISBNs := make([]string, 0)
+			query := dao.BookQuery{SortBy: sortBy, SortDescending: sortDescending, Limit: 2}
+			for page := 0; page < len(allBooks); page++ {
+				books, err := bookDAO.Query(ctx, query)
+				assert.Nil(t, err)
+				if len(books) == 0 {
+					break
+				}
+
+				pagedISBNs = append(pagedISBNs, isbnsOf(books)...)
+				query.After = dao.KeyOf(books[len(books)-1], sortBy)
+			}
+
+			assert.Equal(t, isbnsOf(allBooks), pagedISBNs)
+		}
+	}
+}
+
+func testClear(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+	mustCreate(t, bookDAO, newAvailableBook("00002"))
+	mustCreateFine(t, daoFactory.FineDAO(), newOutstandingFine("f1", "01", arbitraryTime))
+
+	assert.Nil(t, daoFactory.Clear())
+
+	customerFines, err := daoFactory.FineDAO().ReadByCustomer(ctx, "01")
+	assert.Nil(t, err)
+	assert.Empty(t, customerFines)
+
+	allBooks, err := bookDAO.ReadAll(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, allBooks)
+
+	// DAOs handed out after Clear see the same empty library
+	allBooks, err = daoFactory.BookDAO().ReadAll(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, allBooks)
+}
+
+func testConcurrentAccess(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	numberOfWorkers := 8
+	numberOfIterations := 10
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < numberOfWorkers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			bookDAO := daoFactory.BookDAO()
+
+			for i := 0; i < numberOfIterations; i++ {
+				isbn := fmt.Sprintf("%02d-%03d", worker, i)
+
+				if err := bookDAO.Create(ctx, newAvailableBook(isbn)); err != nil {
+					t.Error(err)
+					return
+				}
+
+				if _, err := bookDAO.Read(ctx, isbn); err != nil {
+					t.Error(err)
+				}
+
+				if _, err := bookDAO.ReadAll(ctx); err != nil {
+					t.Error(err)
+				}
+
+				if _, err := bookDAO.Query(ctx, dao.BookQuery{State: utils.ToPtr("available"), Limit: 5}); err != nil {
+					t.Error(err)
+				}
+
+				_, err := bookDAO.UpdateAtomically(ctx, isbn, func(currentBook *models.Book) (*models.Book, error) {
+					currentBook.State = utils.ToPtr("checked-out")
+					currentBook.CheckedOutCustomerID = utils.ToPtr("01")
+					return currentBook, nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+
+				if i%2 == 0 {
+					if err := bookDAO.Delete(ctx, newAvailableBook(isbn)); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	allBooks, err := daoFactory.BookDAO().ReadAll(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, allBooks, numberOfWorkers*numberOfIterations/2)
+}
+
+// checkContextErrors calls every BookDAO method with ctx, which must be done, and expects each call to fail with expectedErr and leave the library untouched
+func checkContextErrors(t *testing.T, daoFactory dao.DAOFactory, ctx context.Context, expectedErr error) {
+	bookDAO := daoFactory.BookDAO()
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+
+	assert.ErrorIs(t, bookDAO.Create(ctx, newAvailableBook("00002")), expectedErr)
+
+	_, err := bookDAO.Read(ctx, "00001")
+	assert.ErrorIs(t, err, expectedErr)
+
+	_, err = bookDAO.ReadAll(ctx)
+	assert.ErrorIs(t, err, expectedErr)
+
+	_, err = bookDAO.Query(ctx, dao.BookQuery{SortBy: dao.SortByISBN})
+	assert.ErrorIs(t, err, expectedErr)
+
+	updatedBook := newAvailableBook("00001")
+	updatedBook.State = utils.ToPtr("checked-out")
+	updatedBook.CheckedOutCustomerID = utils.ToPtr("01")
+	assert.ErrorIs(t, bookDAO.Update(ctx, updatedBook), expectedErr)
+
+	_, err = bookDAO.UpdateAtomically(ctx, "00001", func(currentBook *models.Book) (*models.Book, error) {
+		currentBook.State = utils.ToPtr("checked-out")
+		currentBook.CheckedOutCustomerID = utils.ToPtr("01")
+		return currentBook, nil
+	})
+	assert.ErrorIs(t, err, expectedErr)
+
+	assert.ErrorIs(t, bookDAO.Delete(ctx, newAvailableBook("00001")), expectedErr)
+
+	allBooks, err := bookDAO.ReadAll(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"00001"}, isbnsOf(allBooks))
+	assert.Equal(t, "available", *allBooks[0].State)
+	assert.Equal(t, int64(1), allBooks[0].Version)
+}
+
+func testCanceledContext(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	checkContextErrors(t, daoFactory, ctx, context.Canceled)
+	checkFineContextErrors(t, daoFactory, ctx, context.Canceled)
+}
+
+func testExpiredContext(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	checkContextErrors(t, daoFactory, ctx, context.DeadlineExceeded)
+	checkFineContextErrors(t, daoFactory, ctx, context.DeadlineExceeded)
+}
+
+func testPing(t *testing.T, daoFactory dao.DAOFactory) {
+	assert.Nil(t, daoFactory.Ping(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NotNil(t, daoFactory.Ping(ctx))
+}
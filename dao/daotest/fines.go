@@ -0,0 +1,304 @@
+package daotest
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newOutstandingFine returns an outstanding fine of 75 for three days, charged to the customer at timeCreated
+func newOutstandingFine(id string, customerID string, timeCreated time.Time) *models.Fine {
+	return &models.Fine{
+		ID: utils.ToPtr(id),
+		CustomerID: utils.ToPtr(customerID),
+		ISBN: utils.ToPtr("00001"),
+		DaysOverdue: utils.ToPtr(3),
+		Amount: utils.ToPtr(75),
+		State: utils.ToPtr("outstanding"),
+		TimeCreated: utils.ToPtr(timeCreated),
+		TimeUpdated: nil,
+	}
+}
+
+// mustCreateFine creates the fine, failing the test immediately if it cannot be created
+func mustCreateFine(t *testing.T, fineDAO dao.FineDAO, fine *models.Fine) {
+	t.Helper()
+	ctx := context.Background()
+	if err := fineDAO.Create(ctx, fine); err != nil {
+		t.Fatal("failed to create fine: ", err)
+	}
+}
+
+// fineIDsOf returns the IDs of the fines, in order
+func fineIDsOf(fines []*models.Fine) []string {
+	ids := make([]string, 0, len(fines))
+	for _, fine := range fines {
+		ids = append(ids, *fine.ID)
+	}
+	return ids
+}
+
+func testFineCreateAndRead(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	fineDAO := daoFactory.FineDAO()
+
+	// A time with nanoseconds in a time zone other than UTC
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	preciseTime := time.Date(2023, 2, 1, 6, 30, 0, 123456789, zone)
+
+	newFine := newOutstandingFine("f1", "01", preciseTime)
+	assert.Nil(t, fineDAO.Create(ctx, newFine))
+
+	// The fine passed to Create is normalized to match what is stored
+	expectedFine := newOutstandingFine("f1", "01", time.Date(2023, 2, 1, 1, 30, 0, 123456000, time.UTC))
+	assert.Equal(t, expectedFine, newFine)
+
+	retrievedFine, err := fineDAO.Read(ctx, "f1")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedFine, retrievedFine)
+
+	t.Log("Read returns nil for a missing fine")
+	retrievedFine, err = fineDAO.Read(ctx, "f2")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedFine)
+
+	t.Log("Create returns ErrFineAlreadyExists for an ID in use")
+	duplicateFine := newOutstandingFine("f1", "02", arbitraryTime)
+	assert.ErrorIs(t, fineDAO.Create(ctx, duplicateFine), dao.ErrFineAlreadyExists)
+
+	retrievedFine, err = daoFactory.FineDAO().Read(ctx, "f1")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedFine, retrievedFine)
+}
+
+func testFineReadByCustomer(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	fineDAO := daoFactory.FineDAO()
+
+	customerFines, err := fineDAO.ReadByCustomer(ctx, "01")
+	assert.Nil(t, err)
+	assert.NotNil(t, customerFines)
+	assert.Empty(t, customerFines)
+
+	// Created out of order, with two fines charged at the same time, which are ordered by ID
+	mustCreateFine(t, fineDAO, newOutstandingFine("f3", "01", arbitraryTime.Add(2 * time.Hour)))
+	mustCreateFine(t, fineDAO, newOutstandingFine("f2", "01", arbitraryTime))
+	mustCreateFine(t, fineDAO, newOutstandingFine("f4", "02", arbitraryTime.Add(time.Hour)))
+	mustCreateFine(t, fineDAO, newOutstandingFine("f1", "01", arbitraryTime))
+
+	paidFine := newOutstandingFine("f5", "01", arbitraryTime.Add(3 * time.Hour))
+	paidFine.State = utils.ToPtr("paid")
+	paidFine.TimeUpdated = utils.ToPtr(arbitraryTime.Add(4 * time.Hour))
+	mustCreateFine(t, fineDAO, paidFine)
+
+	customerFines, err = fineDAO.ReadByCustomer(ctx, "01")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"f1", "f2", "f3", "f5"}, fineIDsOf(customerFines))
+	assert.Equal(t, paidFine, customerFines[3])
+
+	customerFines, err = fineDAO.ReadByCustomer(ctx, "02")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"f4"}, fineIDsOf(customerFines))
+}
+
+func testFineUpdateAtomically(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	fineDAO := daoFactory.FineDAO()
+
+	mustCreateFine(t, fineDAO, newOutstandingFine("f1", "01", arbitraryTime))
+
+	t.Log("UpdateAtomically returns ErrFineNotFound for a missing fine")
+	_, err := fineDAO.UpdateAtomically(ctx, "f2", func(currentFine *models.Fine) (*models.Fine, error) {
+		t.Error("modify must not be called for a missing fine")
+		return currentFine, nil
+	})
+	assert.ErrorIs(t, err, dao.ErrFineNotFound)
+
+	t.Log("UpdateAtomically leaves the fine untouched when modify fails")
+	modifyErr := errors.New("arbitrary error")
+	_, err = fineDAO.UpdateAtomically(ctx, "f1", func(currentFine *models.Fine) (*models.Fine, error) {
+		currentFine.State = utils.ToPtr("paid")
+		return nil, modifyErr
+	})
+	assert.ErrorIs(t, err, modifyErr)
+
+	retrievedFine, err := fineDAO.Read(ctx, "f1")
+	assert.Nil(t, err)
+	assert.Equal(t, "outstanding", *retrievedFine.State)
+
+	t.Log("UpdateAtomically applies modify")
+	updatedFine, err := fineDAO.UpdateAtomically(ctx, "f1", func(currentFine *models.Fine) (*models.Fine, error) {
+		assert.Equal(t, newOutstandingFine("f1", "01", arbitraryTime), currentFine)
+
+		currentFine.State = utils.ToPtr("waived")
+		currentFine.TimeUpdated = utils.ToPtr(arbitraryTime.Add(time.Hour))
+		return currentFine, nil
+	})
+	assert.Nil(t, err)
+
+	expectedFine := newOutstandingFine("f1", "01", arbitraryTime)
+	expectedFine.State = utils.ToPtr("waived")
+	expectedFine.TimeUpdated = utils.ToPtr(arbitraryTime.Add(time.Hour))
+	assert.Equal(t, expectedFine, updatedFine)
+
+	retrievedFine, err = fineDAO.Read(ctx, "f1")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedFine, retrievedFine)
+}
+
+func testUpdateAtomicallyWithFines(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	bookDAO := daoFactory.BookDAO()
+	fineDAO := daoFactory.FineDAO()
+
+	mustCreate(t, bookDAO, newAvailableBook("00001"))
+	mustCreateFine(t, fineDAO, newOutstandingFine("f1", "01", arbitraryTime))
+
+	t.Log("The fines created by modify are stored along with the book, and can be read back in the same operation")
+	updatedBook, err := bookDAO.UpdateAtomicallyWithFines(ctx, "00001", func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		if err := fines.Create(newOutstandingFine("f2", "01", arbitraryTime.Add(time.Hour))); err != nil {
+			return nil, err
+		}
+
+		customerFines, err := fines.ReadByCustomer("01")
+		if err != nil {
+			return nil, err
+		}
+		assert.Equal(t, []string{"f1", "f2"}, fineIDsOf(customerFines))
+
+		*currentBook.State = "checked-out"
+		currentBook.CheckedOutCustomerID = utils.ToPtr("01")
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), updatedBook.Version)
+
+	customerFines, err := fineDAO.ReadByCustomer(ctx, "01")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"f1", "f2"}, fineIDsOf(customerFines))
+
+	t.Log("The fines created by modify are stored even when the book is unchanged")
+	_, err = bookDAO.UpdateAtomicallyWithFines(ctx, "00001", func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		return currentBook, fines.Create(newOutstandingFine("f3", "02", arbitraryTime))
+	})
+	assert.Nil(t, err)
+
+	retrievedFine, err := fineDAO.Read(ctx, "f3")
+	assert.Nil(t, err)
+	assert.NotNil(t, retrievedFine)
+
+	t.Log("Neither the book nor the fines are stored when modify fails")
+	modifyErr := errors.New("arbitrary error")
+	_, err = bookDAO.UpdateAtomicallyWithFines(ctx, "00001", func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		if err := fines.Create(newOutstandingFine("f4", "01", arbitraryTime)); err != nil {
+			return nil, err
+		}
+
+		*currentBook.State = "available"
+		currentBook.CheckedOutCustomerID = nil
+		return nil, modifyErr
+	})
+	assert.ErrorIs(t, err, modifyErr)
+
+	retrievedFine, err = fineDAO.Read(ctx, "f4")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedFine)
+
+	retrievedBook, err := bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "checked-out", *retrievedBook.State)
+	assert.Equal(t, int64(2), retrievedBook.Version)
+
+	t.Log("Create returns ErrFineAlreadyExists for an ID in use, and the book is left untouched")
+	_, err = bookDAO.UpdateAtomicallyWithFines(ctx, "00001", func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		*currentBook.State = "available"
+		currentBook.CheckedOutCustomerID = nil
+		return currentBook, fines.Create(newOutstandingFine("f1", "01", arbitraryTime))
+	})
+	assert.ErrorIs(t, err, dao.ErrFineAlreadyExists)
+
+	retrievedBook, err = bookDAO.Read(ctx, "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "checked-out", *retrievedBook.State)
+	assert.Equal(t, int64(2), retrievedBook.Version)
+
+	t.Log("UpdateAtomicallyWithFines returns ErrBookNotFound for a missing book")
+	_, err = bookDAO.UpdateAtomicallyWithFines(ctx, "99999", func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		t.Error("modify must not be called for a missing book")
+		return currentBook, nil
+	})
+	assert.ErrorIs(t, err, dao.ErrBookNotFound)
+}
+
+func testFineUpdateAtomicallyConcurrent(t *testing.T, daoFactory dao.DAOFactory) {
+	ctx := context.Background()
+	fineDAO := daoFactory.FineDAO()
+
+	mustCreateFine(t, fineDAO, newOutstandingFine("f1", "01", arbitraryTime))
+
+	// Every worker pays the fine if it is still outstanding. If two workers could read it at the same time, it would be paid more than once
+	numberOfWorkers := 20
+	alreadySettledErr := errors.New("already settled")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	paidCount := 0
+	for worker := 0; worker < numberOfWorkers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := fineDAO.UpdateAtomically(ctx, "f1", func(currentFine *models.Fine) (*models.Fine, error) {
+				if *currentFine.State != "outstanding" {
+					return nil, alreadySettledErr
+				}
+				currentFine.State = utils.ToPtr("paid")
+				currentFine.TimeUpdated = utils.ToPtr(arbitraryTime.Add(time.Hour))
+				return currentFine, nil
+			})
+
+			if err == nil {
+				mu.Lock()
+				paidCount++
+				mu.Unlock()
+			} else if !errors.Is(err, alreadySettledErr) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, paidCount)
+}
+
+// checkFineContextErrors calls every FineDAO method with ctx, which must be done, and expects each call to fail with expectedErr and leave the fines untouched
+func checkFineContextErrors(t *testing.T, daoFactory dao.DAOFactory, ctx context.Context, expectedErr error) {
+	fineDAO := daoFactory.FineDAO()
+	mustCreateFine(t, fineDAO, newOutstandingFine("f1", "01", arbitraryTime))
+
+	assert.ErrorIs(t, fineDAO.Create(ctx, newOutstandingFine("f2", "01", arbitraryTime)), expectedErr)
+
+	_, err := fineDAO.Read(ctx, "f1")
+	assert.ErrorIs(t, err, expectedErr)
+
+	_, err = fineDAO.ReadByCustomer(ctx, "01")
+	assert.ErrorIs(t, err, expectedErr)
+
+	_, err = fineDAO.UpdateAtomically(ctx, "f1", func(currentFine *models.Fine) (*models.Fine, error) {
+		currentFine.State = utils.ToPtr("paid")
+		return currentFine, nil
+	})
+	assert.ErrorIs(t, err, expectedErr)
+
+	customerFines, err := fineDAO.ReadByCustomer(context.Background(), "01")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"f1"}, fineIDsOf(customerFines))
+	assert.Equal(t, "outstanding", *customerFines[0].State)
+}
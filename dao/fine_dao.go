@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"example/library_project/models"
+
+	"context"
+)
+
+type FineDAO interface {
+	// Every method takes the context of the request it serves, and gives up with an error wrapping ctx.Err() once it is done, like the methods of BookDAO.
+	// Every implementation stores times with NormalizeTime, and the fines passed to Create are normalized in place to match what is stored.
+	// The behavior of every implementation is checked by the conformance tests in the daotest package
+
+	// Create stores the new fine. It returns ErrFineAlreadyExists if the ID is in use
+	Create(ctx context.Context, newFine *models.Fine) error
+
+	// Read returns nil if there is no fine with the given ID
+	Read(ctx context.Context, id string) (*models.Fine, error)
+
+	// ReadByCustomer returns every fine of the customer, whatever its state, sorted by the time it was charged and then by ID
+	ReadByCustomer(ctx context.Context, customerID string) ([]*models.Fine, error)
+
+	// UpdateAtomically reads the fine with the given ID, passes it to modify, and stores the fine returned by modify, as a single atomic operation.
+	// No other update to the same fine can happen in between the read and the write, so a fine cannot be both paid and waived. The returned fine is the fine as stored.
+	// It returns ErrFineNotFound if the fine does not exist, and any error returned by modify unchanged
+	UpdateAtomically(ctx context.Context, id string, modify func(currentFine *models.Fine) (*models.Fine, error)) (*models.Fine, error)
+}
+
+// FineTx gives the modify function of BookDAO.UpdateAtomicallyWithFines access to the fines, within the atomic operation that updates the book.
+// Its methods take the context of that operation
+type FineTx interface {
+	// ReadByCustomer returns every fine of the customer like FineDAO.ReadByCustomer, including the fines created earlier in the same operation
+	ReadByCustomer(customerID string) ([]*models.Fine, error)
+
+	// Create stores the new fine like FineDAO.Create. The fine is discarded if modify then fails or the book cannot be stored.
+	// modify must give up with the error Create returns, since some backends cannot go on with a transaction after a failed statement
+	Create(newFine *models.Fine) error
+}
@@ -0,0 +1,50 @@
+package dao
+
+import (
+	"example/library_project/models"
+
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalHoldQueue encodes the hold queue of a book as a JSON array, which is how the SQL DAOs store it in a single column.
+// An empty queue is stored as NULL, so that books nobody is waiting for look the same whichever way their queue was emptied
+func MarshalHoldQueue(queue []string) (*string, error) {
+	if len(queue) == 0 {
+		return nil, nil
+	}
+
+	encodedQueue, err := json.Marshal(queue)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding hold queue: %w", err)
+	}
+
+	storedQueue := string(encodedQueue)
+	return &storedQueue, nil
+}
+
+// UnmarshalHoldQueue decodes a hold queue stored by MarshalHoldQueue. NULL and the empty array both decode to a nil queue
+func UnmarshalHoldQueue(storedQueue sql.NullString) ([]string, error) {
+	if !storedQueue.Valid {
+		return nil, nil
+	}
+
+	var queue []string
+	if err := json.Unmarshal([]byte(storedQueue.String), &queue); err != nil {
+		return nil, fmt.Errorf("error decoding hold queue: %w", err)
+	}
+
+	if len(queue) == 0 {
+		return nil, nil
+	}
+
+	return queue, nil
+}
+
+// NormalizeHoldQueue sets an empty hold queue of the book to nil, which is how every BookDAO returns it
+func NormalizeHoldQueue(book *models.Book) {
+	if len(book.HoldQueue) == 0 {
+		book.HoldQueue = nil
+	}
+}
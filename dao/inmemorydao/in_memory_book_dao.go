@@ -1,50 +1,315 @@
 package inmemorydao
 
 import (
+	"example/library_project/dao"
 	"example/library_project/models"
+
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
 )
 
+// InMemoryBookDAO stores books in a map. It is safe for concurrent use by multiple goroutines, as gin serves each request on its own goroutine.
+// Like a real database, it never shares its stored books with callers: books are copied on the way in and on the way out,
+// so only a successful Create or Update changes what is stored.
+// Nothing it does blocks for long, so it only checks that the context is still live before touching the map
 type InMemoryBookDAO struct {
 	Books map[string]*models.Book
+
+	// mu guards Books. Readers take the read lock, while Create, Update and Delete take the write lock
+	mu *sync.RWMutex
+
+	// Fines and finesMu are those of the InMemoryFineDAO of the same factory, so that UpdateAtomicallyWithFines can read and create fines while it holds the book.
+	// finesMu is always locked after mu
+	Fines map[string]*models.Fine
+	finesMu *sync.RWMutex
+}
+
+// inMemoryFineTx creates fines straight into the map, and remembers their IDs so that they can be removed if the update fails
+type inMemoryFineTx struct {
+	fines map[string]*models.Fine
+	created []string
+}
+
+func (tx *inMemoryFineTx) ReadByCustomer(customerID string) ([]*models.Fine, error) {
+	return finesOfCustomer(tx.fines, customerID), nil
 }
 
-func (d *InMemoryBookDAO) Create(newBook *models.Book) error {
-	d.Books[*newBook.ISBN] = newBook
+func (tx *inMemoryFineTx) Create(newFine *models.Fine) error {
+	if err := createFine(tx.fines, newFine); err != nil {
+		return err
+	}
+
+	tx.created = append(tx.created, *newFine.ID)
 	return nil
 }
 
-func (d *InMemoryBookDAO) Delete(book *models.Book) error {
+// rollback removes the fines created through tx
+func (tx *inMemoryFineTx) rollback() {
+	for _, id := range tx.created {
+		delete(tx.fines, id)
+	}
+}
+
+func (d *InMemoryBookDAO) Create(ctx context.Context, newBook *models.Book) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := d.Books[*newBook.ISBN]; ok {
+		return dao.ErrBookAlreadyExists
+	}
+
+	newBook.Version = 1
+	dao.NormalizeBookTimes(newBook)
+	dao.NormalizeHoldQueue(newBook)
+	d.Books[*newBook.ISBN] = newBook.Copy()
+	return nil
+}
+
+func (d *InMemoryBookDAO) Delete(ctx context.Context, book *models.Book) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if book.Version != 0 {
+		currentBook, ok := d.Books[*book.ISBN]
+		if !ok || currentBook.Version != book.Version {
+			return dao.ErrVersionMismatch
+		}
+	}
+
 	delete(d.Books, *book.ISBN)
 	return nil
 }
 
-func (d *InMemoryBookDAO) Update(book *models.Book) error {
-	d.Books[*book.ISBN] = book
+func (d *InMemoryBookDAO) Update(ctx context.Context, book *models.Book) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	currentBook, ok := d.Books[*book.ISBN]
+	if !ok {
+		return dao.ErrBookNotFound
+	}
+
+	book.Version = currentBook.Version + 1
+	dao.NormalizeBookTimes(book)
+	dao.NormalizeHoldQueue(book)
+	d.Books[*book.ISBN] = book.Copy()
 	return nil
 }
 
-func (d *InMemoryBookDAO) Read(isbn string) (*models.Book, error) {
+// UpdateAtomically is UpdateAtomicallyWithFines for a modify that has no use for the fines
+func (d *InMemoryBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	return d.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, _ dao.FineTx) (*models.Book, error) {
+		return modify(currentBook)
+	})
+}
+
+// UpdateAtomicallyWithFines holds the write locks of the books and of the fines for the whole read-modify-write, and removes the fines created by modify if the update fails
+func (d *InMemoryBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.finesMu.Lock()
+	defer d.finesMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	currentBook, ok := d.Books[isbn]
+	if !ok {
+		return nil, dao.ErrBookNotFound
+	}
+
+	fineTx := &inMemoryFineTx{fines: d.Fines, created: nil}
+	updatedBook, err := modify(currentBook.Copy(), fineTx)
+	if err != nil {
+		fineTx.rollback()
+		return nil, err
+	}
+
+	if updatedBook == nil || updatedBook.ISBN == nil || *updatedBook.ISBN != isbn {
+		fineTx.rollback()
+		return nil, errors.New("error updating book: modify must return the book it was given")
+	}
+
+	// Only bump the version if modify changed something, so that a no-op update does not invalidate the client's ETag
+	updatedBook.Version = currentBook.Version
+	dao.NormalizeBookTimes(updatedBook)
+	dao.NormalizeHoldQueue(updatedBook)
+	if reflect.DeepEqual(updatedBook, currentBook) {
+		return updatedBook, nil
+	}
+
+	updatedBook.Version = currentBook.Version + 1
+	d.Books[isbn] = updatedBook.Copy()
+	return updatedBook, nil
+}
+
+func (d *InMemoryBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	retrievedBook, ok := d.Books[isbn] // in the future, this could be a call to a database
 
 	// For scalability, we can add a database connection here. 
 	// If there is an error connecting to the database, then we will return: nil, InternalServerError
 
 	if ok {
-		return retrievedBook, nil
+		return retrievedBook.Copy(), nil
 	} else {
 		return nil, nil
 	}
 }
 
-func (d *InMemoryBookDAO) ReadAll() ([]*models.Book, error) {	
-	all_books := make([]*models.Book, 0)
+func (d *InMemoryBookDAO) ReadAll(ctx context.Context) ([]*models.Book, error) {	
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	all_books := make([]*models.Book, 0, len(d.Books))
 
 	// For scalability, we can add a database connection here. 
 	// If there is an error connecting to the database, then we will return: nil, InternalServerError
 
 	for _, currentBook := range d.Books {
-		all_books = append(all_books, currentBook)
+		all_books = append(all_books, currentBook.Copy())
 	}
 
 	return all_books, nil
-}
\ No newline at end of file
+}
+
+func (d *InMemoryBookDAO) Query(ctx context.Context, query dao.BookQuery) ([]*models.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// comesAfterKey reports whether the book comes strictly after the key of the query in its order
+	comesAfterKey := func(book *models.Book) bool {
+		if query.After == nil {
+			return true
+		}
+
+		keyBook := &models.Book{ISBN: &query.After.ISBN, TimeCreated: query.After.SortTime, TimeUpdated: query.After.SortTime, TimeDue: query.After.SortTime}
+		if query.SortDescending {
+			return lessForQuery(book, keyBook, query.SortBy)
+		}
+		return lessForQuery(keyBook, book, query.SortBy)
+	}
+
+	d.mu.RLock()
+	matchingBooks := make([]*models.Book, 0)
+	for _, currentBook := range d.Books {
+		if matchesQuery(currentBook, query) && comesAfterKey(currentBook) {
+			matchingBooks = append(matchingBooks, currentBook.Copy())
+		}
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(matchingBooks, func(i, j int) bool {
+		if query.SortDescending {
+			return lessForQuery(matchingBooks[j], matchingBooks[i], query.SortBy)
+		}
+		return lessForQuery(matchingBooks[i], matchingBooks[j], query.SortBy)
+	})
+
+	if query.Limit > 0 && query.Limit < len(matchingBooks) {
+		matchingBooks = matchingBooks[:query.Limit]
+	}
+
+	return matchingBooks, nil
+}
+
+// matchesQuery reports whether the book satisfies every filter of the query
+func matchesQuery(book *models.Book, query dao.BookQuery) bool {
+	if query.State != nil && *book.State != *query.State {
+		return false
+	}
+
+	if query.CustomerID != nil {
+		isOnHoldCustomer := book.OnHoldCustomerID != nil && *book.OnHoldCustomerID == *query.CustomerID
+		isCheckedOutCustomer := book.CheckedOutCustomerID != nil && *book.CheckedOutCustomerID == *query.CustomerID
+		if !isOnHoldCustomer && !isCheckedOutCustomer {
+			return false
+		}
+	}
+
+	if query.CreatedAfter != nil && !book.TimeCreated.After(*query.CreatedAfter) {
+		return false
+	}
+
+	if query.CreatedBefore != nil && !book.TimeCreated.Before(*query.CreatedBefore) {
+		return false
+	}
+
+	if query.UpdatedAfter != nil && (book.TimeUpdated == nil || !book.TimeUpdated.After(*query.UpdatedAfter)) {
+		return false
+	}
+
+	if query.DueBefore != nil && (book.TimeDue == nil || !book.TimeDue.Before(*query.DueBefore)) {
+		return false
+	}
+
+	if query.HoldExpiryBefore != nil && (book.HoldExpiry == nil || !book.HoldExpiry.Before(*query.HoldExpiryBefore)) {
+		return false
+	}
+
+	return true
+}
+
+// lessForQuery orders books by the sort field, then by ISBN. A book without the sort time sorts before one with it, mirroring how SQL orders NULLs
+func lessForQuery(a *models.Book, b *models.Book, sortBy string) bool {
+	switch sortBy {
+	case dao.SortByTimeCreated:
+		if !a.TimeCreated.Equal(*b.TimeCreated) {
+			return a.TimeCreated.Before(*b.TimeCreated)
+		}
+	case dao.SortByTimeUpdated:
+		if less, ok := lessForNullableTime(a.TimeUpdated, b.TimeUpdated); ok {
+			return less
+		}
+	case dao.SortByTimeDue:
+		if less, ok := lessForNullableTime(a.TimeDue, b.TimeDue); ok {
+			return less
+		}
+	}
+
+	return *a.ISBN < *b.ISBN
+}
+
+// lessForNullableTime compares two optional times with nil first. ok is false when the times are equal, so that the caller falls back to the ISBN
+func lessForNullableTime(a *time.Time, b *time.Time) (less bool, ok bool) {
+	if a == nil && b != nil {
+		return true, true
+	}
+	if a != nil && b == nil {
+		return false, true
+	}
+	if a != nil && b != nil && !a.Equal(*b) {
+		return a.Before(*b), true
+	}
+
+	return false, false
+}
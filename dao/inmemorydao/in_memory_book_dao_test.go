@@ -0,0 +1,320 @@
+package inmemorydao
+
+import (
+	"example/library_project/dao"
+	"example/library_project/dao/daotest"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInMemoryDAOFactory_Conformance runs the shared DAO contract against a fresh in-memory store per test
+func TestInMemoryDAOFactory_Conformance(t *testing.T) {
+	daotest.RunDAOFactoryTests(t, func(t *testing.T) dao.DAOFactory {
+		return NewInMemoryDAOFactory()
+	})
+}
+
+// TestInMemoryBookDAO_ConcurrentAccess calls every BookDAO method from many goroutines at once. It is intended to be run with the race detector (go test -race)
+func TestInMemoryBookDAO_ConcurrentAccess(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := NewInMemoryDAOFactory()
+	bookDAO := daoFactory.BookDAO()
+
+	numberOfWorkers := 16
+	numberOfIterations := 200
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < numberOfWorkers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			// A second DAO from the same factory must share the same lock as the first
+			workerDAO := daoFactory.BookDAO()
+
+			for i := 0; i < numberOfIterations; i++ {
+				isbn := fmt.Sprintf("%02d-%03d", worker, i%10)
+
+				book := &models.Book{
+					ISBN: utils.ToPtr(isbn),
+					State: utils.ToPtr("available"),
+					OnHoldCustomerID: nil,
+					CheckedOutCustomerID: nil,
+					TimeCreated: utils.ToPtr(arbitraryTime),
+					TimeUpdated: nil,
+				}
+
+				// The ISBN may still be in use from an earlier iteration that did not delete it
+				if err := workerDAO.Create(context.Background(), book); err != nil && !errors.Is(err, dao.ErrBookAlreadyExists) {
+					t.Error(err)
+				}
+
+				if _, err := bookDAO.Read(context.Background(), isbn); err != nil {
+					t.Error(err)
+				}
+
+				if _, err := bookDAO.ReadAll(context.Background()); err != nil {
+					t.Error(err)
+				}
+
+				updatedBook := &models.Book{
+					ISBN: utils.ToPtr(isbn),
+					State: utils.ToPtr("checked-out"),
+					OnHoldCustomerID: nil,
+					CheckedOutCustomerID: utils.ToPtr("01"),
+					TimeCreated: utils.ToPtr(arbitraryTime),
+					TimeUpdated: utils.ToPtr(arbitraryTime),
+				}
+
+				if err := workerDAO.Update(context.Background(), updatedBook); err != nil {
+					t.Error(err)
+				}
+
+				if i%3 == 0 {
+					assert.Nil(t, bookDAO.Delete(context.Background(), updatedBook))
+				}
+			}
+		}(worker)
+	}
+
+	wg.Wait()
+
+	allBooks, err := bookDAO.ReadAll(context.Background())
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, len(allBooks), numberOfWorkers*10)
+}
+
+// TestInMemoryBookDAO_ReadAllDuringCreate ensures ReadAll can iterate over the books while other goroutines are adding new ones
+func TestInMemoryBookDAO_ReadAllDuringCreate(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := NewInMemoryDAOFactory()
+	bookDAO := daoFactory.BookDAO()
+
+	numberOfBooks := 500
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < numberOfBooks; i++ {
+			book := &models.Book{
+				ISBN: utils.ToPtr(fmt.Sprintf("%05d", i)),
+				State: utils.ToPtr("available"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: nil,
+				TimeCreated: utils.ToPtr(arbitraryTime),
+				TimeUpdated: nil,
+			}
+			assert.Nil(t, bookDAO.Create(context.Background(), book))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		previousCount := 0
+		for previousCount < numberOfBooks {
+			allBooks, err := bookDAO.ReadAll(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			// Books are only ever added, so the count must never go down
+			assert.GreaterOrEqual(t, len(allBooks), previousCount)
+			previousCount = len(allBooks)
+		}
+	}()
+
+	wg.Wait()
+
+	allBooks, err := bookDAO.ReadAll(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, allBooks, numberOfBooks)
+}
+
+// TestInMemoryBookDAO_DefensiveCopies ensures that modifying a book passed to or returned by the DAO does not modify the stored book
+func TestInMemoryBookDAO_DefensiveCopies(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := NewInMemoryDAOFactory()
+	bookDAO := daoFactory.BookDAO()
+
+	newBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	}
+
+	expectedBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+		Version: 1,
+	}
+
+	assert.Nil(t, bookDAO.Create(context.Background(), newBook))
+
+	t.Log("Modifying the book after Create")
+	*newBook.State = "checked-out"
+	newBook.CheckedOutCustomerID = utils.ToPtr("01")
+
+	retrievedBook, err := bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedBook, retrievedBook)
+
+	t.Log("Modifying the book returned by Read")
+	*retrievedBook.State = "on-hold"
+	retrievedBook.OnHoldCustomerID = utils.ToPtr("02")
+
+	retrievedAgain, err := bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedBook, retrievedAgain)
+
+	t.Log("Modifying the books returned by ReadAll")
+	allBooks, err := bookDAO.ReadAll(context.Background())
+	assert.Nil(t, err)
+	*allBooks[0].State = "checked-out"
+
+	retrievedAgain, err = bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedBook, retrievedAgain)
+
+	t.Log("Modifying the book after Update")
+	updatedBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("01"),
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: utils.ToPtr(arbitraryTime),
+	}
+	assert.Nil(t, bookDAO.Update(context.Background(), updatedBook))
+	*updatedBook.State = "available"
+
+	retrievedAgain, err = bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "checked-out", *retrievedAgain.State)
+}
+
+func TestInMemoryBookDAO_UpdateAtomically(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := NewInMemoryDAOFactory()
+	bookDAO := daoFactory.BookDAO()
+
+	assert.Nil(t, bookDAO.Create(context.Background(), &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	}))
+
+	t.Log("Book not found")
+	_, err := bookDAO.UpdateAtomically(context.Background(), "00002", func(currentBook *models.Book) (*models.Book, error) {
+		t.Error("modify must not be called for a missing book")
+		return currentBook, nil
+	})
+	assert.ErrorIs(t, err, dao.ErrBookNotFound)
+
+	t.Log("An error from modify leaves the stored book untouched")
+	modifyErr := errors.New("arbitrary error")
+	_, err = bookDAO.UpdateAtomically(context.Background(), "00001", func(currentBook *models.Book) (*models.Book, error) {
+		*currentBook.State = "checked-out"
+		return nil, modifyErr
+	})
+	assert.ErrorIs(t, err, modifyErr)
+
+	storedBook, err := bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "available", *storedBook.State)
+
+	t.Log("Concurrent increments are never lost")
+	numberOfWorkers := 50
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < numberOfWorkers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := bookDAO.UpdateAtomically(context.Background(), "00001", func(currentBook *models.Book) (*models.Book, error) {
+				// Use TimeUpdated as a counter, one minute per update
+				if currentBook.TimeUpdated == nil {
+					currentBook.TimeUpdated = utils.ToPtr(arbitraryTime)
+				}
+				currentBook.TimeUpdated = utils.ToPtr(currentBook.TimeUpdated.Add(time.Minute))
+				return currentBook, nil
+			})
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	storedBook, err = bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, arbitraryTime.Add(time.Duration(numberOfWorkers)*time.Minute), *storedBook.TimeUpdated)
+}
+
+func TestInMemoryBookDAO_Version(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := NewInMemoryDAOFactory()
+	bookDAO := daoFactory.BookDAO()
+
+	newBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	}
+
+	t.Log("Create starts at version 1")
+	assert.Nil(t, bookDAO.Create(context.Background(), newBook))
+	assert.Equal(t, int64(1), newBook.Version)
+
+	t.Log("Update increments the version")
+	newBook.TimeUpdated = utils.ToPtr(arbitraryTime)
+	assert.Nil(t, bookDAO.Update(context.Background(), newBook))
+	assert.Equal(t, int64(2), newBook.Version)
+
+	t.Log("UpdateAtomically does not increment the version when nothing changed")
+	unchangedBook, err := bookDAO.UpdateAtomically(context.Background(), "00001", func(currentBook *models.Book) (*models.Book, error) {
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), unchangedBook.Version)
+
+	t.Log("UpdateAtomically increments the version when the book changed")
+	changedBook, err := bookDAO.UpdateAtomically(context.Background(), "00001", func(currentBook *models.Book) (*models.Book, error) {
+		*currentBook.State = "on-hold"
+		currentBook.OnHoldCustomerID = utils.ToPtr("01")
+		return currentBook, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), changedBook.Version)
+
+	storedBook, err := bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), storedBook.Version)
+}
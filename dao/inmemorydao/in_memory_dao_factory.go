@@ -3,21 +3,44 @@ package inmemorydao
 import (
 	"example/library_project/dao"
 	"example/library_project/models"
+
+	"context"
+	"sync"
 )
 
 type InMemoryDAOFactory struct {
 	Books map[string]*models.Book
+	Fines map[string]*models.Fine
+
+	// mu guards Books. It is shared with every InMemoryBookDAO handed out by the factory, since they all operate on the same map
+	mu *sync.RWMutex
+
+	// finesMu guards Fines in the same way, for every InMemoryFineDAO handed out by the factory
+	finesMu *sync.RWMutex
 }
 
 func NewInMemoryDAOFactory() *InMemoryDAOFactory {
 	return &InMemoryDAOFactory{
 		Books: map[string]*models.Book{},
+		Fines: map[string]*models.Fine{},
+		mu: &sync.RWMutex{},
+		finesMu: &sync.RWMutex{},
 	}
 }
 
 func (f *InMemoryDAOFactory) BookDAO() dao.BookDAO {
 	return &InMemoryBookDAO{
 		Books: f.Books,
+		mu: f.mu,
+		Fines: f.Fines,
+		finesMu: f.finesMu,
+	}
+}
+
+func (f *InMemoryDAOFactory) FineDAO() dao.FineDAO {
+	return &InMemoryFineDAO{
+		Fines: f.Fines,
+		mu: f.finesMu,
 	}
 }
 
@@ -29,6 +52,26 @@ func (f *InMemoryDAOFactory) Close() error {
 	return nil
 }
 
+// Ping always succeeds, since the books and fines live in this process
+func (f *InMemoryDAOFactory) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
 func (f *InMemoryDAOFactory) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Empty the maps in place, since every DAO from this factory refers to them
+	for isbn := range f.Books {
+		delete(f.Books, isbn)
+	}
+
+	f.finesMu.Lock()
+	defer f.finesMu.Unlock()
+
+	for id := range f.Fines {
+		delete(f.Fines, id)
+	}
+
 	return nil
-}
\ No newline at end of file
+}
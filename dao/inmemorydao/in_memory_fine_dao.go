@@ -0,0 +1,115 @@
+package inmemorydao
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// InMemoryFineDAO stores fines in a map. Like InMemoryBookDAO, it is safe for concurrent use and copies fines on the way in and on the way out
+type InMemoryFineDAO struct {
+	Fines map[string]*models.Fine
+
+	// mu guards Fines. Readers take the read lock, while Create and UpdateAtomically take the write lock
+	mu *sync.RWMutex
+}
+
+func (d *InMemoryFineDAO) Create(ctx context.Context, newFine *models.Fine) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return createFine(d.Fines, newFine)
+}
+
+// createFine adds newFine to fines. The caller must hold the write lock of fines
+func createFine(fines map[string]*models.Fine, newFine *models.Fine) error {
+	if _, ok := fines[*newFine.ID]; ok {
+		return dao.ErrFineAlreadyExists
+	}
+
+	dao.NormalizeFineTimes(newFine)
+	fines[*newFine.ID] = newFine.Copy()
+	return nil
+}
+
+func (d *InMemoryFineDAO) Read(ctx context.Context, id string) (*models.Fine, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	retrievedFine, ok := d.Fines[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return retrievedFine.Copy(), nil
+}
+
+func (d *InMemoryFineDAO) ReadByCustomer(ctx context.Context, customerID string) ([]*models.Fine, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return finesOfCustomer(d.Fines, customerID), nil
+}
+
+// finesOfCustomer returns copies of the fines of the customer, sorted by the time they were charged and then by ID. The caller must hold a lock of fines
+func finesOfCustomer(fines map[string]*models.Fine, customerID string) []*models.Fine {
+	customerFines := make([]*models.Fine, 0)
+	for _, currentFine := range fines {
+		if *currentFine.CustomerID == customerID {
+			customerFines = append(customerFines, currentFine.Copy())
+		}
+	}
+
+	sort.Slice(customerFines, func(i, j int) bool {
+		if !customerFines[i].TimeCreated.Equal(*customerFines[j].TimeCreated) {
+			return customerFines[i].TimeCreated.Before(*customerFines[j].TimeCreated)
+		}
+		return *customerFines[i].ID < *customerFines[j].ID
+	})
+
+	return customerFines
+}
+
+// UpdateAtomically holds the write lock for the whole read-modify-write, so concurrent updates to the same fine are applied one after the other
+func (d *InMemoryFineDAO) UpdateAtomically(ctx context.Context, id string, modify func(currentFine *models.Fine) (*models.Fine, error)) (*models.Fine, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	currentFine, ok := d.Fines[id]
+	if !ok {
+		return nil, dao.ErrFineNotFound
+	}
+
+	updatedFine, err := modify(currentFine.Copy())
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedFine == nil || updatedFine.ID == nil || *updatedFine.ID != id {
+		return nil, errors.New("error updating fine: modify must return the fine it was given")
+	}
+
+	dao.NormalizeFineTimes(updatedFine)
+	d.Fines[id] = updatedFine.Copy()
+	return updatedFine, nil
+}
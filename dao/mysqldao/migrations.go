@@ -0,0 +1,354 @@
+package mysqldao
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches names such as "0001_create_books.up.sql"
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockName is the name of the MySQL user lock held while migrating, so that several instances starting at once do not migrate concurrently
+const migrationLockName = "library_project.schema_migrations"
+
+// migrationLockTimeout is how long, in seconds, to wait for another instance to finish migrating
+const migrationLockTimeout = 60
+
+// ErrSchemaOutOfDate is returned by Open when MigrationModeVerify is selected and the database is missing migrations
+var ErrSchemaOutOfDate = errors.New("database schema is out of date")
+
+// MigrationMode selects what Open does with the schema migrations
+type MigrationMode int
+
+const (
+	// MigrationModeApply applies pending migrations on Open. It is the default
+	MigrationModeApply MigrationMode = iota
+	// MigrationModeVerify makes Open fail with ErrSchemaOutOfDate when migrations are pending, for deployments that migrate as a separate step
+	MigrationModeVerify
+	// MigrationModeSkip leaves the schema alone on Open, which is used by the migrate command
+	MigrationModeSkip
+)
+
+// migration is one versioned schema change, read from the pair of files <version>_<name>.up.sql and <version>_<name>.down.sql
+type migration struct {
+	version int64
+	name string
+	up []string
+	down []string
+}
+
+// MigrationStatus describes one known migration and whether it has been applied to the database
+type MigrationStatus struct {
+	Version int64
+	Name string
+	Applied bool
+}
+
+// loadMigrations parses the embedded migration files, ordered by version
+func loadMigrations(files fs.FS) ([]migration, error) {
+	fileNames, err := fs.Glob(files, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	migrationsByVersion := make(map[int64]*migration)
+	for _, fileName := range fileNames {
+		baseName := strings.TrimPrefix(fileName, "migrations/")
+		matches := migrationFileName.FindStringSubmatch(baseName)
+		if matches == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", baseName)
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration version in %q", baseName)
+		}
+
+		contents, err := fs.ReadFile(files, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", baseName, err)
+		}
+
+		currentMigration, ok := migrationsByVersion[version]
+		if !ok {
+			currentMigration = &migration{version: version, name: matches[2]}
+			migrationsByVersion[version] = currentMigration
+		} else if currentMigration.name != matches[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, currentMigration.name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			currentMigration.up = splitStatements(string(contents))
+		} else {
+			currentMigration.down = splitStatements(string(contents))
+		}
+	}
+
+	migrations := make([]migration, 0, len(migrationsByVersion))
+	for _, currentMigration := range migrationsByVersion {
+		if len(currentMigration.up) == 0 || len(currentMigration.down) == 0 {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file with at least one statement", currentMigration.version, currentMigration.name)
+		}
+		migrations = append(migrations, *currentMigration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// splitStatements splits the contents of a migration file into statements, which end with a semicolon at the end of a line.
+// Lines starting with "--" are comments. The driver runs a single statement per call unless multiStatements is enabled in the DSN
+func splitStatements(contents string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(contents, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmedLine, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// withMigrationLock runs fn on a single connection while holding the migration lock, after making sure the schema_migrations table exists.
+// MySQL commits schema changes immediately, so the lock rather than a transaction is what keeps migrations from interleaving
+func (f *MySQLDAOFactory) withMigrationLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	// GET_LOCK belongs to the session, so every statement must go through the same connection
+	conn, err := f.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a database connection: %w", err)
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&acquired); err != nil {
+		return fmt.Errorf("failed to acquire the migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for the migration lock held by another connection")
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+
+	createTable := "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at DATETIME(6) NOT NULL)"
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create the schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns the versions recorded in schema_migrations
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		applied[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+// checkUnknownVersions fails when the database has migrations this build does not know about, which means it was migrated by a newer build
+func checkUnknownVersions(migrations []migration, applied map[int64]bool) error {
+	known := make(map[int64]bool, len(migrations))
+	for _, currentMigration := range migrations {
+		known[currentMigration.version] = true
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("database has migration %d applied, which this build does not know about", version)
+		}
+	}
+
+	return nil
+}
+
+// MigrateUp applies every pending migration in order, and returns how many were applied
+func (f *MySQLDAOFactory) MigrateUp() (int, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return 0, err
+	}
+
+	appliedCount := 0
+	err = f.withMigrationLock(func(conn *sql.Conn) error {
+		ctx := context.Background()
+
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := checkUnknownVersions(migrations, applied); err != nil {
+			return err
+		}
+
+		for _, currentMigration := range migrations {
+			if applied[currentMigration.version] {
+				continue
+			}
+
+			for _, statement := range currentMigration.up {
+				if _, err := conn.ExecContext(ctx, statement); err != nil {
+					return fmt.Errorf("failed to apply migration %d_%s: %w", currentMigration.version, currentMigration.name, err)
+				}
+			}
+
+			appliedAt := time.Now().UTC().Format(timeFormat)
+			if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", currentMigration.version, currentMigration.name, appliedAt); err != nil {
+				return fmt.Errorf("failed to record migration %d_%s: %w", currentMigration.version, currentMigration.name, err)
+			}
+
+			slog.Info("applied migration", slog.Int64("version", currentMigration.version), slog.String("name", currentMigration.name))
+			appliedCount++
+		}
+
+		return nil
+	})
+
+	return appliedCount, err
+}
+
+// MigrateDown reverts the most recently applied migrations, at most steps of them, and returns how many were reverted
+func (f *MySQLDAOFactory) MigrateDown(steps int) (int, error) {
+	if steps < 1 {
+		return 0, fmt.Errorf("steps must be at least 1")
+	}
+
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return 0, err
+	}
+
+	revertedCount := 0
+	err = f.withMigrationLock(func(conn *sql.Conn) error {
+		ctx := context.Background()
+
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := checkUnknownVersions(migrations, applied); err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && revertedCount < steps; i-- {
+			currentMigration := migrations[i]
+			if !applied[currentMigration.version] {
+				continue
+			}
+
+			for _, statement := range currentMigration.down {
+				if _, err := conn.ExecContext(ctx, statement); err != nil {
+					return fmt.Errorf("failed to revert migration %d_%s: %w", currentMigration.version, currentMigration.name, err)
+				}
+			}
+
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", currentMigration.version); err != nil {
+				return fmt.Errorf("failed to record reverting migration %d_%s: %w", currentMigration.version, currentMigration.name, err)
+			}
+
+			slog.Info("reverted migration", slog.Int64("version", currentMigration.version), slog.String("name", currentMigration.name))
+			revertedCount++
+		}
+
+		return nil
+	})
+
+	return revertedCount, err
+}
+
+// MigrationStatus lists every known migration in order, and whether it has been applied
+func (f *MySQLDAOFactory) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	err = f.withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(context.Background(), conn)
+		if err != nil {
+			return err
+		}
+
+		if err := checkUnknownVersions(migrations, applied); err != nil {
+			return err
+		}
+
+		for _, currentMigration := range migrations {
+			statuses = append(statuses, MigrationStatus{
+				Version: currentMigration.version,
+				Name: currentMigration.name,
+				Applied: applied[currentMigration.version],
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// verifyMigrations returns ErrSchemaOutOfDate if any known migration has not been applied
+func (f *MySQLDAOFactory) verifyMigrations() error {
+	statuses, err := f.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if !status.Applied {
+			return fmt.Errorf("%w: migration %d_%s has not been applied", ErrSchemaOutOfDate, status.Version, status.Name)
+		}
+	}
+
+	return nil
+}
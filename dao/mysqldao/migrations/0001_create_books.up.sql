@@ -0,0 +1,12 @@
+-- Databases created by hand before migrations existed already have this table, which CREATE TABLE IF NOT EXISTS leaves untouched.
+-- Such a table lacks the Version column and stores times to the second, which 0008_adopt_legacy_books fixes
+CREATE TABLE IF NOT EXISTS Books (
+	ISBN VARCHAR(255) NOT NULL,
+	State VARCHAR(32) NOT NULL,
+	OnHoldCustomerID VARCHAR(255) NULL,
+	CheckedOutCustomerID VARCHAR(255) NULL,
+	TimeCreated DATETIME(6) NOT NULL,
+	TimeUpdated DATETIME(6) NULL,
+	Version BIGINT NOT NULL DEFAULT 1,
+	PRIMARY KEY (ISBN)
+);
@@ -0,0 +1,3 @@
+DROP INDEX BooksTimeUpdatedIndex ON Books;
+DROP INDEX BooksTimeCreatedIndex ON Books;
+DROP INDEX BooksStateIndex ON Books;
@@ -0,0 +1,4 @@
+-- Supports the filters and sort orders of BookDAO.Query
+CREATE INDEX BooksStateIndex ON Books (State, ISBN);
+CREATE INDEX BooksTimeCreatedIndex ON Books (TimeCreated, ISBN);
+CREATE INDEX BooksTimeUpdatedIndex ON Books (TimeUpdated, ISBN);
@@ -0,0 +1,2 @@
+DROP INDEX BooksTimeDueIndex ON Books;
+ALTER TABLE Books DROP COLUMN TimeDue;
@@ -0,0 +1,3 @@
+-- Checked-out books are due back at TimeDue, which the overdue report filters and sorts on
+ALTER TABLE Books ADD COLUMN TimeDue DATETIME(6) NULL AFTER TimeUpdated;
+CREATE INDEX BooksTimeDueIndex ON Books (State, TimeDue, ISBN);
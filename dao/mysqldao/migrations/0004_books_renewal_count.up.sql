@@ -0,0 +1,2 @@
+-- RenewalCount counts the renewals of the current loan, and is NULL while the book is not checked-out
+ALTER TABLE Books ADD COLUMN RenewalCount INT NULL AFTER TimeDue;
@@ -0,0 +1,2 @@
+-- HoldQueue is the JSON array of the customers waiting for the book, in the order they joined, and is NULL while nobody is waiting
+ALTER TABLE Books ADD COLUMN HoldQueue JSON NULL AFTER RenewalCount;
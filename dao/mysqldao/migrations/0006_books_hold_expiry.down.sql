@@ -0,0 +1,2 @@
+DROP INDEX BooksHoldExpiryIndex ON Books;
+ALTER TABLE Books DROP COLUMN HoldExpiry;
@@ -0,0 +1,3 @@
+-- On-hold books must be checked out before HoldExpiry, after which the hold sweeper releases them
+ALTER TABLE Books ADD COLUMN HoldExpiry DATETIME(6) NULL AFTER HoldQueue;
+CREATE INDEX BooksHoldExpiryIndex ON Books (State, HoldExpiry, ISBN);
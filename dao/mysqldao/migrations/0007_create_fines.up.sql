@@ -0,0 +1,13 @@
+-- Fines charged for overdue returns. Customers read their fines as a ledger, oldest first
+CREATE TABLE Fines (
+	ID VARCHAR(255) NOT NULL,
+	CustomerID VARCHAR(255) NOT NULL,
+	ISBN VARCHAR(255) NOT NULL,
+	DaysOverdue INT NOT NULL,
+	Amount BIGINT NOT NULL,
+	State VARCHAR(32) NOT NULL,
+	TimeCreated DATETIME(6) NOT NULL,
+	TimeUpdated DATETIME(6) NULL,
+	PRIMARY KEY (ID)
+);
+CREATE INDEX FinesCustomerIndex ON Fines (CustomerID, TimeCreated, ID);
@@ -0,0 +1,2 @@
+-- The adopted table keeps its Version column and precise times, which the DAO and 0001_create_books rely on
+DO 0;
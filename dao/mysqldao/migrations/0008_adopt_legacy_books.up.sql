@@ -0,0 +1,8 @@
+-- Brings a Books table created by hand before migrations existed up to date with 0001_create_books, and changes nothing on tables created by it.
+-- MySQL has no ADD COLUMN IF NOT EXISTS, so the column is only added through a prepared statement when information_schema lacks it
+SET @addVersion = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE Books ADD COLUMN Version BIGINT NOT NULL DEFAULT 1', 'DO 0') FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'Books' AND COLUMN_NAME = 'Version');
+PREPARE addVersionStatement FROM @addVersion;
+EXECUTE addVersionStatement;
+DEALLOCATE PREPARE addVersionStatement;
+-- Hand-made tables stored times to the second, while the DAO stores them to the microsecond
+ALTER TABLE Books MODIFY COLUMN TimeCreated DATETIME(6) NOT NULL, MODIFY COLUMN TimeUpdated DATETIME(6) NULL;
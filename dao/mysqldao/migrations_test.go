@@ -0,0 +1,150 @@
+package mysqldao
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadMigrations_Embedded ensures the shipped migrations are numbered 1, 2, 3... and each can be reverted
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, migrations)
+
+	for i, currentMigration := range migrations {
+		assert.Equal(t, int64(i+1), currentMigration.version)
+		assert.NotEmpty(t, currentMigration.up)
+		assert.NotEmpty(t, currentMigration.down)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct{
+		description string
+		files fstest.MapFS
+		expectedMigrations []migration
+		expectErr bool
+	}{
+		{
+			description: "Migrations are ordered by version",
+			files: fstest.MapFS{
+				"migrations/0010_second.up.sql": {Data: []byte("CREATE TABLE B (ID INT);")},
+				"migrations/0010_second.down.sql": {Data: []byte("DROP TABLE B;")},
+				"migrations/0002_first.up.sql": {Data: []byte("CREATE TABLE A (ID INT);")},
+				"migrations/0002_first.down.sql": {Data: []byte("DROP TABLE A;")},
+			},
+			expectedMigrations: []migration{
+				{version: 2, name: "first", up: []string{"CREATE TABLE A (ID INT);"}, down: []string{"DROP TABLE A;"}},
+				{version: 10, name: "second", up: []string{"CREATE TABLE B (ID INT);"}, down: []string{"DROP TABLE B;"}},
+			},
+			expectErr: false,
+		},
+		{
+			description: "Missing down file",
+			files: fstest.MapFS{
+				"migrations/0001_first.up.sql": {Data: []byte("CREATE TABLE A (ID INT);")},
+			},
+			expectedMigrations: nil,
+			expectErr: true,
+		},
+		{
+			description: "Version used twice",
+			files: fstest.MapFS{
+				"migrations/0001_first.up.sql": {Data: []byte("CREATE TABLE A (ID INT);")},
+				"migrations/0001_first.down.sql": {Data: []byte("DROP TABLE A;")},
+				"migrations/0001_other.up.sql": {Data: []byte("CREATE TABLE B (ID INT);")},
+				"migrations/0001_other.down.sql": {Data: []byte("DROP TABLE B;")},
+			},
+			expectedMigrations: nil,
+			expectErr: true,
+		},
+		{
+			description: "Unexpected file name",
+			files: fstest.MapFS{
+				"migrations/first.sql": {Data: []byte("CREATE TABLE A (ID INT);")},
+			},
+			expectedMigrations: nil,
+			expectErr: true,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		t.Log(currentTestCase.description)
+
+		migrations, err := loadMigrations(currentTestCase.files)
+		if currentTestCase.expectErr {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, currentTestCase.expectedMigrations, migrations)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	contents := `-- A comment
+CREATE TABLE A (
+	ID INT
+);
+
+CREATE INDEX AIndex ON A (ID);
+DROP TABLE B`
+
+	assert.Equal(t, []string{
+		"CREATE TABLE A (\n\tID INT\n);",
+		"CREATE INDEX AIndex ON A (ID);",
+		"DROP TABLE B",
+	}, splitStatements(contents))
+}
+
+// TestMySQLDAOFactory_Migrations reverts and re-applies every migration against the MySQL database given by LIBRARY_TEST_MYSQL_DSN, and is skipped if it is not set
+func TestMySQLDAOFactory_Migrations(t *testing.T) {
+	dsn := os.Getenv("LIBRARY_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("LIBRARY_TEST_MYSQL_DSN is not set")
+	}
+
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daoFactory := NewMySQLDAOFactoryFromDSN(dsn)
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer daoFactory.Close()
+
+	t.Log("Open applied every migration")
+	assert.Nil(t, daoFactory.verifyMigrations())
+
+	t.Log("Nothing is left to apply")
+	appliedCount, err := daoFactory.MigrateUp()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, appliedCount)
+
+	t.Log("Reverting every migration")
+	revertedCount, err := daoFactory.MigrateDown(len(migrations) + 1)
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), revertedCount)
+	assert.ErrorIs(t, daoFactory.verifyMigrations(), ErrSchemaOutOfDate)
+
+	t.Log("Verifying on Open fails while migrations are pending")
+	verifyingFactory := NewMySQLDAOFactoryFromDSN(dsn)
+	verifyingFactory.MigrationMode = MigrationModeVerify
+	assert.ErrorIs(t, verifyingFactory.Open(), ErrSchemaOutOfDate)
+
+	t.Log("Re-applying every migration")
+	appliedCount, err = daoFactory.MigrateUp()
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), appliedCount)
+
+	statuses, err := daoFactory.MigrationStatus()
+	assert.Nil(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied)
+	}
+}
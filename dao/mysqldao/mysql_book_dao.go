@@ -3,69 +3,311 @@ package mysqldao
 
 import (
 	"database/sql"
+	"example/library_project/dao"
 	"example/library_project/models"
 	"example/library_project/utils"
 
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	// "log"
 
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
+// bookColumns lists the columns of the Books table in the order expected by scanBook
+const bookColumns = "ISBN, State, OnHoldCustomerID, CheckedOutCustomerID, TimeCreated, TimeUpdated, TimeDue, RenewalCount, HoldQueue, HoldExpiry, Version"
+
+// timeFormat is the layout of DATETIME(6) values sent to MySQL, and parseTimeFormat also accepts values without fractional seconds.
+// Times are stored in UTC
+const timeFormat = "2006-01-02 15:04:05.000000"
+const parseTimeFormat = "2006-01-02 15:04:05.999999"
+
+// formatTime converts an optional time to the text sent to the database
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	return utils.ToPtr(dao.NormalizeTime(t).Format(timeFormat))
+}
+
+// nullTime scans a nullable DATETIME column. The driver returns time.Time when the DSN sets parseTime=true, and text otherwise, so both are accepted
+type nullTime struct {
+	Time time.Time
+	Valid bool
+}
+
+func (t *nullTime) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+	case time.Time:
+		t.Time, t.Valid = value.UTC(), true
+	case []byte:
+		return t.parse(string(value))
+	case string:
+		return t.parse(value)
+	default:
+		return fmt.Errorf("error parsing time: unexpected type %T", src)
+	}
+
+	return nil
+}
+
+func (t *nullTime) parse(value string) error {
+	parsedTime, err := time.Parse(parseTimeFormat, value)
+	if err != nil {
+		return fmt.Errorf("error parsing time: %w", err)
+	}
+
+	t.Time, t.Valid = parsedTime, true
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
 type MySQLBookDAO struct {
 	db *sql.DB
 }
 
-func (d *MySQLBookDAO) Create(newBook *models.Book) error {
-	query := "INSERT INTO Books (ISBN, State, OnHoldCustomerID, CheckedOutCustomerID, TimeCreated, TimeUpdated) VALUES (?, ?, ?, ?, ?, ?)"
+func (d *MySQLBookDAO) Create(ctx context.Context, newBook *models.Book) error {
+	query := "INSERT INTO Books (ISBN, State, OnHoldCustomerID, CheckedOutCustomerID, TimeCreated, TimeUpdated, TimeDue, RenewalCount, HoldQueue, HoldExpiry, Version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)"
 
-	timeCreated := utils.ToPtr(newBook.TimeCreated.Format("2006-01-02 15:04:05"))
+	dao.NormalizeBookTimes(newBook)
+	dao.NormalizeHoldQueue(newBook)
 
-	var timeUpdated *string
-	if newBook.TimeUpdated != nil {
-		timeUpdated = utils.ToPtr(newBook.TimeUpdated.Format("2006-01-02 15:04:05"))
+	holdQueue, err := dao.MarshalHoldQueue(newBook.HoldQueue)
+	if err != nil {
+		return err
 	}
 
-	_, err := d.db.Exec(query, newBook.ISBN, newBook.State, newBook.OnHoldCustomerID, newBook.CheckedOutCustomerID, timeCreated, timeUpdated)
+	_, err = execContext(ctx, d.db, query, newBook.ISBN, newBook.State, newBook.OnHoldCustomerID, newBook.CheckedOutCustomerID, formatTime(newBook.TimeCreated), formatTime(newBook.TimeUpdated), formatTime(newBook.TimeDue), newBook.RenewalCount, holdQueue, formatTime(newBook.HoldExpiry))
 	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // ER_DUP_ENTRY
+			return dao.ErrBookAlreadyExists
+		}
 		return fmt.Errorf("error adding new book to database: %w", err)
 	}
 
+	newBook.Version = 1
+
 	return nil
 }
 
-func (d *MySQLBookDAO) Delete(book *models.Book) error {
+func (d *MySQLBookDAO) Delete(ctx context.Context, book *models.Book) error {
 	query := "DELETE FROM Books WHERE ISBN = ?"
+	args := []any{book.ISBN}
 
-	_, err := d.db.Exec(query, book.ISBN)
+	// The version is compared by the DELETE itself, so that no update can slip in between the check and the removal
+	if book.Version != 0 {
+		query += " AND Version = ?"
+		args = append(args, book.Version)
+	}
+
+	result, err := execContext(ctx, d.db, query, args...)
 	if err != nil {
 		return fmt.Errorf("error deleting book from database: %w", err)
 	}
 
+	if book.Version != 0 {
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error deleting book from database: %w", err)
+		}
+		if rowsAffected == 0 {
+			return dao.ErrVersionMismatch
+		}
+	}
+
 	return nil
 }
 
-func (d *MySQLBookDAO) Update(book *models.Book) error {
-	query := "UPDATE Books SET State = ?, OnHoldCustomerID = ?, CheckedOutCustomerID = ?, TimeUpdated = ? WHERE ISBN = ?"
+func (d *MySQLBookDAO) Update(ctx context.Context, book *models.Book) error {
+	tx, err := beginTx(ctx, d.db)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once the transaction has been committed
+
+	query := "UPDATE Books SET State = ?, OnHoldCustomerID = ?, CheckedOutCustomerID = ?, TimeUpdated = ?, TimeDue = ?, RenewalCount = ?, HoldQueue = ?, HoldExpiry = ?, Version = Version + 1 WHERE ISBN = ?"
+
+	dao.NormalizeBookTimes(book)
+	dao.NormalizeHoldQueue(book)
+
+	holdQueue, err := dao.MarshalHoldQueue(book.HoldQueue)
+	if err != nil {
+		return err
+	}
 
-	_, err := d.db.Exec(query, book.State, book.OnHoldCustomerID, book.CheckedOutCustomerID, book.TimeUpdated, book.ISBN)
+	_, err = execContext(ctx, tx, query, book.State, book.OnHoldCustomerID, book.CheckedOutCustomerID, formatTime(book.TimeUpdated), formatTime(book.TimeDue), book.RenewalCount, holdQueue, formatTime(book.HoldExpiry), book.ISBN)
 	if err != nil {
 		return fmt.Errorf("error updating book: %w", err)
 	}
 
+	// Read back the new version within the same transaction, so that it cannot have been bumped again by another update
+	var version int64
+	if err := queryRowContext(ctx, tx, "SELECT Version FROM Books WHERE ISBN = ?", book.ISBN).Scan(&version); err != nil {
+		if err == sql.ErrNoRows {
+			return dao.ErrBookNotFound
+		}
+		return fmt.Errorf("error reading updated version: %w", err)
+	}
+
+	if err := commit(ctx, tx); err != nil {
+		return fmt.Errorf("error committing update: %w", err)
+	}
+
+	book.Version = version
+
 	return nil
 }
 
-func (d *MySQLBookDAO) Read(isbn string) (*models.Book, error) {
-	query := "SELECT ISBN, State, OnHoldCustomerID, CheckedOutCustomerID, TimeCreated, TimeUpdated FROM Books WHERE ISBN = ?"
-	row := d.db.QueryRow(query, isbn)
+// UpdateAtomically is UpdateAtomicallyWithFines for a modify that has no use for the fines
+func (d *MySQLBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	return d.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, _ dao.FineTx) (*models.Book, error) {
+		return modify(currentBook)
+	})
+}
+
+// mysqlFineTx reads and creates fines within the transaction of a book update
+type mysqlFineTx struct {
+	ctx context.Context
+	tx *sql.Tx
+}
+
+func (f *mysqlFineTx) ReadByCustomer(customerID string) ([]*models.Fine, error) {
+	return readFinesByCustomer(f.ctx, f.tx, customerID)
+}
+
+func (f *mysqlFineTx) Create(newFine *models.Fine) error {
+	return createFine(f.ctx, f.tx, newFine)
+}
+
+// UpdateAtomicallyWithFines locks the book's row with SELECT ... FOR UPDATE for the duration of a transaction, so concurrent updates to the same book are applied one after the other.
+// The fines read and created by modify go through the same transaction
+func (d *MySQLBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	tx, err := beginTx(ctx, d.db)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once the transaction has been committed
+
+	query := "SELECT " + bookColumns + " FROM Books WHERE ISBN = ? FOR UPDATE"
+	currentBook, err := scanBook(queryRowContext(ctx, tx, query, isbn))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, dao.ErrBookNotFound
+		}
+
+		return nil, mapLockError(fmt.Errorf("error reading book for update: %w", err))
+	}
+
+	updatedBook, err := modify(currentBook.Copy(), &mysqlFineTx{ctx: ctx, tx: tx})
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedBook == nil || updatedBook.ISBN == nil || *updatedBook.ISBN != isbn {
+		return nil, errors.New("error updating book: modify must return the book it was given")
+	}
+
+	// Only bump the version if modify changed something, so that a no-op update does not invalidate the client's ETag.
+	// The transaction is committed either way, for the fines modify may have created
+	updatedBook.Version = currentBook.Version
+	dao.NormalizeBookTimes(updatedBook)
+	dao.NormalizeHoldQueue(updatedBook)
+	if !reflect.DeepEqual(updatedBook, currentBook) {
+		updatedBook.Version = currentBook.Version + 1
+
+		holdQueue, err := dao.MarshalHoldQueue(updatedBook.HoldQueue)
+		if err != nil {
+			return nil, err
+		}
+
+		query = "UPDATE Books SET State = ?, OnHoldCustomerID = ?, CheckedOutCustomerID = ?, TimeUpdated = ?, TimeDue = ?, RenewalCount = ?, HoldQueue = ?, HoldExpiry = ?, Version = ? WHERE ISBN = ?"
+		if _, err := execContext(ctx, tx, query, updatedBook.State, updatedBook.OnHoldCustomerID, updatedBook.CheckedOutCustomerID, formatTime(updatedBook.TimeUpdated), formatTime(updatedBook.TimeDue), updatedBook.RenewalCount, holdQueue, formatTime(updatedBook.HoldExpiry), updatedBook.Version, updatedBook.ISBN); err != nil {
+			return nil, mapLockError(fmt.Errorf("error updating book: %w", err))
+		}
+	}
+
+	if err := commit(ctx, tx); err != nil {
+		return nil, mapLockError(fmt.Errorf("error committing update: %w", err))
+	}
+
+	return updatedBook, nil
+}
+
+func (d *MySQLBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM Books WHERE ISBN = ?"
+
+	retrievedIndividualBook, err := scanBook(queryRowContext(ctx, d.db, query, isbn))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		
+		return nil, fmt.Errorf("error: %w", err)
+	}
 
+	return retrievedIndividualBook, nil
+}
+
+func (d *MySQLBookDAO) ReadAll(ctx context.Context) ([]*models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM Books"
+
+	// The span covers reading the rows as well as running the query
+	ctx, span := startStatementSpan(ctx, query)
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		recordStatementError(span, err)
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	retrievedBooks := make([]*models.Book, 0)
+
+	for rows.Next() {
+		nextBook, err := scanBook(rows)
+		if err != nil {
+			recordStatementError(span, err)
+			return nil, fmt.Errorf("error: %w", err)
+		}
+
+		retrievedBooks = append(retrievedBooks, nextBook)
+	}
+
+	if err := rows.Err(); err != nil {
+		recordStatementError(span, err)
+		return nil, fmt.Errorf("error iterating over books: %w", err)
+	}
+
+	return retrievedBooks, nil
+}
+
+// scanBook reads a single row selected with bookColumns into a book. sql.ErrNoRows is returned unwrapped so callers can detect a missing book
+func scanBook(row rowScanner) (*models.Book, error) {
 	retrievedISBN := new(sql.NullString)
 	retrievedState := new(sql.NullString)
 	retrievedOnHoldCustomerID := new(sql.NullString)
 	retrievedCheckedOutCustomerID := new(sql.NullString)
-	retrievedTimeCreated := new(sql.NullString)
-	retrievedTimeUpdated := new(sql.NullString)
+	retrievedTimeCreated := new(nullTime)
+	retrievedTimeUpdated := new(nullTime)
+	retrievedTimeDue := new(nullTime)
+	retrievedRenewalCount := new(sql.NullInt64)
+	retrievedHoldQueue := new(sql.NullString)
+	retrievedHoldExpiry := new(nullTime)
+	retrievedVersion := new(int64)
 
 	err := row.Scan(
 		retrievedISBN,
@@ -74,65 +316,175 @@ func (d *MySQLBookDAO) Read(isbn string) (*models.Book, error) {
 		retrievedCheckedOutCustomerID,
 		retrievedTimeCreated,
 		retrievedTimeUpdated,
+		retrievedTimeDue,
+		retrievedRenewalCount,
+		retrievedHoldQueue,
+		retrievedHoldExpiry,
+		retrievedVersion,
 	)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		
-		return nil, fmt.Errorf("error: %w", err)
+		return nil, err
 	}
 
-	retrievedIndividualBook := &models.Book{
+	retrievedBook := &models.Book{
 		ISBN: nil,
 		State: nil,
 		OnHoldCustomerID: nil,
 		CheckedOutCustomerID: nil,
 		TimeCreated: nil,
 		TimeUpdated: nil,
+		TimeDue: nil,
+		RenewalCount: nil,
+		HoldQueue: nil,
+		HoldExpiry: nil,
+		Overdue: false,
+		Version: *retrievedVersion,
 	}
 
 	if retrievedISBN.Valid {
-		retrievedIndividualBook.ISBN = &retrievedISBN.String
+		retrievedBook.ISBN = &retrievedISBN.String
 	}
 
 	if retrievedState.Valid {
-		retrievedIndividualBook.State = &retrievedState.String
+		retrievedBook.State = &retrievedState.String
 	}
 
 	if retrievedOnHoldCustomerID.Valid {
-		retrievedIndividualBook.OnHoldCustomerID = &retrievedOnHoldCustomerID.String
+		retrievedBook.OnHoldCustomerID = &retrievedOnHoldCustomerID.String
 	}
 
 	if retrievedCheckedOutCustomerID.Valid {
-		retrievedIndividualBook.CheckedOutCustomerID = &retrievedCheckedOutCustomerID.String
+		retrievedBook.CheckedOutCustomerID = &retrievedCheckedOutCustomerID.String
 	}
 
 	if retrievedTimeCreated.Valid {
-		timeCreated, err := time.Parse("2006-01-02 15:04:05", retrievedTimeCreated.String)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing time created in read: %w", err)
-		}
-		retrievedIndividualBook.TimeCreated = &timeCreated
+		retrievedBook.TimeCreated = &retrievedTimeCreated.Time
 	}
 
 	if retrievedTimeUpdated.Valid {
-		timeUpdated, err := time.Parse("2006-01-02 15:04:05", retrievedTimeUpdated.String)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing time created in read: %w", err)
+		retrievedBook.TimeUpdated = &retrievedTimeUpdated.Time
+	}
+
+	if retrievedTimeDue.Valid {
+		retrievedBook.TimeDue = &retrievedTimeDue.Time
+	}
+
+	if retrievedRenewalCount.Valid {
+		retrievedBook.RenewalCount = utils.ToPtr(int(retrievedRenewalCount.Int64))
+	}
+
+	if retrievedHoldExpiry.Valid {
+		retrievedBook.HoldExpiry = &retrievedHoldExpiry.Time
+	}
+
+	if retrievedBook.HoldQueue, err = dao.UnmarshalHoldQueue(*retrievedHoldQueue); err != nil {
+		return nil, err
+	}
+
+	return retrievedBook, nil
+}
+
+// mapLockError wraps err with dao.ErrConcurrentUpdate when MySQL aborted the statement because of a deadlock or a lock wait timeout
+func mapLockError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// 1205: ER_LOCK_WAIT_TIMEOUT, 1213: ER_LOCK_DEADLOCK
+		if mysqlErr.Number == 1205 || mysqlErr.Number == 1213 {
+			return fmt.Errorf("%w: %v", dao.ErrConcurrentUpdate, err)
 		}
-		retrievedIndividualBook.TimeUpdated = &timeUpdated
 	}
 
-	return retrievedIndividualBook, nil
+	return err
 }
 
-func (d *MySQLBookDAO) ReadAll() ([]*models.Book, error) {
-	query := "SELECT ISBN, State, OnHoldCustomerID, CheckedOutCustomerID, TimeCreated, TimeUpdated FROM Books"
+// sortColumns maps the sort fields of a dao.BookQuery to the columns of the Books table
+var sortColumns = map[string]string{
+	dao.SortByISBN: "ISBN",
+	dao.SortByTimeCreated: "TimeCreated",
+	dao.SortByTimeUpdated: "TimeUpdated",
+	dao.SortByTimeDue: "TimeDue",
+}
+
+// Query builds the WHERE, ORDER BY and LIMIT clauses from the query, so that filtering and pagination happen in the database.
+// Times are bound as the same UTC text the write paths store, so that the comparisons do not depend on the loc of the DSN
+func (d *MySQLBookDAO) Query(ctx context.Context, query dao.BookQuery) ([]*models.Book, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+
+	sortColumn, ok := sortColumns[query.SortBy]
+	if !ok {
+		sortColumn = "ISBN"
+	}
+
+	if query.State != nil {
+		conditions = append(conditions, "State = ?")
+		args = append(args, *query.State)
+	}
+
+	if query.CustomerID != nil {
+		conditions = append(conditions, "(OnHoldCustomerID = ? OR CheckedOutCustomerID = ?)")
+		args = append(args, *query.CustomerID, *query.CustomerID)
+	}
+
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, "TimeCreated > ?")
+		args = append(args, formatTime(query.CreatedAfter))
+	}
+
+	if query.CreatedBefore != nil {
+		conditions = append(conditions, "TimeCreated < ?")
+		args = append(args, formatTime(query.CreatedBefore))
+	}
+
+	if query.UpdatedAfter != nil {
+		conditions = append(conditions, "TimeUpdated > ?")
+		args = append(args, formatTime(query.UpdatedAfter))
+	}
 
-	rows, err := d.db.Query(query)
+	if query.DueBefore != nil {
+		conditions = append(conditions, "TimeDue < ?")
+		args = append(args, formatTime(query.DueBefore))
+	}
+
+	if query.HoldExpiryBefore != nil {
+		conditions = append(conditions, "HoldExpiry < ?")
+		args = append(args, formatTime(query.HoldExpiryBefore))
+	}
+
+	// The page starts after the last book of the previous page, which the index on the sort column lets MySQL seek to
+	if query.After != nil {
+		placeholder := func(arg any) string {
+			args = append(args, arg)
+			return "?"
+		}
+		conditions = append(conditions, dao.KeysetCondition(query, sortColumn, "ISBN", placeholder, func(t *time.Time) any { return formatTime(t) }))
+	}
+
+	sqlQuery := "SELECT " + bookColumns + " FROM Books"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// MySQL sorts NULLs first in ascending order and last in descending order, as documented on dao.BookQuery
+	direction := "ASC"
+	if query.SortDescending {
+		direction = "DESC"
+	}
+	sqlQuery += fmt.Sprintf(" ORDER BY %s %s, ISBN %s", sortColumn, direction, direction)
+
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	// The span covers reading the rows as well as running the query
+	ctx, span := startStatementSpan(ctx, sqlQuery)
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
+		recordStatementError(span, err)
 		return nil, fmt.Errorf("error querying database: %w", err)
 	}
 	defer rows.Close()
@@ -140,69 +492,19 @@ func (d *MySQLBookDAO) ReadAll() ([]*models.Book, error) {
 	retrievedBooks := make([]*models.Book, 0)
 
 	for rows.Next() {
-		retrievedISBN := new(sql.NullString)
-		retrievedState := new(sql.NullString)
-		retrievedOnHoldCustomerID := new(sql.NullString)
-		retrievedCheckedOutCustomerID := new(sql.NullString)
-		retrievedTimeCreated := new(sql.NullString)
-		retrievedTimeUpdated := new(sql.NullString)
-
-		err := rows.Scan(
-			retrievedISBN,
-			retrievedState,
-			retrievedOnHoldCustomerID,
-			retrievedCheckedOutCustomerID,
-			retrievedTimeCreated,
-			retrievedTimeUpdated,
-		)
-			
+		nextBook, err := scanBook(rows)
 		if err != nil {
+			recordStatementError(span, err)
 			return nil, fmt.Errorf("error: %w", err)
 		}
 
-		nextBook := &models.Book{
-			ISBN: nil,
-			State: nil,
-			OnHoldCustomerID: nil,
-			CheckedOutCustomerID: nil,
-			TimeCreated: nil,
-			TimeUpdated: nil,
-		}
-
-		if retrievedISBN.Valid {
-			nextBook.ISBN = &retrievedISBN.String
-		}
-	
-		if retrievedState.Valid {
-			nextBook.State = &retrievedState.String
-		}
-	
-		if retrievedOnHoldCustomerID.Valid {
-			nextBook.OnHoldCustomerID = &retrievedOnHoldCustomerID.String
-		}
-	
-		if retrievedCheckedOutCustomerID.Valid {
-			nextBook.CheckedOutCustomerID = &retrievedCheckedOutCustomerID.String
-		}
-	
-		if retrievedTimeCreated.Valid {
-			timeCreated, err := time.Parse("2006-01-02 15:04:05", retrievedTimeCreated.String)
-			if err != nil {
-				return nil, fmt.Errorf("error parsing time created in read: %w", err)
-			}
-			nextBook.TimeCreated = &timeCreated
-		}
-	
-		if retrievedTimeUpdated.Valid {
-			timeUpdated, err := time.Parse("2006-01-02 15:04:05", retrievedTimeUpdated.String)
-			if err != nil {
-				return nil, fmt.Errorf("error parsing time created in read: %w", err)
-			}
-			nextBook.TimeUpdated = &timeUpdated
-		}
-
 		retrievedBooks = append(retrievedBooks, nextBook)
 	}
 
+	if err := rows.Err(); err != nil {
+		recordStatementError(span, err)
+		return nil, fmt.Errorf("error iterating over books: %w", err)
+	}
+
 	return retrievedBooks, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,53 @@
+package mysqldao
+
+import (
+	"example/library_project/dao"
+	"example/library_project/dao/daotest"
+
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMySQLDAOFactory_Conformance runs the shared DAO contract against the MySQL database given by LIBRARY_TEST_MYSQL_DSN, and is skipped if it is not set.
+// The database is cleared, so it must not hold any data worth keeping
+func TestMySQLDAOFactory_Conformance(t *testing.T) {
+	dsn := os.Getenv("LIBRARY_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("LIBRARY_TEST_MYSQL_DSN is not set")
+	}
+
+	daotest.RunDAOFactoryTests(t, func(t *testing.T) dao.DAOFactory {
+		return NewMySQLDAOFactoryFromDSN(dsn)
+	})
+}
+
+func TestNullTime_Scan(t *testing.T) {
+	expectedTime := time.Date(2023, 2, 1, 1, 30, 0, 123456000, time.UTC)
+
+	tests := []struct{
+		description string
+		src any
+		expectedValid bool
+	}{
+		{"NULL", nil, false},
+		{"Text, without parseTime", []byte("2023-02-01 01:30:00.123456"), true},
+		{"time.Time, with parseTime", time.Date(2023, 2, 1, 2, 30, 0, 123456000, time.FixedZone("UTC+1", 60*60)), true},
+	}
+
+	for _, currentTestCase := range tests {
+		t.Log(currentTestCase.description)
+
+		var scannedTime nullTime
+		assert.Nil(t, scannedTime.Scan(currentTestCase.src))
+		assert.Equal(t, currentTestCase.expectedValid, scannedTime.Valid)
+		if currentTestCase.expectedValid {
+			assert.Equal(t, expectedTime, scannedTime.Time)
+		}
+	}
+
+	var scannedTime nullTime
+	assert.NotNil(t, scannedTime.Scan([]byte("yesterday")))
+}
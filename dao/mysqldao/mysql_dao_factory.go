@@ -7,55 +7,73 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"database/sql"
 
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	// "log"
 
 	// "os"
 )
 
+// MySQLDAOFactory connects to a MySQL database whose schema is managed by the embedded migrations (see MigrationMode)
 type MySQLDAOFactory struct {
 	db *sql.DB
-	dbUsername string
-	dbPassword string
-	dbHost string
-	dbPort string
-	dbName string
+	dsn string
+
+	// MigrationMode selects whether Open applies pending migrations, only verifies that there are none, or leaves the schema alone
+	MigrationMode MigrationMode
+
+	// Pool configures the connection pool on Open
+	Pool dao.ConnectionPool
 }
 
 func NewMySQLDAOFactory(dbUsername string, dbPassword string, dbHost string, dbPort string, dbName string) *MySQLDAOFactory {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUsername, dbPassword, dbHost, dbPort, dbName)
+
+	return NewMySQLDAOFactoryFromDSN(dsn)
+}
+
+// NewMySQLDAOFactoryFromDSN accepts any data source name understood by the MySQL driver, such as "user:password@tcp(localhost:3306)/library?tls=true&parseTime=true"
+func NewMySQLDAOFactoryFromDSN(dsn string) *MySQLDAOFactory {
 	return &MySQLDAOFactory{
 		db: nil,
-		dbUsername: dbUsername,
-		dbPassword: dbPassword,
-		dbHost: dbHost,
-		dbPort: dbPort,
-		dbName: dbName,
+		dsn: dsn,
+		MigrationMode: MigrationModeApply,
+		Pool: dao.ConnectionPool{},
 	}
 }
 
 func (f *MySQLDAOFactory) Open() error {
-	dbUsername := f.dbUsername
-	dbPassword := f.dbPassword
-	dbHost := f.dbHost
-	dbPort := f.dbPort
-	dbName := f.dbName
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUsername, dbPassword, dbHost, dbPort, dbName)
-
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open("mysql", f.dsn)
 	if err != nil {
 		return fmt.Errorf("failed to connect to the database: %w", err)
 	}
 
+	f.Pool.Apply(db)
+
 	err = db.Ping()
 	if err != nil {
+		db.Close()
 		return fmt.Errorf("failed to ping the database: %w", err)
 	}
 
 	f.db = db
 
+	switch f.MigrationMode {
+	case MigrationModeApply:
+		_, err = f.MigrateUp()
+	case MigrationModeVerify:
+		err = f.verifyMigrations()
+	}
+	if err != nil {
+		db.Close()
+		f.db = nil
+		return fmt.Errorf("failed to prepare the database schema: %w", err)
+	}
+
 	// log.Println("Connected to the MySQL database")
-	fmt.Println("Connected to the MySQL database")
+	slog.Info("connected to the MySQL database")
 
 	return nil
 }
@@ -79,10 +97,35 @@ func (f *MySQLDAOFactory) BookDAO() dao.BookDAO {
 	}
 }
 
+func (f *MySQLDAOFactory) FineDAO() dao.FineDAO {
+	return &MySQLFineDAO{
+		db: f.db,
+	}
+}
+
+// DB returns the connection pool, for reporting its statistics. It is nil until Open succeeds
+func (f *MySQLDAOFactory) DB() *sql.DB {
+	return f.db
+}
+
+func (f *MySQLDAOFactory) Ping(ctx context.Context) error {
+	if f.db == nil {
+		return errors.New("database connection is not open")
+	}
+
+	if err := f.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping the database: %w", err)
+	}
+
+	return nil
+}
+
 func (f *MySQLDAOFactory) Clear() error {
-	_, err := f.db.Exec("TRUNCATE TABLE Books;")
-	if err != nil {
-		return fmt.Errorf("failed to clear database: %w", err)
+	// The driver runs a single statement per call, unless the DSN sets multiStatements=true
+	for _, table := range []string{"Books", "Fines"} {
+		if _, err := f.db.Exec("TRUNCATE TABLE " + table + ";"); err != nil {
+			return fmt.Errorf("failed to clear database: %w", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,179 @@
+package mysqldao
+
+import (
+	"database/sql"
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// fineColumns lists the columns of the Fines table in the order expected by scanFine
+const fineColumns = "ID, CustomerID, ISBN, DaysOverdue, Amount, State, TimeCreated, TimeUpdated"
+
+type MySQLFineDAO struct {
+	db *sql.DB
+}
+
+func (d *MySQLFineDAO) Create(ctx context.Context, newFine *models.Fine) error {
+	return createFine(ctx, d.db, newFine)
+}
+
+// createFine inserts newFine through q, which is either the connection pool or the transaction of a book update
+func createFine(ctx context.Context, q querier, newFine *models.Fine) error {
+	query := "INSERT INTO Fines (" + fineColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+	dao.NormalizeFineTimes(newFine)
+
+	_, err := execContext(ctx, q, query, newFine.ID, newFine.CustomerID, newFine.ISBN, newFine.DaysOverdue, newFine.Amount, newFine.State, formatTime(newFine.TimeCreated), formatTime(newFine.TimeUpdated))
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // ER_DUP_ENTRY
+			return dao.ErrFineAlreadyExists
+		}
+		return mapLockError(fmt.Errorf("error adding new fine to database: %w", err))
+	}
+
+	return nil
+}
+
+func (d *MySQLFineDAO) Read(ctx context.Context, id string) (*models.Fine, error) {
+	query := "SELECT " + fineColumns + " FROM Fines WHERE ID = ?"
+
+	retrievedFine, err := scanFine(queryRowContext(ctx, d.db, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("error reading fine: %w", err)
+	}
+
+	return retrievedFine, nil
+}
+
+func (d *MySQLFineDAO) ReadByCustomer(ctx context.Context, customerID string) ([]*models.Fine, error) {
+	return readFinesByCustomer(ctx, d.db, customerID)
+}
+
+// readFinesByCustomer selects the fines of the customer through q, which is either the connection pool or the transaction of a book update
+func readFinesByCustomer(ctx context.Context, q querier, customerID string) ([]*models.Fine, error) {
+	query := "SELECT " + fineColumns + " FROM Fines WHERE CustomerID = ? ORDER BY TimeCreated ASC, ID ASC"
+
+	// The span covers reading the rows as well as running the query
+	ctx, span := startStatementSpan(ctx, query)
+	defer span.End()
+
+	rows, err := q.QueryContext(ctx, query, customerID)
+	if err != nil {
+		recordStatementError(span, err)
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	retrievedFines := make([]*models.Fine, 0)
+
+	for rows.Next() {
+		nextFine, err := scanFine(rows)
+		if err != nil {
+			recordStatementError(span, err)
+			return nil, fmt.Errorf("error reading fine: %w", err)
+		}
+
+		retrievedFines = append(retrievedFines, nextFine)
+	}
+
+	if err := rows.Err(); err != nil {
+		recordStatementError(span, err)
+		return nil, fmt.Errorf("error iterating over fines: %w", err)
+	}
+
+	return retrievedFines, nil
+}
+
+// UpdateAtomically locks the fine's row with SELECT ... FOR UPDATE for the duration of a transaction, so concurrent updates to the same fine are applied one after the other
+func (d *MySQLFineDAO) UpdateAtomically(ctx context.Context, id string, modify func(currentFine *models.Fine) (*models.Fine, error)) (*models.Fine, error) {
+	tx, err := beginTx(ctx, d.db)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once the transaction has been committed
+
+	query := "SELECT " + fineColumns + " FROM Fines WHERE ID = ? FOR UPDATE"
+	currentFine, err := scanFine(queryRowContext(ctx, tx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, dao.ErrFineNotFound
+		}
+
+		return nil, mapLockError(fmt.Errorf("error reading fine for update: %w", err))
+	}
+
+	updatedFine, err := modify(currentFine.Copy())
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedFine == nil || updatedFine.ID == nil || *updatedFine.ID != id {
+		return nil, errors.New("error updating fine: modify must return the fine it was given")
+	}
+
+	dao.NormalizeFineTimes(updatedFine)
+
+	query = "UPDATE Fines SET State = ?, TimeUpdated = ? WHERE ID = ?"
+	if _, err := execContext(ctx, tx, query, updatedFine.State, formatTime(updatedFine.TimeUpdated), updatedFine.ID); err != nil {
+		return nil, mapLockError(fmt.Errorf("error updating fine: %w", err))
+	}
+
+	if err := commit(ctx, tx); err != nil {
+		return nil, mapLockError(fmt.Errorf("error committing update: %w", err))
+	}
+
+	return updatedFine, nil
+}
+
+// scanFine reads a single row selected with fineColumns into a fine. sql.ErrNoRows is returned unwrapped so callers can detect a missing fine
+func scanFine(row rowScanner) (*models.Fine, error) {
+	retrievedFine := &models.Fine{
+		ID: new(string),
+		CustomerID: new(string),
+		ISBN: new(string),
+		DaysOverdue: new(int),
+		Amount: new(int),
+		State: new(string),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	}
+	retrievedTimeCreated := new(nullTime)
+	retrievedTimeUpdated := new(nullTime)
+
+	err := row.Scan(
+		retrievedFine.ID,
+		retrievedFine.CustomerID,
+		retrievedFine.ISBN,
+		retrievedFine.DaysOverdue,
+		retrievedFine.Amount,
+		retrievedFine.State,
+		retrievedTimeCreated,
+		retrievedTimeUpdated,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if retrievedTimeCreated.Valid {
+		retrievedFine.TimeCreated = utils.ToPtr(retrievedTimeCreated.Time)
+	}
+
+	if retrievedTimeUpdated.Valid {
+		retrievedFine.TimeUpdated = utils.ToPtr(retrievedTimeUpdated.Time)
+	}
+
+	return retrievedFine, nil
+}
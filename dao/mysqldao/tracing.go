@@ -0,0 +1,84 @@
+package mysqldao
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records a span for every SQL statement run by MySQLBookDAO and MySQLFineDAO. It uses the global tracer provider, so it records nothing unless tracing is enabled
+var tracer = otel.Tracer("example/library_project/dao/mysqldao")
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// startStatementSpan starts the client span of a SQL statement, named after its operation such as "SELECT". The statement is recorded with its placeholders, never with its arguments
+func startStatementSpan(ctx context.Context, statement string) (context.Context, trace.Span) {
+	operation := strings.ToUpper(strings.SplitN(strings.TrimSpace(statement), " ", 2)[0])
+
+	return tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBOperation(operation), semconv.DBStatement(statement)),
+	)
+}
+
+// recordStatementError marks span as failed with err, if any
+func recordStatementError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// execContext runs a statement that returns no rows, within its own span
+func execContext(ctx context.Context, q querier, statement string, args ...any) (sql.Result, error) {
+	ctx, span := startStatementSpan(ctx, statement)
+	defer span.End()
+
+	result, err := q.ExecContext(ctx, statement, args...)
+	recordStatementError(span, err)
+
+	return result, err
+}
+
+// queryRowContext runs a statement returning at most one row, within its own span. sql.ErrNoRows only surfaces on Scan, so a missing row does not mark the span as failed
+func queryRowContext(ctx context.Context, q querier, statement string, args ...any) *sql.Row {
+	ctx, span := startStatementSpan(ctx, statement)
+	defer span.End()
+
+	row := q.QueryRowContext(ctx, statement, args...)
+	recordStatementError(span, row.Err())
+
+	return row
+}
+
+// beginTx starts a transaction, within its own span
+func beginTx(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	ctx, span := startStatementSpan(ctx, "BEGIN")
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	recordStatementError(span, err)
+
+	return tx, err
+}
+
+// commit commits tx, within its own span
+func commit(ctx context.Context, tx *sql.Tx) error {
+	_, span := startStatementSpan(ctx, "COMMIT")
+	defer span.End()
+
+	err := tx.Commit()
+	recordStatementError(span, err)
+
+	return err
+}
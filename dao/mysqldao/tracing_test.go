@@ -0,0 +1,97 @@
+package mysqldao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeQuerier returns err from ExecContext, without a database
+type fakeQuerier struct {
+	err error
+}
+
+func (q *fakeQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, q.err
+}
+
+func (q *fakeQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, q.err
+}
+
+func (q *fakeQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return nil
+}
+
+func TestExecContext_Span(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previousTracerProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracerProvider)
+	t.Cleanup(func() { otel.SetTracerProvider(previousTracerProvider) })
+
+	tests := []struct{
+		description string
+		statement string
+		err error
+		expectedName string
+		expectedStatus codes.Code
+	}{
+		{
+			description: "Successful statement",
+			statement: "DELETE FROM Books WHERE ISBN = ?",
+			err: nil,
+			expectedName: "DELETE",
+			expectedStatus: codes.Unset,
+		},
+		{
+			description: "Failed statement",
+			statement: "  update Books SET State = ? WHERE ISBN = ?",
+			err: errors.New("lock wait timeout"),
+			expectedName: "UPDATE",
+			expectedStatus: codes.Error,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		exporter.Reset()
+
+		parentContext, parentSpan := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+		_, err := execContext(parentContext, &fakeQuerier{err: currentTestCase.err}, currentTestCase.statement, "00001")
+		parentSpan.End()
+
+		assert.Equal(t, currentTestCase.err, err)
+
+		spans := exporter.GetSpans()
+		if !assert.Len(t, spans, 2) {
+			continue
+		}
+		span := spans[0]
+
+		assert.Equal(t, currentTestCase.expectedName, span.Name)
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+		assert.Equal(t, currentTestCase.expectedStatus, span.Status.Code)
+		assert.Equal(t, spans[1].SpanContext.SpanID(), span.Parent.SpanID())
+
+		// The statement is recorded with its placeholders, and never with its arguments
+		assert.Contains(t, span.Attributes, attribute.String("db.system", "mysql"))
+		assert.Contains(t, span.Attributes, attribute.String("db.operation", currentTestCase.expectedName))
+		assert.Contains(t, span.Attributes, attribute.String("db.statement", currentTestCase.statement))
+		for _, currentAttribute := range span.Attributes {
+			assert.NotEqual(t, "00001", currentAttribute.Value.Emit())
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package postgresdao
+
+import (
+	"database/sql"
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// bookColumns lists the columns of the books table in the order expected by scanBook
+const bookColumns = "isbn, state, on_hold_customer_id, checked_out_customer_id, time_created, time_updated, time_due, renewal_count, hold_queue, hold_expiry, version"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type PostgresBookDAO struct {
+	db *sql.DB
+}
+
+func (d *PostgresBookDAO) Create(ctx context.Context, newBook *models.Book) error {
+	query := "INSERT INTO books (" + bookColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1)"
+
+	// Times are truncated before being sent, since PostgreSQL would round them to the nearest microsecond instead
+	dao.NormalizeBookTimes(newBook)
+	dao.NormalizeHoldQueue(newBook)
+
+	holdQueue, err := dao.MarshalHoldQueue(newBook.HoldQueue)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, query, newBook.ISBN, newBook.State, newBook.OnHoldCustomerID, newBook.CheckedOutCustomerID, newBook.TimeCreated, newBook.TimeUpdated, newBook.TimeDue, newBook.RenewalCount, holdQueue, newBook.HoldExpiry)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return dao.ErrBookAlreadyExists
+		}
+		return fmt.Errorf("error adding new book to database: %w", err)
+	}
+
+	newBook.Version = 1
+
+	return nil
+}
+
+func (d *PostgresBookDAO) Delete(ctx context.Context, book *models.Book) error {
+	query := "DELETE FROM books WHERE isbn = $1"
+	args := []any{book.ISBN}
+
+	// The version is compared by the DELETE itself, so that no update can slip in between the check and the removal
+	if book.Version != 0 {
+		query += " AND version = $2"
+		args = append(args, book.Version)
+	}
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error deleting book from database: %w", err)
+	}
+
+	if book.Version != 0 {
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error deleting book from database: %w", err)
+		}
+		if rowsAffected == 0 {
+			return dao.ErrVersionMismatch
+		}
+	}
+
+	return nil
+}
+
+func (d *PostgresBookDAO) Update(ctx context.Context, book *models.Book) error {
+	query := "UPDATE books SET state = $1, on_hold_customer_id = $2, checked_out_customer_id = $3, time_updated = $4, time_due = $5, renewal_count = $6, hold_queue = $7, hold_expiry = $8, version = version + 1 WHERE isbn = $9 RETURNING version"
+
+	dao.NormalizeBookTimes(book)
+	dao.NormalizeHoldQueue(book)
+
+	var version int64
+	holdQueue, err := dao.MarshalHoldQueue(book.HoldQueue)
+	if err != nil {
+		return err
+	}
+
+	err = d.db.QueryRowContext(ctx, query, book.State, book.OnHoldCustomerID, book.CheckedOutCustomerID, book.TimeUpdated, book.TimeDue, book.RenewalCount, holdQueue, book.HoldExpiry, book.ISBN).Scan(&version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return dao.ErrBookNotFound
+		}
+		return fmt.Errorf("error updating book: %w", err)
+	}
+
+	book.Version = version
+
+	return nil
+}
+
+// UpdateAtomically is UpdateAtomicallyWithFines for a modify that has no use for the fines
+func (d *PostgresBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	return d.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, _ dao.FineTx) (*models.Book, error) {
+		return modify(currentBook)
+	})
+}
+
+// postgresFineTx reads and creates fines within the transaction of a book update
+type postgresFineTx struct {
+	ctx context.Context
+	tx *sql.Tx
+}
+
+func (f *postgresFineTx) ReadByCustomer(customerID string) ([]*models.Fine, error) {
+	return readFinesByCustomer(f.ctx, f.tx, customerID)
+}
+
+func (f *postgresFineTx) Create(newFine *models.Fine) error {
+	return createFine(f.ctx, f.tx, newFine)
+}
+
+// UpdateAtomicallyWithFines locks the book's row with SELECT ... FOR UPDATE for the duration of a transaction, so concurrent updates to the same book are applied one after the other.
+// The fines read and created by modify go through the same transaction
+func (d *PostgresBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once the transaction has been committed
+
+	query := "SELECT " + bookColumns + " FROM books WHERE isbn = $1 FOR UPDATE"
+	currentBook, err := scanBook(tx.QueryRowContext(ctx, query, isbn))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, dao.ErrBookNotFound
+		}
+
+		return nil, mapLockError(fmt.Errorf("error reading book for update: %w", err))
+	}
+
+	updatedBook, err := modify(currentBook.Copy(), &postgresFineTx{ctx: ctx, tx: tx})
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedBook == nil || updatedBook.ISBN == nil || *updatedBook.ISBN != isbn {
+		return nil, errors.New("error updating book: modify must return the book it was given")
+	}
+
+	// Only bump the version if modify changed something, so that a no-op update does not invalidate the client's ETag.
+	// The transaction is committed either way, for the fines modify may have created
+	updatedBook.Version = currentBook.Version
+	dao.NormalizeBookTimes(updatedBook)
+	dao.NormalizeHoldQueue(updatedBook)
+	if !reflect.DeepEqual(updatedBook, currentBook) {
+		updatedBook.Version = currentBook.Version + 1
+
+		holdQueue, err := dao.MarshalHoldQueue(updatedBook.HoldQueue)
+		if err != nil {
+			return nil, err
+		}
+
+		query = "UPDATE books SET state = $1, on_hold_customer_id = $2, checked_out_customer_id = $3, time_updated = $4, time_due = $5, renewal_count = $6, hold_queue = $7, hold_expiry = $8, version = $9 WHERE isbn = $10"
+		if _, err := tx.ExecContext(ctx, query, updatedBook.State, updatedBook.OnHoldCustomerID, updatedBook.CheckedOutCustomerID, updatedBook.TimeUpdated, updatedBook.TimeDue, updatedBook.RenewalCount, holdQueue, updatedBook.HoldExpiry, updatedBook.Version, updatedBook.ISBN); err != nil {
+			return nil, mapLockError(fmt.Errorf("error updating book: %w", err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, mapLockError(fmt.Errorf("error committing update: %w", err))
+	}
+
+	return updatedBook, nil
+}
+
+func (d *PostgresBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM books WHERE isbn = $1"
+
+	retrievedIndividualBook, err := scanBook(d.db.QueryRowContext(ctx, query, isbn))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("error: %w", err)
+	}
+
+	return retrievedIndividualBook, nil
+}
+
+func (d *PostgresBookDAO) ReadAll(ctx context.Context) ([]*models.Book, error) {
+	return d.queryBooks(ctx, "SELECT " + bookColumns + " FROM books")
+}
+
+// sortColumns maps the sort fields of a dao.BookQuery to the columns of the books table
+var sortColumns = map[string]string{
+	dao.SortByISBN: "isbn",
+	dao.SortByTimeCreated: "time_created",
+	dao.SortByTimeUpdated: "time_updated",
+	dao.SortByTimeDue: "time_due",
+}
+
+// Query builds the WHERE, ORDER BY and LIMIT clauses from the query, so that filtering and pagination happen in the database
+func (d *PostgresBookDAO) Query(ctx context.Context, query dao.BookQuery) ([]*models.Book, error) {
+	sqlQuery, args := buildQuery(query)
+
+	return d.queryBooks(ctx, sqlQuery, args...)
+}
+
+// buildQuery returns the statement selecting the books of the query, along with its arguments
+func buildQuery(query dao.BookQuery) (string, []any) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+
+	// placeholder adds an argument and returns its positional placeholder
+	placeholder := func(arg any) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.State != nil {
+		conditions = append(conditions, "state = "+placeholder(*query.State))
+	}
+
+	if query.CustomerID != nil {
+		customerPlaceholder := placeholder(*query.CustomerID)
+		conditions = append(conditions, "(on_hold_customer_id = "+customerPlaceholder+" OR checked_out_customer_id = "+customerPlaceholder+")")
+	}
+
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, "time_created > "+placeholder(*query.CreatedAfter))
+	}
+
+	if query.CreatedBefore != nil {
+		conditions = append(conditions, "time_created < "+placeholder(*query.CreatedBefore))
+	}
+
+	if query.UpdatedAfter != nil {
+		conditions = append(conditions, "time_updated > "+placeholder(*query.UpdatedAfter))
+	}
+
+	if query.DueBefore != nil {
+		conditions = append(conditions, "time_due < "+placeholder(*query.DueBefore))
+	}
+
+	if query.HoldExpiryBefore != nil {
+		conditions = append(conditions, "hold_expiry < "+placeholder(*query.HoldExpiryBefore))
+	}
+
+	sortColumn, ok := sortColumns[query.SortBy]
+	if !ok {
+		sortColumn = "isbn"
+	}
+
+	// The page starts after the last book of the previous page, which the index on the sort column lets PostgreSQL seek to
+	if query.After != nil {
+		conditions = append(conditions, dao.KeysetCondition(query, sortColumn, "isbn", placeholder, func(t *time.Time) any { return *t }))
+	}
+
+	sqlQuery := "SELECT " + bookColumns + " FROM books"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// PostgreSQL sorts NULLs last in ascending order by default, so the NULL ordering documented on dao.BookQuery is requested explicitly.
+	// The indexes on time_created and time_updated are declared NULLS FIRST to match, so that pages are read from them without sorting
+	orderBy := fmt.Sprintf(" ORDER BY %s ASC NULLS FIRST, isbn ASC", sortColumn)
+	if query.SortDescending {
+		orderBy = fmt.Sprintf(" ORDER BY %s DESC NULLS LAST, isbn DESC", sortColumn)
+	}
+	sqlQuery += orderBy
+
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT " + placeholder(query.Limit)
+	}
+
+	return sqlQuery, args
+}
+
+// queryBooks runs a query selecting bookColumns and scans every row into a book
+func (d *PostgresBookDAO) queryBooks(ctx context.Context, query string, args ...any) ([]*models.Book, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	retrievedBooks := make([]*models.Book, 0)
+
+	for rows.Next() {
+		nextBook, err := scanBook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error: %w", err)
+		}
+
+		retrievedBooks = append(retrievedBooks, nextBook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over books: %w", err)
+	}
+
+	return retrievedBooks, nil
+}
+
+// scanBook reads a single row selected with bookColumns into a book. sql.ErrNoRows is returned unwrapped so callers can detect a missing book
+func scanBook(row rowScanner) (*models.Book, error) {
+	retrievedISBN := new(sql.NullString)
+	retrievedState := new(sql.NullString)
+	retrievedOnHoldCustomerID := new(sql.NullString)
+	retrievedCheckedOutCustomerID := new(sql.NullString)
+	retrievedTimeCreated := new(sql.NullTime)
+	retrievedTimeUpdated := new(sql.NullTime)
+	retrievedTimeDue := new(sql.NullTime)
+	retrievedRenewalCount := new(sql.NullInt64)
+	retrievedHoldQueue := new(sql.NullString)
+	retrievedHoldExpiry := new(sql.NullTime)
+	retrievedVersion := new(int64)
+
+	err := row.Scan(
+		retrievedISBN,
+		retrievedState,
+		retrievedOnHoldCustomerID,
+		retrievedCheckedOutCustomerID,
+		retrievedTimeCreated,
+		retrievedTimeUpdated,
+		retrievedTimeDue,
+		retrievedRenewalCount,
+		retrievedHoldQueue,
+		retrievedHoldExpiry,
+		retrievedVersion,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	retrievedBook := &models.Book{
+		ISBN: nil,
+		State: nil,
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: nil,
+		TimeUpdated: nil,
+		TimeDue: nil,
+		RenewalCount: nil,
+		HoldQueue: nil,
+		HoldExpiry: nil,
+		Overdue: false,
+		Version: *retrievedVersion,
+	}
+
+	if retrievedISBN.Valid {
+		retrievedBook.ISBN = &retrievedISBN.String
+	}
+
+	if retrievedState.Valid {
+		retrievedBook.State = &retrievedState.String
+	}
+
+	if retrievedOnHoldCustomerID.Valid {
+		retrievedBook.OnHoldCustomerID = &retrievedOnHoldCustomerID.String
+	}
+
+	if retrievedCheckedOutCustomerID.Valid {
+		retrievedBook.CheckedOutCustomerID = &retrievedCheckedOutCustomerID.String
+	}
+
+	// timestamptz values are returned in the session time zone, so normalize them to UTC
+	if retrievedTimeCreated.Valid {
+		timeCreated := retrievedTimeCreated.Time.UTC()
+		retrievedBook.TimeCreated = &timeCreated
+	}
+
+	if retrievedTimeUpdated.Valid {
+		timeUpdated := retrievedTimeUpdated.Time.UTC()
+		retrievedBook.TimeUpdated = &timeUpdated
+	}
+
+	if retrievedTimeDue.Valid {
+		timeDue := retrievedTimeDue.Time.UTC()
+		retrievedBook.TimeDue = &timeDue
+	}
+
+	if retrievedRenewalCount.Valid {
+		renewalCount := int(retrievedRenewalCount.Int64)
+		retrievedBook.RenewalCount = &renewalCount
+	}
+
+	if retrievedHoldExpiry.Valid {
+		holdExpiry := retrievedHoldExpiry.Time.UTC()
+		retrievedBook.HoldExpiry = &holdExpiry
+	}
+
+	if retrievedBook.HoldQueue, err = dao.UnmarshalHoldQueue(*retrievedHoldQueue); err != nil {
+		return nil, err
+	}
+
+	return retrievedBook, nil
+}
+
+// mapLockError wraps err with dao.ErrConcurrentUpdate when PostgreSQL aborted the statement because of a deadlock, a serialization failure or a lock timeout
+func mapLockError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 40001: serialization_failure, 40P01: deadlock_detected, 55P03: lock_not_available
+		if pgErr.Code == "40001" || pgErr.Code == "40P01" || pgErr.Code == "55P03" {
+			return fmt.Errorf("%w: %v", dao.ErrConcurrentUpdate, err)
+		}
+	}
+
+	return err
+}
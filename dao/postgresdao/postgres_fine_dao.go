@@ -0,0 +1,182 @@
+package postgresdao
+
+import (
+	"database/sql"
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fineColumns lists the columns of the fines table in the order expected by scanFine
+const fineColumns = "id, customer_id, isbn, days_overdue, amount, state, time_created, time_updated"
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type PostgresFineDAO struct {
+	db *sql.DB
+}
+
+func (d *PostgresFineDAO) Create(ctx context.Context, newFine *models.Fine) error {
+	return createFine(ctx, d.db, newFine)
+}
+
+// createFine inserts newFine through q, which is either the connection pool or the transaction of a book update
+func createFine(ctx context.Context, q querier, newFine *models.Fine) error {
+	query := "INSERT INTO fines (" + fineColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+	// Times are truncated before being sent, since PostgreSQL would round them to the nearest microsecond instead
+	dao.NormalizeFineTimes(newFine)
+
+	_, err := q.ExecContext(ctx, query, newFine.ID, newFine.CustomerID, newFine.ISBN, newFine.DaysOverdue, newFine.Amount, newFine.State, newFine.TimeCreated, newFine.TimeUpdated)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return dao.ErrFineAlreadyExists
+		}
+		return fmt.Errorf("error adding new fine to database: %w", err)
+	}
+
+	return nil
+}
+
+func (d *PostgresFineDAO) Read(ctx context.Context, id string) (*models.Fine, error) {
+	query := "SELECT " + fineColumns + " FROM fines WHERE id = $1"
+
+	retrievedFine, err := scanFine(d.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("error reading fine: %w", err)
+	}
+
+	return retrievedFine, nil
+}
+
+func (d *PostgresFineDAO) ReadByCustomer(ctx context.Context, customerID string) ([]*models.Fine, error) {
+	return readFinesByCustomer(ctx, d.db, customerID)
+}
+
+// readFinesByCustomer selects the fines of the customer through q, which is either the connection pool or the transaction of a book update
+func readFinesByCustomer(ctx context.Context, q querier, customerID string) ([]*models.Fine, error) {
+	query := "SELECT " + fineColumns + " FROM fines WHERE customer_id = $1 ORDER BY time_created ASC, id ASC"
+
+	rows, err := q.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	retrievedFines := make([]*models.Fine, 0)
+
+	for rows.Next() {
+		nextFine, err := scanFine(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading fine: %w", err)
+		}
+
+		retrievedFines = append(retrievedFines, nextFine)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over fines: %w", err)
+	}
+
+	return retrievedFines, nil
+}
+
+// UpdateAtomically locks the fine's row with SELECT ... FOR UPDATE for the duration of a transaction, so concurrent updates to the same fine are applied one after the other
+func (d *PostgresFineDAO) UpdateAtomically(ctx context.Context, id string, modify func(currentFine *models.Fine) (*models.Fine, error)) (*models.Fine, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once the transaction has been committed
+
+	query := "SELECT " + fineColumns + " FROM fines WHERE id = $1 FOR UPDATE"
+	currentFine, err := scanFine(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, dao.ErrFineNotFound
+		}
+
+		return nil, mapLockError(fmt.Errorf("error reading fine for update: %w", err))
+	}
+
+	updatedFine, err := modify(currentFine.Copy())
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedFine == nil || updatedFine.ID == nil || *updatedFine.ID != id {
+		return nil, errors.New("error updating fine: modify must return the fine it was given")
+	}
+
+	dao.NormalizeFineTimes(updatedFine)
+
+	query = "UPDATE fines SET state = $1, time_updated = $2 WHERE id = $3"
+	if _, err := tx.ExecContext(ctx, query, updatedFine.State, updatedFine.TimeUpdated, updatedFine.ID); err != nil {
+		return nil, mapLockError(fmt.Errorf("error updating fine: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, mapLockError(fmt.Errorf("error committing update: %w", err))
+	}
+
+	return updatedFine, nil
+}
+
+// scanFine reads a single row selected with fineColumns into a fine. sql.ErrNoRows is returned unwrapped so callers can detect a missing fine
+func scanFine(row rowScanner) (*models.Fine, error) {
+	retrievedFine := &models.Fine{
+		ID: new(string),
+		CustomerID: new(string),
+		ISBN: new(string),
+		DaysOverdue: new(int),
+		Amount: new(int),
+		State: new(string),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	}
+	retrievedTimeCreated := new(sql.NullTime)
+	retrievedTimeUpdated := new(sql.NullTime)
+
+	err := row.Scan(
+		retrievedFine.ID,
+		retrievedFine.CustomerID,
+		retrievedFine.ISBN,
+		retrievedFine.DaysOverdue,
+		retrievedFine.Amount,
+		retrievedFine.State,
+		retrievedTimeCreated,
+		retrievedTimeUpdated,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// timestamptz values are returned in the session time zone, so normalize them to UTC
+	if retrievedTimeCreated.Valid {
+		timeCreated := retrievedTimeCreated.Time.UTC()
+		retrievedFine.TimeCreated = &timeCreated
+	}
+
+	if retrievedTimeUpdated.Valid {
+		timeUpdated := retrievedTimeUpdated.Time.UTC()
+		retrievedFine.TimeUpdated = &timeUpdated
+	}
+
+	return retrievedFine, nil
+}
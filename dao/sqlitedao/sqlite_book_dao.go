@@ -0,0 +1,427 @@
+package sqlitedao
+
+import (
+	"database/sql"
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// timeFormat is a fixed-width UTC layout with nanosecond precision, so that times stored as text sort chronologically
+const timeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// bookColumns lists the columns of the Books table in the order expected by scanBook
+const bookColumns = "ISBN, State, OnHoldCustomerID, CheckedOutCustomerID, TimeCreated, TimeUpdated, TimeDue, RenewalCount, HoldQueue, HoldExpiry, Version"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type SQLiteBookDAO struct {
+	db *sql.DB
+}
+
+// formatTime converts an optional time to the text stored in the database
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	formattedTime := dao.NormalizeTime(t).Format(timeFormat)
+	return &formattedTime
+}
+
+func (d *SQLiteBookDAO) Create(ctx context.Context, newBook *models.Book) error {
+	query := "INSERT INTO Books (" + bookColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)"
+
+	holdQueue, err := dao.MarshalHoldQueue(newBook.HoldQueue)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, query, newBook.ISBN, newBook.State, newBook.OnHoldCustomerID, newBook.CheckedOutCustomerID, formatTime(newBook.TimeCreated), formatTime(newBook.TimeUpdated), formatTime(newBook.TimeDue), newBook.RenewalCount, holdQueue, formatTime(newBook.HoldExpiry))
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+			return dao.ErrBookAlreadyExists
+		}
+		return fmt.Errorf("error adding new book to database: %w", err)
+	}
+
+	newBook.Version = 1
+	dao.NormalizeBookTimes(newBook)
+	dao.NormalizeHoldQueue(newBook)
+
+	return nil
+}
+
+func (d *SQLiteBookDAO) Delete(ctx context.Context, book *models.Book) error {
+	query := "DELETE FROM Books WHERE ISBN = ?"
+	args := []any{book.ISBN}
+
+	// The version is compared by the DELETE itself, so that no update can slip in between the check and the removal
+	if book.Version != 0 {
+		query += " AND Version = ?"
+		args = append(args, book.Version)
+	}
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error deleting book from database: %w", err)
+	}
+
+	if book.Version != 0 {
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error deleting book from database: %w", err)
+		}
+		if rowsAffected == 0 {
+			return dao.ErrVersionMismatch
+		}
+	}
+
+	return nil
+}
+
+func (d *SQLiteBookDAO) Update(ctx context.Context, book *models.Book) error {
+	query := "UPDATE Books SET State = ?, OnHoldCustomerID = ?, CheckedOutCustomerID = ?, TimeUpdated = ?, TimeDue = ?, RenewalCount = ?, HoldQueue = ?, HoldExpiry = ?, Version = Version + 1 WHERE ISBN = ? RETURNING Version"
+
+	var version int64
+	holdQueue, err := dao.MarshalHoldQueue(book.HoldQueue)
+	if err != nil {
+		return err
+	}
+
+	err = d.db.QueryRowContext(ctx, query, book.State, book.OnHoldCustomerID, book.CheckedOutCustomerID, formatTime(book.TimeUpdated), formatTime(book.TimeDue), book.RenewalCount, holdQueue, formatTime(book.HoldExpiry), book.ISBN).Scan(&version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return dao.ErrBookNotFound
+		}
+		return fmt.Errorf("error updating book: %w", err)
+	}
+
+	book.Version = version
+	dao.NormalizeBookTimes(book)
+	dao.NormalizeHoldQueue(book)
+
+	return nil
+}
+
+// UpdateAtomically is UpdateAtomicallyWithFines for a modify that has no use for the fines
+func (d *SQLiteBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	return d.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, _ dao.FineTx) (*models.Book, error) {
+		return modify(currentBook)
+	})
+}
+
+// sqliteFineTx reads and creates fines within the transaction of a book update
+type sqliteFineTx struct {
+	ctx context.Context
+	tx *sql.Tx
+}
+
+func (f *sqliteFineTx) ReadByCustomer(customerID string) ([]*models.Fine, error) {
+	return readFinesByCustomer(f.ctx, f.tx, customerID)
+}
+
+func (f *sqliteFineTx) Create(newFine *models.Fine) error {
+	return createFine(f.ctx, f.tx, newFine)
+}
+
+// UpdateAtomicallyWithFines runs the read-modify-write in a transaction. Since the factory uses a single connection, no other statement can run in between.
+// The fines read and created by modify go through the same transaction, as they could not get another connection
+func (d *SQLiteBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once the transaction has been committed
+
+	query := "SELECT " + bookColumns + " FROM Books WHERE ISBN = ?"
+	currentBook, err := scanBook(tx.QueryRowContext(ctx, query, isbn))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, dao.ErrBookNotFound
+		}
+
+		return nil, fmt.Errorf("error reading book for update: %w", err)
+	}
+
+	updatedBook, err := modify(currentBook.Copy(), &sqliteFineTx{ctx: ctx, tx: tx})
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedBook == nil || updatedBook.ISBN == nil || *updatedBook.ISBN != isbn {
+		return nil, errors.New("error updating book: modify must return the book it was given")
+	}
+
+	// Only bump the version if modify changed something, so that a no-op update does not invalidate the client's ETag.
+	// The transaction is committed either way, for the fines modify may have created
+	updatedBook.Version = currentBook.Version
+	dao.NormalizeBookTimes(updatedBook)
+	dao.NormalizeHoldQueue(updatedBook)
+	if !reflect.DeepEqual(updatedBook, currentBook) {
+		updatedBook.Version = currentBook.Version + 1
+
+		holdQueue, err := dao.MarshalHoldQueue(updatedBook.HoldQueue)
+		if err != nil {
+			return nil, err
+		}
+
+		query = "UPDATE Books SET State = ?, OnHoldCustomerID = ?, CheckedOutCustomerID = ?, TimeUpdated = ?, TimeDue = ?, RenewalCount = ?, HoldQueue = ?, HoldExpiry = ?, Version = ? WHERE ISBN = ?"
+		if _, err := tx.ExecContext(ctx, query, updatedBook.State, updatedBook.OnHoldCustomerID, updatedBook.CheckedOutCustomerID, formatTime(updatedBook.TimeUpdated), formatTime(updatedBook.TimeDue), updatedBook.RenewalCount, holdQueue, formatTime(updatedBook.HoldExpiry), updatedBook.Version, updatedBook.ISBN); err != nil {
+			return nil, fmt.Errorf("error updating book: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing update: %w", err)
+	}
+
+	return updatedBook, nil
+}
+
+func (d *SQLiteBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM Books WHERE ISBN = ?"
+
+	retrievedIndividualBook, err := scanBook(d.db.QueryRowContext(ctx, query, isbn))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("error: %w", err)
+	}
+
+	return retrievedIndividualBook, nil
+}
+
+func (d *SQLiteBookDAO) ReadAll(ctx context.Context) ([]*models.Book, error) {
+	return d.queryBooks(ctx, "SELECT " + bookColumns + " FROM Books")
+}
+
+// sortColumns maps the sort fields of a dao.BookQuery to the columns of the Books table
+var sortColumns = map[string]string{
+	dao.SortByISBN: "ISBN",
+	dao.SortByTimeCreated: "TimeCreated",
+	dao.SortByTimeUpdated: "TimeUpdated",
+	dao.SortByTimeDue: "TimeDue",
+}
+
+// Query builds the WHERE, ORDER BY and LIMIT clauses from the query, so that filtering and pagination happen in the database
+func (d *SQLiteBookDAO) Query(ctx context.Context, query dao.BookQuery) ([]*models.Book, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+
+	if query.State != nil {
+		conditions = append(conditions, "State = ?")
+		args = append(args, *query.State)
+	}
+
+	if query.CustomerID != nil {
+		conditions = append(conditions, "(OnHoldCustomerID = ? OR CheckedOutCustomerID = ?)")
+		args = append(args, *query.CustomerID, *query.CustomerID)
+	}
+
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, "TimeCreated > ?")
+		args = append(args, formatTime(query.CreatedAfter))
+	}
+
+	if query.CreatedBefore != nil {
+		conditions = append(conditions, "TimeCreated < ?")
+		args = append(args, formatTime(query.CreatedBefore))
+	}
+
+	if query.UpdatedAfter != nil {
+		conditions = append(conditions, "TimeUpdated > ?")
+		args = append(args, formatTime(query.UpdatedAfter))
+	}
+
+	if query.DueBefore != nil {
+		conditions = append(conditions, "TimeDue < ?")
+		args = append(args, formatTime(query.DueBefore))
+	}
+
+	if query.HoldExpiryBefore != nil {
+		conditions = append(conditions, "HoldExpiry < ?")
+		args = append(args, formatTime(query.HoldExpiryBefore))
+	}
+
+	sortColumn, ok := sortColumns[query.SortBy]
+	if !ok {
+		sortColumn = "ISBN"
+	}
+
+	// The page starts after the last book of the previous page, which the index on the sort column lets SQLite seek to
+	if query.After != nil {
+		placeholder := func(arg any) string {
+			args = append(args, arg)
+			return "?"
+		}
+		conditions = append(conditions, dao.KeysetCondition(query, sortColumn, "ISBN", placeholder, func(t *time.Time) any { return formatTime(t) }))
+	}
+
+	sqlQuery := "SELECT " + bookColumns + " FROM Books"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// SQLite sorts NULLs first in ascending order and last in descending order, as documented on dao.BookQuery
+	direction := "ASC"
+	if query.SortDescending {
+		direction = "DESC"
+	}
+	sqlQuery += fmt.Sprintf(" ORDER BY %s %s, ISBN %s", sortColumn, direction, direction)
+
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	return d.queryBooks(ctx, sqlQuery, args...)
+}
+
+// queryBooks runs a query selecting bookColumns and scans every row into a book
+func (d *SQLiteBookDAO) queryBooks(ctx context.Context, query string, args ...any) ([]*models.Book, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	retrievedBooks := make([]*models.Book, 0)
+
+	for rows.Next() {
+		nextBook, err := scanBook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error: %w", err)
+		}
+
+		retrievedBooks = append(retrievedBooks, nextBook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over books: %w", err)
+	}
+
+	return retrievedBooks, nil
+}
+
+// scanBook reads a single row selected with bookColumns into a book. sql.ErrNoRows is returned unwrapped so callers can detect a missing book
+func scanBook(row rowScanner) (*models.Book, error) {
+	retrievedISBN := new(sql.NullString)
+	retrievedState := new(sql.NullString)
+	retrievedOnHoldCustomerID := new(sql.NullString)
+	retrievedCheckedOutCustomerID := new(sql.NullString)
+	retrievedTimeCreated := new(sql.NullString)
+	retrievedTimeUpdated := new(sql.NullString)
+	retrievedTimeDue := new(sql.NullString)
+	retrievedRenewalCount := new(sql.NullInt64)
+	retrievedHoldQueue := new(sql.NullString)
+	retrievedHoldExpiry := new(sql.NullString)
+	retrievedVersion := new(int64)
+
+	err := row.Scan(
+		retrievedISBN,
+		retrievedState,
+		retrievedOnHoldCustomerID,
+		retrievedCheckedOutCustomerID,
+		retrievedTimeCreated,
+		retrievedTimeUpdated,
+		retrievedTimeDue,
+		retrievedRenewalCount,
+		retrievedHoldQueue,
+		retrievedHoldExpiry,
+		retrievedVersion,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	retrievedBook := &models.Book{
+		ISBN: nil,
+		State: nil,
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: nil,
+		TimeUpdated: nil,
+		TimeDue: nil,
+		RenewalCount: nil,
+		HoldQueue: nil,
+		HoldExpiry: nil,
+		Overdue: false,
+		Version: *retrievedVersion,
+	}
+
+	if retrievedISBN.Valid {
+		retrievedBook.ISBN = &retrievedISBN.String
+	}
+
+	if retrievedState.Valid {
+		retrievedBook.State = &retrievedState.String
+	}
+
+	if retrievedOnHoldCustomerID.Valid {
+		retrievedBook.OnHoldCustomerID = &retrievedOnHoldCustomerID.String
+	}
+
+	if retrievedCheckedOutCustomerID.Valid {
+		retrievedBook.CheckedOutCustomerID = &retrievedCheckedOutCustomerID.String
+	}
+
+	if retrievedTimeCreated.Valid {
+		timeCreated, err := time.Parse(timeFormat, retrievedTimeCreated.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing time created in read: %w", err)
+		}
+		retrievedBook.TimeCreated = &timeCreated
+	}
+
+	if retrievedTimeUpdated.Valid {
+		timeUpdated, err := time.Parse(timeFormat, retrievedTimeUpdated.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing time updated in read: %w", err)
+		}
+		retrievedBook.TimeUpdated = &timeUpdated
+	}
+
+	if retrievedTimeDue.Valid {
+		timeDue, err := time.Parse(timeFormat, retrievedTimeDue.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing time due in read: %w", err)
+		}
+		retrievedBook.TimeDue = &timeDue
+	}
+
+	if retrievedRenewalCount.Valid {
+		renewalCount := int(retrievedRenewalCount.Int64)
+		retrievedBook.RenewalCount = &renewalCount
+	}
+
+	if retrievedHoldExpiry.Valid {
+		holdExpiry, err := time.Parse(timeFormat, retrievedHoldExpiry.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing hold expiry in read: %w", err)
+		}
+		retrievedBook.HoldExpiry = &holdExpiry
+	}
+
+	if retrievedBook.HoldQueue, err = dao.UnmarshalHoldQueue(*retrievedHoldQueue); err != nil {
+		return nil, err
+	}
+
+	return retrievedBook, nil
+}
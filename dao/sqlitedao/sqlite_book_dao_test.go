@@ -0,0 +1,112 @@
+package sqlitedao
+
+import (
+	"example/library_project/dao"
+	"example/library_project/dao/daotest"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSQLiteBookDAO_Persistence ensures books survive closing and re-opening the database file
+func TestSQLiteBookDAO_Persistence(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 123456000, time.UTC)
+	dbPath := filepath.Join(t.TempDir(), "library.db")
+
+	daoFactory := NewSQLiteDAOFactory(dbPath)
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	newBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("01"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	}
+	assert.Nil(t, daoFactory.BookDAO().Create(context.Background(), newBook))
+	assert.Nil(t, daoFactory.Close())
+
+	t.Log("Re-opening the database")
+	daoFactory = NewSQLiteDAOFactory(dbPath)
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer daoFactory.Close()
+
+	retrievedBook, err := daoFactory.BookDAO().Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("01"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+		Version: 1,
+	}, retrievedBook)
+
+	t.Log("Clear removes every book")
+	assert.Nil(t, daoFactory.Clear())
+
+	allBooks, err := daoFactory.BookDAO().ReadAll(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, allBooks)
+}
+
+// TestSQLiteDAOFactory_Conformance runs the shared DAO contract against a fresh database file per test
+func TestSQLiteDAOFactory_Conformance(t *testing.T) {
+	daotest.RunDAOFactoryTests(t, func(t *testing.T) dao.DAOFactory {
+		return NewSQLiteDAOFactory(filepath.Join(t.TempDir(), "library.db"))
+	})
+}
+
+// TestSQLiteDAOFactory_AddsMissingColumns ensures a database file created before the TimeDue column existed is upgraded on Open
+func TestSQLiteDAOFactory_AddsMissingColumns(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	dbPath := filepath.Join(t.TempDir(), "library.db")
+
+	db, err := sql.Open("sqlite", "file:"+dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE Books (ISBN TEXT NOT NULL PRIMARY KEY, State TEXT NOT NULL, OnHoldCustomerID TEXT, CheckedOutCustomerID TEXT, TimeCreated TEXT NOT NULL, TimeUpdated TEXT, Version INTEGER NOT NULL DEFAULT 1)`)
+	assert.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO Books (ISBN, State, TimeCreated) VALUES ('00001', 'available', ?)`, arbitraryTime.Format(timeFormat))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	daoFactory := NewSQLiteDAOFactory(dbPath)
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer daoFactory.Close()
+
+	t.Log("Existing books have no due date")
+	retrievedBook, err := daoFactory.BookDAO().Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, retrievedBook.TimeDue)
+
+	t.Log("Due dates can be stored")
+	retrievedBook.State = utils.ToPtr("checked-out")
+	retrievedBook.CheckedOutCustomerID = utils.ToPtr("01")
+	retrievedBook.TimeDue = utils.ToPtr(arbitraryTime.Add(14 * 24 * time.Hour))
+	assert.Nil(t, daoFactory.BookDAO().Update(context.Background(), retrievedBook))
+
+	retrievedBook, err = daoFactory.BookDAO().Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, arbitraryTime.Add(14 * 24 * time.Hour), *retrievedBook.TimeDue)
+
+	t.Log("Re-opening the upgraded database leaves it as is")
+	assert.Nil(t, daoFactory.Close())
+	assert.Nil(t, daoFactory.Open())
+}
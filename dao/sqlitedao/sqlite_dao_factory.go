@@ -0,0 +1,199 @@
+package sqlitedao
+
+import (
+	"example/library_project/dao"
+
+	"database/sql"
+	_ "modernc.org/sqlite"
+
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// schema creates the tables used by the SQLite DAOs if they do not exist yet.
+// Times are stored as fixed-width UTC text (see timeFormat), so that comparing and sorting them as strings gives the chronological order.
+// Hold queues are stored as JSON text, as encoded by dao.MarshalHoldQueue
+const schema = `
+CREATE TABLE IF NOT EXISTS Books (
+	ISBN TEXT NOT NULL PRIMARY KEY,
+	State TEXT NOT NULL,
+	OnHoldCustomerID TEXT,
+	CheckedOutCustomerID TEXT,
+	TimeCreated TEXT NOT NULL,
+	TimeUpdated TEXT,
+	TimeDue TEXT,
+	RenewalCount INTEGER,
+	HoldQueue TEXT,
+	HoldExpiry TEXT,
+	Version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS Fines (
+	ID TEXT NOT NULL PRIMARY KEY,
+	CustomerID TEXT NOT NULL,
+	ISBN TEXT NOT NULL,
+	DaysOverdue INTEGER NOT NULL,
+	Amount INTEGER NOT NULL,
+	State TEXT NOT NULL,
+	TimeCreated TEXT NOT NULL,
+	TimeUpdated TEXT
+);
+`
+
+// addedColumns lists the columns added to the Books table after it was first created, with their definitions.
+// SQLite has no ADD COLUMN IF NOT EXISTS, so Open adds whichever of them an existing database file is missing
+var addedColumns = []struct{
+	name string
+	definition string
+}{
+	{name: "TimeDue", definition: "TEXT"},
+	{name: "RenewalCount", definition: "INTEGER"},
+	{name: "HoldQueue", definition: "TEXT"},
+	{name: "HoldExpiry", definition: "TEXT"},
+}
+
+// indexes creates the indexes of the Books and Fines tables once every column exists
+const indexes = `
+CREATE INDEX IF NOT EXISTS BooksTimeDueIndex ON Books (State, TimeDue, ISBN);
+CREATE INDEX IF NOT EXISTS BooksHoldExpiryIndex ON Books (State, HoldExpiry, ISBN);
+CREATE INDEX IF NOT EXISTS BooksTimeCreatedIndex ON Books (TimeCreated, ISBN);
+CREATE INDEX IF NOT EXISTS BooksTimeUpdatedIndex ON Books (TimeUpdated, ISBN);
+CREATE INDEX IF NOT EXISTS FinesCustomerIndex ON Fines (CustomerID, TimeCreated, ID);
+`
+
+// SQLiteDAOFactory stores the library in a single local SQLite file, which is created along with its schema on Open if it does not exist.
+// This gives real persistence without running a database server
+type SQLiteDAOFactory struct {
+	db *sql.DB
+	dbPath string
+}
+
+// NewSQLiteDAOFactory returns a factory for the database file at dbPath. The special path ":memory:" keeps the database in memory until Close
+func NewSQLiteDAOFactory(dbPath string) *SQLiteDAOFactory {
+	return &SQLiteDAOFactory{
+		db: nil,
+		dbPath: dbPath,
+	}
+}
+
+func (f *SQLiteDAOFactory) Open() error {
+	// Wait for locks held by other processes instead of failing immediately
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", f.dbPath)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open the database: %w", err)
+	}
+
+	// SQLite allows a single writer at a time. Funnelling every statement through one connection serializes writes inside
+	// this process, so transactions never fail with SQLITE_BUSY, and it keeps ":memory:" databases from being split across connections
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create the database schema: %w", err)
+	}
+
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to update the database schema: %w", err)
+	}
+
+	if _, err := db.Exec(indexes); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create the database indexes: %w", err)
+	}
+
+	f.db = db
+
+	slog.Info("opened the SQLite database", slog.String("path", f.dbPath))
+
+	return nil
+}
+
+// addMissingColumns adds the columns of addedColumns that the Books table does not have yet
+func addMissingColumns(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('Books')")
+	if err != nil {
+		return fmt.Errorf("failed to list the columns of Books: %w", err)
+	}
+	defer rows.Close()
+
+	existingColumns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to list the columns of Books: %w", err)
+		}
+		existingColumns[name] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list the columns of Books: %w", err)
+	}
+
+	// The connection pool holds a single connection, so the rows must be released before running another statement
+	rows.Close()
+
+	for _, column := range addedColumns {
+		if existingColumns[column.name] {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE Books ADD COLUMN %s %s", column.name, column.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", column.name, err)
+		}
+
+		slog.Info("added column to the SQLite database", slog.String("column", column.name))
+	}
+
+	return nil
+}
+
+func (f *SQLiteDAOFactory) Close() error {
+	if f.db == nil {
+		return nil
+	}
+
+	err := f.db.Close()
+	if err != nil {
+		return fmt.Errorf("Failed to close database connection: %w", err)
+	}
+
+	return nil
+}
+
+func (f *SQLiteDAOFactory) BookDAO() dao.BookDAO {
+	return &SQLiteBookDAO{
+		db: f.db,
+	}
+}
+
+func (f *SQLiteDAOFactory) FineDAO() dao.FineDAO {
+	return &SQLiteFineDAO{
+		db: f.db,
+	}
+}
+
+func (f *SQLiteDAOFactory) Ping(ctx context.Context) error {
+	if f.db == nil {
+		return errors.New("database connection is not open")
+	}
+
+	if err := f.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping the database: %w", err)
+	}
+
+	return nil
+}
+
+func (f *SQLiteDAOFactory) Clear() error {
+	_, err := f.db.Exec("DELETE FROM Books; DELETE FROM Fines;")
+	if err != nil {
+		return fmt.Errorf("failed to clear database: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package sqlitedao
+
+import (
+	"database/sql"
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// fineColumns lists the columns of the Fines table in the order expected by scanFine
+const fineColumns = "ID, CustomerID, ISBN, DaysOverdue, Amount, State, TimeCreated, TimeUpdated"
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type SQLiteFineDAO struct {
+	db *sql.DB
+}
+
+func (d *SQLiteFineDAO) Create(ctx context.Context, newFine *models.Fine) error {
+	return createFine(ctx, d.db, newFine)
+}
+
+// createFine inserts newFine through q, which is either the connection pool or the transaction of a book update
+func createFine(ctx context.Context, q querier, newFine *models.Fine) error {
+	query := "INSERT INTO Fines (" + fineColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+	_, err := q.ExecContext(ctx, query, newFine.ID, newFine.CustomerID, newFine.ISBN, newFine.DaysOverdue, newFine.Amount, newFine.State, formatTime(newFine.TimeCreated), formatTime(newFine.TimeUpdated))
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+			return dao.ErrFineAlreadyExists
+		}
+		return fmt.Errorf("error adding new fine to database: %w", err)
+	}
+
+	dao.NormalizeFineTimes(newFine)
+
+	return nil
+}
+
+func (d *SQLiteFineDAO) Read(ctx context.Context, id string) (*models.Fine, error) {
+	query := "SELECT " + fineColumns + " FROM Fines WHERE ID = ?"
+
+	retrievedFine, err := scanFine(d.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("error reading fine: %w", err)
+	}
+
+	return retrievedFine, nil
+}
+
+func (d *SQLiteFineDAO) ReadByCustomer(ctx context.Context, customerID string) ([]*models.Fine, error) {
+	return readFinesByCustomer(ctx, d.db, customerID)
+}
+
+// readFinesByCustomer selects the fines of the customer through q, which is either the connection pool or the transaction of a book update
+func readFinesByCustomer(ctx context.Context, q querier, customerID string) ([]*models.Fine, error) {
+	query := "SELECT " + fineColumns + " FROM Fines WHERE CustomerID = ? ORDER BY TimeCreated ASC, ID ASC"
+
+	rows, err := q.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	retrievedFines := make([]*models.Fine, 0)
+
+	for rows.Next() {
+		nextFine, err := scanFine(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading fine: %w", err)
+		}
+
+		retrievedFines = append(retrievedFines, nextFine)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over fines: %w", err)
+	}
+
+	return retrievedFines, nil
+}
+
+// UpdateAtomically runs the read-modify-write in a transaction. Since the factory uses a single connection, no other statement can run in between
+func (d *SQLiteFineDAO) UpdateAtomically(ctx context.Context, id string, modify func(currentFine *models.Fine) (*models.Fine, error)) (*models.Fine, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once the transaction has been committed
+
+	query := "SELECT " + fineColumns + " FROM Fines WHERE ID = ?"
+	currentFine, err := scanFine(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, dao.ErrFineNotFound
+		}
+
+		return nil, fmt.Errorf("error reading fine for update: %w", err)
+	}
+
+	updatedFine, err := modify(currentFine.Copy())
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedFine == nil || updatedFine.ID == nil || *updatedFine.ID != id {
+		return nil, errors.New("error updating fine: modify must return the fine it was given")
+	}
+
+	dao.NormalizeFineTimes(updatedFine)
+
+	query = "UPDATE Fines SET State = ?, TimeUpdated = ? WHERE ID = ?"
+	if _, err := tx.ExecContext(ctx, query, updatedFine.State, formatTime(updatedFine.TimeUpdated), updatedFine.ID); err != nil {
+		return nil, fmt.Errorf("error updating fine: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing update: %w", err)
+	}
+
+	return updatedFine, nil
+}
+
+// scanFine reads a single row selected with fineColumns into a fine. sql.ErrNoRows is returned unwrapped so callers can detect a missing fine
+func scanFine(row rowScanner) (*models.Fine, error) {
+	retrievedFine := &models.Fine{
+		ID: new(string),
+		CustomerID: new(string),
+		ISBN: new(string),
+		DaysOverdue: new(int),
+		Amount: new(int),
+		State: new(string),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	}
+	retrievedTimeCreated := new(sql.NullString)
+	retrievedTimeUpdated := new(sql.NullString)
+
+	err := row.Scan(
+		retrievedFine.ID,
+		retrievedFine.CustomerID,
+		retrievedFine.ISBN,
+		retrievedFine.DaysOverdue,
+		retrievedFine.Amount,
+		retrievedFine.State,
+		retrievedTimeCreated,
+		retrievedTimeUpdated,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if retrievedTimeCreated.Valid {
+		timeCreated, err := time.Parse(timeFormat, retrievedTimeCreated.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing time created in read: %w", err)
+		}
+		retrievedFine.TimeCreated = &timeCreated
+	}
+
+	if retrievedTimeUpdated.Valid {
+		timeUpdated, err := time.Parse(timeFormat, retrievedTimeUpdated.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing time updated in read: %w", err)
+		}
+		retrievedFine.TimeUpdated = &timeUpdated
+	}
+
+	return retrievedFine, nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"example/library_project/auth"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"fmt"
+)
+
+var forbiddenErr = errors.New("forbidden")
+
+// principal returns the authenticated caller of the request. A request without one is only allowed through, with ok false, when authentication is disabled,
+// so that a route mistakenly left without the authentication middleware refuses every request instead of skipping every authorization check
+func (h *BooksHandler) principal(ctx context.Context) (principal *auth.Principal, ok bool, err error) {
+	principal, ok = auth.PrincipalFromContext(ctx)
+	if !ok && !h.AuthenticationDisabled {
+		return nil, false, fmt.Errorf("This operation requires an authenticated caller: %w", forbiddenErr)
+	}
+
+	return principal, ok, nil
+}
+
+// authorizeRole returns an error wrapping forbiddenErr when the caller was authenticated without role.
+// Requests carry no principal when authentication is disabled, and are then allowed
+func (h *BooksHandler) authorizeRole(ctx context.Context, role string) error {
+	principal, ok, err := h.principal(ctx)
+	if !ok {
+		return err
+	}
+
+	if !principal.HasRole(role) {
+		return fmt.Errorf("This operation requires the %s role: %w", role, forbiddenErr)
+	}
+
+	return nil
+}
+
+// authorizeCustomer returns an error wrapping forbiddenErr when the caller may not act for the customer, as patrons may only act for themselves
+func (h *BooksHandler) authorizeCustomer(ctx context.Context, customerID string) error {
+	principal, ok, err := h.principal(ctx)
+	if !ok {
+		return err
+	}
+
+	if !principal.CanActFor(customerID) {
+		return fmt.Errorf("Patrons may only manage the hold queues and fines of their own customer ID: %w", forbiddenErr)
+	}
+
+	return nil
+}
+
+// authorizeCustomers returns an error wrapping forbiddenErr when the caller may not act for the customer IDs of incomingBook,
+// which are the customers the checkout, placeHold, releaseHold and returnBook transitions act for. Patrons may only act for themselves
+func (h *BooksHandler) authorizeCustomers(ctx context.Context, incomingBook *models.Book) error {
+	principal, ok, err := h.principal(ctx)
+	if !ok {
+		return err
+	}
+
+	for _, customerID := range []*string{incomingBook.CheckedOutCustomerID, incomingBook.OnHoldCustomerID} {
+		if customerID != nil && !principal.CanActFor(*customerID) {
+			return fmt.Errorf("Patrons may only check out, hold, return or release books for their own customer ID: %w", forbiddenErr)
+		}
+	}
+
+	return nil
+}
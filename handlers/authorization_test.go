@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/library_project/auth"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"fmt"
+	"log"
+)
+
+func TestBooksHandler_Authorization(t *testing.T) {
+	arbitraryTimeCreated := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	patron := &auth.Principal{Subject: "patron-42", Method: auth.MethodJWT, Role: auth.RolePatron, CustomerID: "42"}
+	librarian := &auth.Principal{Subject: "front-desk", Method: auth.MethodAPIKey, Role: auth.RoleLibrarian, CustomerID: ""}
+	admin := &auth.Principal{Subject: "catalogue-sync", Method: auth.MethodAPIKey, Role: auth.RoleAdmin, CustomerID: ""}
+
+	tests := []struct{
+		description string
+		principal *auth.Principal
+		authenticationDisabled bool
+		method string
+		path string
+		incomingBook *models.Book
+		expectedStatusCode int
+	}{
+		{
+			description: "Patron checks out a book for themselves",
+			principal: patron,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00001",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("42"), TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 200,
+		},
+		{
+			description: "Patron checks out a book for another customer",
+			principal: patron,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00001",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("43"), TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Patron places a hold for another customer",
+			principal: patron,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00001",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("on-hold"), OnHoldCustomerID: utils.ToPtr("43"), CheckedOutCustomerID: nil, TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Patron returns their own book",
+			principal: patron,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00002",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00002"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("42"), TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 200,
+		},
+		{
+			description: "Patron releases the hold of another customer",
+			principal: patron,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00003",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00003"), State: utils.ToPtr("available"), OnHoldCustomerID: utils.ToPtr("43"), CheckedOutCustomerID: nil, TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Librarian releases the hold of a customer",
+			principal: librarian,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00003",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00003"), State: utils.ToPtr("available"), OnHoldCustomerID: utils.ToPtr("43"), CheckedOutCustomerID: nil, TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 200,
+		},
+		{
+			description: "Librarian checks out a book for a customer",
+			principal: librarian,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00001",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("43"), TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 200,
+		},
+		{
+			description: "Patron creates a book",
+			principal: patron,
+			authenticationDisabled: false,
+			method: "POST",
+			path: "/books",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00004"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Librarian creates a book",
+			principal: librarian,
+			authenticationDisabled: false,
+			method: "POST",
+			path: "/books",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00004"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Admin creates a book",
+			principal: admin,
+			authenticationDisabled: false,
+			method: "POST",
+			path: "/books",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00004"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 201,
+		},
+		{
+			description: "Librarian deletes a book",
+			principal: librarian,
+			authenticationDisabled: false,
+			method: "DELETE",
+			path: "/books/00001",
+			incomingBook: nil,
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Admin deletes a book",
+			principal: admin,
+			authenticationDisabled: false,
+			method: "DELETE",
+			path: "/books/00001",
+			incomingBook: nil,
+			expectedStatusCode: 204,
+		},
+		{
+			description: "Anyone may delete a book when authentication is disabled",
+			principal: nil,
+			authenticationDisabled: true,
+			method: "DELETE",
+			path: "/books/00001",
+			incomingBook: nil,
+			expectedStatusCode: 204,
+		},
+		{
+			description: "Request without a caller deletes a book while authentication is enabled",
+			principal: nil,
+			authenticationDisabled: false,
+			method: "DELETE",
+			path: "/books/00001",
+			incomingBook: nil,
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Request without a caller creates a book while authentication is enabled",
+			principal: nil,
+			authenticationDisabled: false,
+			method: "POST",
+			path: "/books",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00004"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 403,
+		},
+		{
+			description: "Request without a caller checks out a book while authentication is enabled",
+			principal: nil,
+			authenticationDisabled: false,
+			method: "PATCH",
+			path: "/books/00001",
+			incomingBook: &models.Book{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("43"), TimeCreated: nil, TimeUpdated: nil},
+			expectedStatusCode: 403,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		// Every test case starts from the same books
+		daoFactory := inmemorydao.NewInMemoryDAOFactory()
+		if err := daoFactory.Open(); err != nil {
+			log.Fatal("failed to open database connection: ", err)
+		}
+
+		existingBooks := []*models.Book{
+			{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTimeCreated), TimeUpdated: nil},
+			{ISBN: utils.ToPtr("00002"), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("42"), TimeCreated: utils.ToPtr(arbitraryTimeCreated), TimeUpdated: nil},
+			{ISBN: utils.ToPtr("00003"), State: utils.ToPtr("on-hold"), OnHoldCustomerID: utils.ToPtr("43"), CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTimeCreated), TimeUpdated: nil},
+		}
+		for _, existingBook := range existingBooks {
+			if err := daoFactory.BookDAO().Create(context.Background(), existingBook); err != nil {
+				log.Fatal("failed to add book to DAO: ", err)
+			}
+		}
+
+		fixedTimeProvider := &utils.TestingDateTimeProvider{
+			ArbitraryTime: time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC),
+		}
+		h := NewBooksHandler(daoFactory.BookDAO(), fixedTimeProvider)
+		h.AuthenticationDisabled = currentTestCase.authenticationDisabled
+
+		// Stands in for the authentication middleware
+		principal := currentTestCase.principal
+		r := gin.Default()
+		r.Use(func(c *gin.Context) {
+			if principal != nil {
+				c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+			}
+			c.Next()
+		})
+		r.POST("/books", h.CreateBook)
+		r.DELETE("/books/:isbn", h.DeleteBook)
+		r.PATCH("/books/:isbn", h.UpdateBook)
+
+		var body bytes.Buffer
+		if currentTestCase.incomingBook != nil {
+			if err := json.NewEncoder(&body).Encode(currentTestCase.incomingBook); err != nil {
+				log.Fatal("failed to encode book: ", err)
+			}
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(currentTestCase.method, currentTestCase.path, &body)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		if currentTestCase.expectedStatusCode == http.StatusForbidden {
+			var errorResponse models.ErrorResponse
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &errorResponse))
+			assert.NotNil(t, errorResponse.Message)
+		}
+
+		daoFactory.Close()
+	}
+}
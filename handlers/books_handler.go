@@ -3,18 +3,95 @@ package handlers
 import (
 	"example/library_project/utils"
 	"example/library_project/dao"
+
+	"context"
+	"time"
 )
 
+// DefaultLoanPeriod is how long a book may stay checked-out before it is overdue, unless LoanPeriod is set otherwise
+const DefaultLoanPeriod = 14 * 24 * time.Hour
+
+// DefaultMaxRenewals is how many times a loan may be renewed, unless MaxRenewals is set otherwise
+const DefaultMaxRenewals = 2
+
+// DefaultHoldPickupWindow is how long an on-hold book waits to be checked out before its hold expires, unless HoldPickupWindow is set otherwise
+const DefaultHoldPickupWindow = 3 * 24 * time.Hour
+
+// DefaultFineDailyRate is charged for every day a book is returned late, unless FineDailyRate is set otherwise
+const DefaultFineDailyRate = 25
+
+// DefaultFineCap is the most a single late return is fined, unless FineCap is set otherwise
+const DefaultFineCap = 1000
+
+// StateTransitionRecorder is notified of every state transition that UpdateBook passes through the action table, such as for metrics
+type StateTransitionRecorder interface {
+	RecordStateTransition(from string, to string, outcome string)
+}
+
 // BooksHandlers is the struct on which all handler functions are defined as pointer-receiver functions
 type BooksHandler struct {
 	// Books is the library of all the books
 	BookDAOInterface dao.BookDAO
 	DateTimeInterface utils.DateTimeProvider
+
+	// DAOTimeout bounds the time a request may spend in the DAO, across all of its calls. Zero means the DAO is only stopped when the client disconnects
+	DAOTimeout time.Duration
+
+	// MaxBodyBytes bounds the size of the request bodies that are decoded. Zero means no limit
+	MaxBodyBytes int64
+
+	// LoanPeriod is how long after checkout a book is due back, and how much each renewal extends the due date by
+	LoanPeriod time.Duration
+
+	// MaxRenewals is how many times a loan may be renewed. Zero means loans cannot be renewed
+	MaxRenewals int
+
+	// HoldPickupWindow is how long after a book is placed on-hold its hold expires, unless the customer checks it out first
+	HoldPickupWindow time.Duration
+
+	// FineDAOInterface stores the fines charged for late returns. When nil, no fines are charged, checkouts are never refused, and the fines endpoints respond with 501 Not Implemented
+	FineDAOInterface dao.FineDAO
+
+	// FineDailyRate is charged for every day, or part of a day, a book is returned late, in the smallest unit of the currency. Zero disables fines
+	FineDailyRate int
+
+	// FineCap bounds the fine charged for a single late return. Zero means no cap
+	FineCap int
+
+	// FineCheckoutThreshold refuses checkouts to customers whose outstanding fines add up to at least this balance. Zero never refuses checkouts
+	FineCheckoutThreshold int
+
+	// StateTransitions, when not nil, records the outcome of every state transition requested through UpdateBook
+	StateTransitions StateTransitionRecorder
+
+	// AuthenticationDisabled lets the requests without an authenticated caller through the authorization checks. It must only be set when authentication is disabled,
+	// as otherwise a request without a caller means the authentication middleware did not run, and is refused with 403
+	AuthenticationDisabled bool
 }
 
 func NewBooksHandler(bookDAO dao.BookDAO, provider utils.DateTimeProvider) (*BooksHandler) {
 	return &BooksHandler{
 		BookDAOInterface: bookDAO,
 		DateTimeInterface: provider,
+		DAOTimeout: 0,
+		MaxBodyBytes: 0,
+		LoanPeriod: DefaultLoanPeriod,
+		MaxRenewals: DefaultMaxRenewals,
+		HoldPickupWindow: DefaultHoldPickupWindow,
+		FineDAOInterface: nil,
+		FineDailyRate: DefaultFineDailyRate,
+		FineCap: DefaultFineCap,
+		FineCheckoutThreshold: 0,
+		StateTransitions: nil,
+		AuthenticationDisabled: false,
+	}
+}
+
+// daoContext returns the context for the DAO calls made while serving a request, derived from the request's own context so that the calls stop once the client disconnects
+func (h *BooksHandler) daoContext(requestContext context.Context) (context.Context, context.CancelFunc) {
+	if h.DAOTimeout <= 0 {
+		return context.WithCancel(requestContext)
 	}
-}
\ No newline at end of file
+
+	return context.WithTimeout(requestContext, h.DAOTimeout)
+}
@@ -1,14 +1,16 @@
 package handlers
 
 import (
+	"example/library_project/auth"
+	"example/library_project/dao"
 	"example/library_project/models"
-	// "example/library_project/utils"
+	"example/library_project/utils"
 
 	"net/http"
 	"github.com/gin-gonic/gin"
 	// "time"
-	"encoding/json"
 	"errors"
+	"log/slog"
 )
 
 // validateLogicForCreateBook validates requests for the logic specific to creating a new book
@@ -69,52 +71,93 @@ func validateLogicForCreateBook(incomingBook *models.Book) (error) {
 		return errors.New("Client cannot provide time updated when creating a new book.")
 	}
 
+	// Ensure TimeDue is not provided by the client
+	if incomingBook.TimeDue != nil {
+		return errors.New("Client cannot provide time due when creating a new book.")
+	}
+
+	// Ensure RenewalCount is not provided by the client
+	if incomingBook.RenewalCount != nil {
+		return errors.New("Client cannot provide renewal count when creating a new book.")
+	}
+
+	// Ensure HoldExpiry is not provided by the client
+	if incomingBook.HoldExpiry != nil {
+		return errors.New("Client cannot provide hold expiry when creating a new book.")
+	}
+
 	return nil
 }
 
 // CreateBook allows the client to add a new book to the library
 func (h *BooksHandler) CreateBook(c *gin.Context) {
+	// Only admins may add books to the catalogue
+	if err := h.authorizeRole(c.Request.Context(), auth.RoleAdmin); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
 	// Decode JSON to book struct
 	newBook := new(models.Book) // the "new" keyword allocates memory for models.Book, and returns a pointer to it
-	dec := json.NewDecoder(c.Request.Body)
-	if err := dec.Decode(newBook); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"ERROR": err.Error()})
+	if err := h.decodeBook(c, newBook); err != nil {
+		respondWithDecodeError(c, err)
 		return
 	}
 
 	// If fields are not nil, ensure they are within range
 	if err := newBook.Validate(); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"ERROR": err.Error()})
+		respondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Logic validation
 	if err := validateLogicForCreateBook(newBook); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"ERROR": err.Error()})
+		respondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
 	// Make sure ISBN is not already in-use
-	bookWithISBNInUse, err := h.BookDAOInterface.Read(*newBook.ISBN)
+	bookWithISBNInUse, err := h.BookDAOInterface.Read(ctx, *newBook.ISBN)
 
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()})
+		respondWithDAOError(c, ctx, err)
 		return
 	}
 
 	if bookWithISBNInUse != nil {
-		c.IndentedJSON(http.StatusConflict, gin.H{"ERROR": "Book already exists."})
+		respondWithError(c, http.StatusConflict, "Book already exists.")
 		return
 	}
 
 	// Update TimeCreated to now
 	newBook.TimeCreated = h.DateTimeInterface.GetCurrentTime()
 
+	// A book created checked-out starts its loan now, and one created on-hold its pickup window. The overdue flag is computed, so whatever the client sent is dropped
+	if (*newBook.State == "checked-out") {
+		newBook.TimeDue = h.dueTime(newBook.TimeCreated)
+		newBook.RenewalCount = utils.ToPtr(0)
+	} else if (*newBook.State == "on-hold") {
+		newBook.HoldExpiry = h.holdExpiry(newBook.TimeCreated)
+	}
+	newBook.Overdue = false
+
 	// Add the new book to our library
-	if err := h.BookDAOInterface.Create(newBook); err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()})
+	if err := h.BookDAOInterface.Create(ctx, newBook); err != nil {
+		// Another request may have created a book with the same ISBN since it was checked above
+		if errors.Is(err, dao.ErrBookAlreadyExists) {
+			respondWithError(c, http.StatusConflict, "Book already exists.")
+			return
+		}
+		respondWithDAOError(c, ctx, err)
 		return
 	}
 
+	slog.InfoContext(c.Request.Context(), "book created", slog.String("isbn", *newBook.ISBN), slog.String("state", *newBook.State))
+
+	h.setOverdue(newBook)
+	c.Header("ETag", bookETag(newBook))
 	c.IndentedJSON(http.StatusCreated, newBook) // 201 status code if successful
 }
\ No newline at end of file
@@ -14,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"context"
 	"fmt"
 	"log"
 )
@@ -43,7 +44,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 
 	bookDAO := daoFactory.BookDAO()
 
-	bookDAO.Create(existingBook)
+	bookDAO.Create(context.Background(), existingBook)
 
 	fixedTimeProvider := &utils.TestingDateTimeProvider{
 		ArbitraryTime: arbitraryTime,
@@ -51,6 +52,8 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 	
 	h := NewBooksHandler(bookDAO, fixedTimeProvider)
 	
+	h.AuthenticationDisabled = true
+	
 	tests := []struct{
 		description string
 		book *models.Book
@@ -93,6 +96,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("ISBN cannot be the empty string."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -109,6 +113,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Invalid state provided. State must be equal to one of: \"available\", \"on-hold\", or \"checked-out\"."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -125,6 +130,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("On-hold customer ID cannot be the empty string."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -141,6 +147,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Checked-out customer ID cannot be the empty string."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -157,6 +164,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Book already exists."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -173,6 +181,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Missing ISBN in the incoming request."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -189,6 +198,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Missing State in the incoming request."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -205,6 +215,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have an on-hold customer ID when state is available."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -221,6 +232,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have checked-out customer ID when state is available."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -237,6 +249,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have an on-hold customer ID when state is available."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -253,6 +266,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have checked-out customer ID when state is on-hold."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -269,6 +283,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have checked-out customer ID when state is on-hold."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -285,6 +300,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("State provided is on-hold, but no on-hold customer ID is provided."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -301,6 +317,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have checked-out customer ID when state is on-hold."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -317,6 +334,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have on-hold customer ID when state is checked-out."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -333,6 +351,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("State provided is checked-out, but no checked-out customer ID is provided."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -349,6 +368,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Cannot have on-hold customer ID when state is checked-out."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -365,6 +385,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Client cannot provide time created when creating a new book."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -381,6 +402,7 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Client cannot provide time updated when creating a new book."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -397,9 +419,110 @@ func TestBooksHandler_CreateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Client cannot provide time created when creating a new book."),
+				RequestID: nil,
 			},
 		},
-
+		{
+			description: "Time Due is provided",
+			book: &models.Book{
+				ISBN: utils.ToPtr("00000"), 
+				State: utils.ToPtr("checked-out"), 
+				OnHoldCustomerID: nil, 
+				CheckedOutCustomerID: utils.ToPtr("01"), 
+				TimeCreated: nil, 
+				TimeUpdated: nil,
+				TimeDue: utils.ToPtr(time.Now()),
+			}, 
+			expectedStatusCode: 400,
+			expectedBook: nil,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Client cannot provide time due when creating a new book."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "Renewal Count is provided",
+			book: &models.Book{
+				ISBN: utils.ToPtr("00000"), 
+				State: utils.ToPtr("checked-out"), 
+				OnHoldCustomerID: nil, 
+				CheckedOutCustomerID: utils.ToPtr("01"), 
+				TimeCreated: nil, 
+				TimeUpdated: nil,
+				RenewalCount: utils.ToPtr(1),
+			}, 
+			expectedStatusCode: 400,
+			expectedBook: nil,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Client cannot provide renewal count when creating a new book."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "Hold Expiry is provided",
+			book: &models.Book{
+				ISBN: utils.ToPtr("00000"), 
+				State: utils.ToPtr("on-hold"), 
+				OnHoldCustomerID: utils.ToPtr("01"), 
+				CheckedOutCustomerID: nil, 
+				TimeCreated: nil, 
+				TimeUpdated: nil,
+				HoldExpiry: utils.ToPtr(time.Now()),
+			}, 
+			expectedStatusCode: 400,
+			expectedBook: nil,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Client cannot provide hold expiry when creating a new book."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "On-hold book's hold expires one pickup window after it is created",
+			book: &models.Book{
+				ISBN: utils.ToPtr("00010"), 
+				State: utils.ToPtr("on-hold"), 
+				OnHoldCustomerID: utils.ToPtr("01"), 
+				CheckedOutCustomerID: nil, 
+				TimeCreated: nil, 
+				TimeUpdated: nil,
+			}, 
+			expectedStatusCode: 201,
+			expectedBook: &models.Book{
+				ISBN: utils.ToPtr("00010"), 
+				State: utils.ToPtr("on-hold"), 
+				OnHoldCustomerID: utils.ToPtr("01"), 
+				CheckedOutCustomerID: nil, 
+				TimeCreated: utils.ToPtr(arbitraryTime), 
+				TimeUpdated: nil,
+				HoldExpiry: utils.ToPtr(arbitraryTime.Add(DefaultHoldPickupWindow)),
+			},
+			expectedError: nil,
+		},
+		{
+			description: "Checked-out book is due one loan period after it is created, and the overdue flag sent by the client is ignored",
+			book: &models.Book{
+				ISBN: utils.ToPtr("00009"), 
+				State: utils.ToPtr("checked-out"), 
+				OnHoldCustomerID: nil, 
+				CheckedOutCustomerID: utils.ToPtr("01"), 
+				TimeCreated: nil, 
+				TimeUpdated: nil,
+				Overdue: true,
+			}, 
+			expectedStatusCode: 201,
+			expectedBook: &models.Book{
+				ISBN: utils.ToPtr("00009"), 
+				State: utils.ToPtr("checked-out"), 
+				OnHoldCustomerID: nil, 
+				CheckedOutCustomerID: utils.ToPtr("01"), 
+				TimeCreated: utils.ToPtr(arbitraryTime), 
+				TimeUpdated: nil,
+				TimeDue: utils.ToPtr(arbitraryTime.Add(DefaultLoanPeriod)),
+				RenewalCount: utils.ToPtr(0),
+				Overdue: false,
+			},
+			expectedError: nil,
+		},
 	}
 	
 	for _, currentTestCase := range tests {
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithDAOError responds to a DAO call that failed with err: 504 if the DAO ran out of time, 503 if the request was canceled, and 500 otherwise
+func respondWithDAOError(c *gin.Context, ctx context.Context, err error) {
+	// Drivers do not always wrap the context's error, so the context itself is checked as well
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.WarnContext(c.Request.Context(), "the database did not respond in time", slog.String("error", err.Error()))
+		respondWithError(c, http.StatusGatewayTimeout, "The database did not respond in time.")
+		return
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
+		slog.InfoContext(c.Request.Context(), "the request was canceled before the database responded", slog.String("error", err.Error()))
+		respondWithError(c, http.StatusServiceUnavailable, "The request was canceled before the database responded.")
+		return
+	}
+
+	slog.ErrorContext(c.Request.Context(), "the database call failed", slog.String("error", err.Error()))
+	respondWithError(c, http.StatusInternalServerError, err.Error())
+}
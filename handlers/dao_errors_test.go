@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"fmt"
+)
+
+// unresponsiveBookDAO stands in for a database that never answers: every method blocks until its context is done
+type unresponsiveBookDAO struct{}
+
+func (d *unresponsiveBookDAO) wait(ctx context.Context) error {
+	<-ctx.Done()
+	return fmt.Errorf("error querying database: %w", ctx.Err())
+}
+
+func (d *unresponsiveBookDAO) Create(ctx context.Context, newBook *models.Book) error {
+	return d.wait(ctx)
+}
+
+func (d *unresponsiveBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	return nil, d.wait(ctx)
+}
+
+func (d *unresponsiveBookDAO) ReadAll(ctx context.Context) ([]*models.Book, error) {
+	return nil, d.wait(ctx)
+}
+
+func (d *unresponsiveBookDAO) Query(ctx context.Context, query dao.BookQuery) ([]*models.Book, error) {
+	return nil, d.wait(ctx)
+}
+
+func (d *unresponsiveBookDAO) Update(ctx context.Context, book *models.Book) error {
+	return d.wait(ctx)
+}
+
+func (d *unresponsiveBookDAO) Delete(ctx context.Context, book *models.Book) error {
+	return d.wait(ctx)
+}
+
+func (d *unresponsiveBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	return nil, d.wait(ctx)
+}
+
+func (d *unresponsiveBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	return nil, d.wait(ctx)
+}
+
+func TestBooksHandler_DAOTimeout(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(&unresponsiveBookDAO{}, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+	h.DAOTimeout = 10 * time.Millisecond
+
+	r := gin.Default()
+	r.GET("/books", h.GetAllBooks)
+	r.GET("/books/:isbn", h.GetIndividualBook)
+	r.POST("/books", h.CreateBook)
+	r.DELETE("/books/:isbn", h.DeleteBook)
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	tests := []struct{
+		description string
+		method string
+		url string
+		body string
+		cancelRequest bool
+		expectedStatusCode int
+		expectedError *models.ErrorResponse
+	}{
+		{
+			description: "GET /books times out",
+			method: "GET",
+			url: "/books",
+			body: "",
+			cancelRequest: false,
+			expectedStatusCode: 504,
+			expectedError: &models.ErrorResponse{Message: utils.ToPtr("The database did not respond in time."), RequestID: nil},
+		},
+		{
+			description: "GET /books/:isbn times out",
+			method: "GET",
+			url: "/books/00001",
+			body: "",
+			cancelRequest: false,
+			expectedStatusCode: 504,
+			expectedError: &models.ErrorResponse{Message: utils.ToPtr("The database did not respond in time."), RequestID: nil},
+		},
+		{
+			description: "POST /books times out",
+			method: "POST",
+			url: "/books",
+			body: `{"isbn": "00001", "state": "available"}`,
+			cancelRequest: false,
+			expectedStatusCode: 504,
+			expectedError: &models.ErrorResponse{Message: utils.ToPtr("The database did not respond in time."), RequestID: nil},
+		},
+		{
+			description: "DELETE /books/:isbn times out",
+			method: "DELETE",
+			url: "/books/00001",
+			body: "",
+			cancelRequest: false,
+			expectedStatusCode: 504,
+			expectedError: &models.ErrorResponse{Message: utils.ToPtr("The database did not respond in time."), RequestID: nil},
+		},
+		{
+			description: "PATCH /books/:isbn times out",
+			method: "PATCH",
+			url: "/books/00001",
+			body: `{"state": "checked-out", "checkedoutcustomerid": "01"}`,
+			cancelRequest: false,
+			expectedStatusCode: 504,
+			expectedError: &models.ErrorResponse{Message: utils.ToPtr("The database did not respond in time."), RequestID: nil},
+		},
+		{
+			description: "GET /books is canceled by the client",
+			method: "GET",
+			url: "/books",
+			body: "",
+			cancelRequest: true,
+			expectedStatusCode: 503,
+			expectedError: &models.ErrorResponse{Message: utils.ToPtr("The request was canceled before the database responded."), RequestID: nil},
+		},
+		{
+			description: "PATCH /books/:isbn is canceled by the client",
+			method: "PATCH",
+			url: "/books/00001",
+			body: `{"state": "checked-out", "checkedoutcustomerid": "01"}`,
+			cancelRequest: true,
+			expectedStatusCode: 503,
+			expectedError: &models.ErrorResponse{Message: utils.ToPtr("The request was canceled before the database responded."), RequestID: nil},
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if currentTestCase.cancelRequest {
+			cancel()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, currentTestCase.method, currentTestCase.url, strings.NewReader(currentTestCase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+		cancel()
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		// Decode response body into ErrorResponse struct
+		var responseError models.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&responseError); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, currentTestCase.expectedError, &responseError)
+	}
+}
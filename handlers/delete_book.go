@@ -1,30 +1,70 @@
 package handlers
 
 import (
+	"example/library_project/auth"
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"errors"
+	"log/slog"
 	"net/http"
 	"github.com/gin-gonic/gin"
 )
 
 // DeleteBook allows the client to delete a book from the library
 func (h *BooksHandler) DeleteBook(c *gin.Context) {
+	// Only admins may remove books from the catalogue
+	if err := h.authorizeRole(c.Request.Context(), auth.RoleAdmin); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
 	isbn := c.Param("isbn")
 
-	book, err := h.BookDAOInterface.Read(isbn)
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	book, err := h.BookDAOInterface.Read(ctx, isbn)
 
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()}) // 500 status code
+		respondWithDAOError(c, ctx, err) // 500 status code
 		return
 	}
 
+	// If the client made the deletion conditional, make sure it has seen the latest version of the book
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch != "" {
+		if book != nil {
+			h.setOverdue(book)
+		}
+
+		if book == nil || !ifMatchSatisfied(ifMatch, bookETag(book)) {
+			respondWithError(c, http.StatusPreconditionFailed, "The book has been modified since it was last retrieved.")
+			return
+		}
+	}
+
 	if book == nil {
 		c.Status(http.StatusNoContent)
 		return
 	}
 
-	if err := h.BookDAOInterface.Delete(book); err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()})
+	// A conditional deletion only removes the version the client has seen, so that a change made since the book was read is not lost.
+	// Otherwise, the book is removed whatever its version
+	if ifMatch == "" {
+		book = &models.Book{ISBN: book.ISBN}
+	}
+
+	if err := h.BookDAOInterface.Delete(ctx, book); err != nil {
+		if errors.Is(err, dao.ErrVersionMismatch) {
+			respondWithError(c, http.StatusPreconditionFailed, "The book has been modified since it was last retrieved.")
+		} else {
+			respondWithDAOError(c, ctx, err)
+		}
 		return
 	}
 
+	slog.InfoContext(c.Request.Context(), "book deleted", slog.String("isbn", isbn))
+
 	c.Status(http.StatusNoContent)
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package handlers
 import (
 	"testing"
 	// "encoding/json"
+	"example/library_project/dao"
 	"example/library_project/dao/inmemorydao"
 	"example/library_project/models"
 	"example/library_project/utils"
@@ -13,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"context"
 	"fmt"
 	"log"
 )
@@ -42,7 +44,7 @@ func TestBooksHandler_DeleteBook(t *testing.T) {
 
 	bookDAO := daoFactory.BookDAO()
 
-	bookDAO.Create(existingBook1)
+	bookDAO.Create(context.Background(), existingBook1)
 
 	fixedTimeProvider := &utils.TestingDateTimeProvider{
 		ArbitraryTime: arbitraryTime,
@@ -50,6 +52,8 @@ func TestBooksHandler_DeleteBook(t *testing.T) {
 
 	h := NewBooksHandler(bookDAO, fixedTimeProvider)
 
+	h.AuthenticationDisabled = true
+
 
 	tests := []struct{
 		description string
@@ -92,7 +96,7 @@ func TestBooksHandler_DeleteBook(t *testing.T) {
 		if currentTestCase.expectedError == nil {
 			assert.Empty(t, w.Body)
 
-			// deletedBook, err := h.BookDAOInterface.Read(currentTestCase.isbn)
+			// deletedBook, err := h.BookDAOInterface.Read(context.Background(), currentTestCase.isbn)
 			// assert.Nil(t, deletedBook)
 		}
 
@@ -108,4 +112,197 @@ func TestBooksHandler_DeleteBook(t *testing.T) {
 		// 	assert.Equal(t, currentTestCase.expectedError, actualError)
 		// }
 	}
-}
\ No newline at end of file
+}
+func TestBooksHandler_DeleteBook_IfMatch(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	existingBook1 := &models.Book{
+		ISBN: utils.ToPtr("00001"), 
+		State: utils.ToPtr("available"), 
+		OnHoldCustomerID: nil, 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTime), 
+		TimeUpdated: nil,
+	}
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	bookDAO.Create(context.Background(), existingBook1)
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	tests := []struct{
+		description string
+		isbn string
+		ifMatch string
+		expectedStatusCode int
+		expectedToExist bool
+	}{
+		{
+			description: "Stale ETag",
+			isbn: "00001",
+			ifMatch: "\"0\"",
+			expectedStatusCode: 412,
+			expectedToExist: true,
+		},
+		{
+			description: "Weak ETags never match If-Match",
+			isbn: "00001",
+			ifMatch: "W/\"1\"",
+			expectedStatusCode: 412,
+			expectedToExist: true,
+		},
+		{
+			description: "Book not found",
+			isbn: "00002",
+			ifMatch: "*",
+			expectedStatusCode: 412,
+			expectedToExist: false,
+		},
+		{
+			description: "Current ETag",
+			isbn: "00001",
+			ifMatch: "\"1\"",
+			expectedStatusCode: 204,
+			expectedToExist: false,
+		},
+	}
+
+	r := gin.Default()
+	r.DELETE("/books/:isbn", h.DeleteBook)
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		req, err := http.NewRequest("DELETE", "/books/"+currentTestCase.isbn, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", currentTestCase.ifMatch)
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		storedBook, err := bookDAO.Read(context.Background(), currentTestCase.isbn)
+		assert.Nil(t, err)
+		assert.Equal(t, currentTestCase.expectedToExist, storedBook != nil)
+	}
+}
+
+// interleavingBookDAO runs afterRead once a book has been read, standing in for a request that changes the book in between the read and the delete of DeleteBook
+type interleavingBookDAO struct {
+	dao.BookDAO
+	afterRead func()
+}
+
+func (d *interleavingBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	book, err := d.BookDAO.Read(ctx, isbn)
+	if d.afterRead != nil {
+		d.afterRead()
+	}
+	return book, err
+}
+
+func TestBooksHandler_DeleteBook_ConcurrentUpdate(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := &interleavingBookDAO{BookDAO: daoFactory.BookDAO()}
+
+	for _, isbn := range []string{"00001", "00002"} {
+		bookDAO.Create(context.Background(), &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(arbitraryTime),
+			TimeUpdated: nil,
+		})
+	}
+
+	h := NewBooksHandler(bookDAO, &utils.TestingDateTimeProvider{ArbitraryTime: arbitraryTime})
+
+	h.AuthenticationDisabled = true
+
+	r := gin.Default()
+	r.DELETE("/books/:isbn", h.DeleteBook)
+
+	tests := []struct{
+		description string
+		isbn string
+		ifMatch string
+		expectedStatusCode int
+		expectedToExist bool
+	}{
+		{
+			description: "A conditional deletion fails when the book is checked out after it was read",
+			isbn: "00001",
+			ifMatch: "\"1\"",
+			expectedStatusCode: 412,
+			expectedToExist: true,
+		},
+		{
+			description: "An unconditional deletion removes the book whatever its version",
+			isbn: "00002",
+			ifMatch: "",
+			expectedStatusCode: 204,
+			expectedToExist: false,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		isbn := currentTestCase.isbn
+		bookDAO.afterRead = func() {
+			bookDAO.afterRead = nil
+			bookDAO.UpdateAtomically(context.Background(), isbn, func(currentBook *models.Book) (*models.Book, error) {
+				currentBook.State = utils.ToPtr("checked-out")
+				currentBook.CheckedOutCustomerID = utils.ToPtr("01")
+				return currentBook, nil
+			})
+		}
+
+		req, err := http.NewRequest("DELETE", "/books/"+currentTestCase.isbn, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if currentTestCase.ifMatch != "" {
+			req.Header.Set("If-Match", currentTestCase.ifMatch)
+		}
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		storedBook, err := bookDAO.BookDAO.Read(context.Background(), currentTestCase.isbn)
+		assert.Nil(t, err)
+		assert.Equal(t, currentTestCase.expectedToExist, storedBook != nil)
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"example/library_project/logging"
+	"example/library_project/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithError responds with message and, when the request has one, its ID, so that the client can quote it when reporting a problem
+func respondWithError(c *gin.Context, statusCode int, message string) {
+	errorResponse := models.ErrorResponse{
+		Message: &message,
+		RequestID: nil,
+	}
+	if requestID := logging.RequestID(c.Request.Context()); requestID != "" {
+		errorResponse.RequestID = &requestID
+	}
+
+	c.IndentedJSON(statusCode, errorResponse)
+}
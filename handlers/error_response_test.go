@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/logging"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"fmt"
+	"log"
+)
+
+func TestBooksHandler_ErrorResponseRequestID(t *testing.T) {
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC),
+	}
+
+	h := NewBooksHandler(daoFactory.BookDAO(), fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	tests := []struct{
+		description string
+		withMiddleware bool
+		incomingRequestID string
+		expectRequestID bool
+	}{
+		{
+			description: "Propagated request ID is returned in the error",
+			withMiddleware: true,
+			incomingRequestID: "upstream-1234",
+			expectRequestID: true,
+		},
+		{
+			description: "Generated request ID is returned in the error",
+			withMiddleware: true,
+			incomingRequestID: "",
+			expectRequestID: true,
+		},
+		{
+			description: "No request ID without the logging middleware",
+			withMiddleware: false,
+			incomingRequestID: "upstream-1234",
+			expectRequestID: false,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		r := gin.Default()
+		if currentTestCase.withMiddleware {
+			r.Use(logging.Middleware(logging.New(io.Discard, slog.LevelInfo)))
+		}
+		r.GET("/books/:isbn", h.GetIndividualBook)
+
+		req, err := http.NewRequest("GET", "/books/99999", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if currentTestCase.incomingRequestID != "" {
+			req.Header.Set(logging.RequestIDHeader, currentTestCase.incomingRequestID)
+		}
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		// Decode response body into ErrorResponse struct
+		var responseError models.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&responseError); err != nil {
+			t.Fatal(err)
+		}
+
+		if currentTestCase.expectRequestID {
+			assert.Equal(t, &models.ErrorResponse{Message: utils.ToPtr("REQUEST SUCCESSFUL. BOOK NOT FOUND"), RequestID: utils.ToPtr(w.Header().Get(logging.RequestIDHeader))}, &responseError)
+			if currentTestCase.incomingRequestID != "" {
+				assert.Equal(t, currentTestCase.incomingRequestID, *responseError.RequestID)
+			}
+		} else {
+			assert.Equal(t, &models.ErrorResponse{Message: utils.ToPtr("REQUEST SUCCESSFUL. BOOK NOT FOUND"), RequestID: nil}, &responseError)
+		}
+	}
+}
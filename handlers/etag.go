@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"example/library_project/models"
+
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var preconditionFailedErr = errors.New("precondition failed")
+
+// bookETag returns the strong ETag of an individual book, which is derived from the version maintained by the DAO.
+// A book becomes overdue without being modified, so the computed Overdue flag is part of the ETag too
+func bookETag(book *models.Book) string {
+	if book.Overdue {
+		return fmt.Sprintf("\"%d-overdue\"", book.Version)
+	}
+
+	return fmt.Sprintf("\"%d\"", book.Version)
+}
+
+// booksETag returns the strong ETag of a list of books. It changes whenever a book is added to, removed from, or modified in the list,
+// or when the cursor of the next page appears or disappears
+func booksETag(books []*models.Book, nextCursor *string) string {
+	hash := sha256.New()
+	for _, book := range books {
+		fmt.Fprintf(hash, "%s:%d:%t\n", *book.ISBN, book.Version, book.Overdue)
+	}
+	if nextCursor != nil {
+		fmt.Fprintf(hash, "next:%s\n", *nextCursor)
+	}
+
+	return "\"" + hex.EncodeToString(hash.Sum(nil))[:32] + "\""
+}
+
+// ifMatchSatisfied reports whether the If-Match header allows a request to modify a resource with the given current ETag.
+// An empty header is always satisfied. As required by RFC 9110, If-Match uses the strong comparison, so weak ETags never match
+func ifMatchSatisfied(header string, currentETag string) bool {
+	if header == "" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == currentETag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch returns preconditionFailedErr unless the If-Match header allows modifying the current book.
+// The overdue flag is computed on a copy, since anything set on the current book given by UpdateAtomically would count as a change to store
+func (h *BooksHandler) checkIfMatch(ifMatch string, currentBook *models.Book) error {
+	etagBook := currentBook.Copy()
+	h.setOverdue(etagBook)
+
+	if !ifMatchSatisfied(ifMatch, bookETag(etagBook)) {
+		return fmt.Errorf("The book has been modified since it was last retrieved: %w", preconditionFailedErr)
+	}
+
+	return nil
+}
+
+// ifNoneMatchSatisfied reports whether the If-None-Match header matches the current ETag, meaning the client's cached copy is still fresh.
+// As required by RFC 9110, If-None-Match uses the weak comparison, so a "W/" prefix is ignored
+func ifNoneMatchSatisfied(header string, currentETag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == currentETag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"example/library_project/auth"
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newFineID returns a random 128-bit ID, hex-encoded
+func newFineID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("error generating fine ID: %w", err)
+	}
+
+	return hex.EncodeToString(id[:]), nil
+}
+
+// fineFor returns the fine charged for returning the loan at timeReturned, or nil if it was returned on time or fines are disabled.
+// Every day, or part of a day, past the due date is charged FineDailyRate, up to FineCap
+func (h *BooksHandler) fineFor(returnedLoan *models.Book, timeReturned time.Time) (*models.Fine, error) {
+	if h.FineDAOInterface == nil || h.FineDailyRate <= 0 || returnedLoan.TimeDue == nil || !timeReturned.After(*returnedLoan.TimeDue) {
+		return nil, nil
+	}
+
+	late := timeReturned.Sub(*returnedLoan.TimeDue)
+	daysOverdue := int((late + 24*time.Hour - 1) / (24 * time.Hour))
+
+	amount := daysOverdue * h.FineDailyRate
+	if h.FineCap > 0 && amount > h.FineCap {
+		amount = h.FineCap
+	}
+
+	id, err := newFineID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Fine{
+		ID: utils.ToPtr(id),
+		CustomerID: utils.ToPtr(*returnedLoan.CheckedOutCustomerID),
+		ISBN: utils.ToPtr(*returnedLoan.ISBN),
+		DaysOverdue: utils.ToPtr(daysOverdue),
+		Amount: utils.ToPtr(amount),
+		State: utils.ToPtr("outstanding"),
+		TimeCreated: utils.ToPtr(timeReturned),
+		TimeUpdated: nil,
+	}, nil
+}
+
+// chargeFine creates the fine for the loan returned at timeReturned, if it was late, as part of the update that returns the book.
+// It returns the fine created, or nil if there was none to charge
+func (h *BooksHandler) chargeFine(fines dao.FineTx, returnedLoan *models.Book, timeReturned time.Time) (*models.Fine, error) {
+	fine, err := h.fineFor(returnedLoan, timeReturned)
+	if err != nil || fine == nil {
+		return nil, err
+	}
+
+	if err := fines.Create(fine); err != nil {
+		return nil, fmt.Errorf("error charging the fine of an overdue return: %w", err)
+	}
+
+	return fine, nil
+}
+
+// outstandingBalance returns the total of the fines that are neither paid nor waived
+func outstandingBalance(fines []*models.Fine) int {
+	balance := 0
+	for _, fine := range fines {
+		if *fine.State == "outstanding" {
+			balance += *fine.Amount
+		}
+	}
+
+	return balance
+}
+
+// checkoutBalance returns the outstanding balance of the customer checking out incomingBook, or 0 when the request is not a checkout or checkouts are never refused.
+// It reads the fines as part of the update that checks out the book, so that the balance includes every fine charged until then
+func (h *BooksHandler) checkoutBalance(fines dao.FineTx, incomingBook *models.Book) (int, error) {
+	if h.FineDAOInterface == nil || h.FineCheckoutThreshold <= 0 || incomingBook.State == nil || *incomingBook.State != "checked-out" || incomingBook.CheckedOutCustomerID == nil {
+		return 0, nil
+	}
+
+	customerFines, err := fines.ReadByCustomer(*incomingBook.CheckedOutCustomerID)
+	if err != nil {
+		return 0, fmt.Errorf("error reading the fines of the customer: %w", err)
+	}
+
+	return outstandingBalance(customerFines), nil
+}
+
+// refuseCheckout returns an error wrapping conflictErr when a customer owing balance in fines may not check out another book
+func (h *BooksHandler) refuseCheckout(balance int) error {
+	if h.FineCheckoutThreshold > 0 && balance >= h.FineCheckoutThreshold {
+		return fmt.Errorf("Checkout failed as the customer owes %d in fines, which is at or above the limit of %d: %w", balance, h.FineCheckoutThreshold, conflictErr)
+	}
+
+	return nil
+}
+
+// validateLogicForUpdateFine ensures that a request to pay or waive a fine only changes its state
+func validateLogicForUpdateFine(incomingFine *models.Fine, currentFine *models.Fine) (error) {
+	if incomingFine.State == nil {
+		return fmt.Errorf("Expected 'state' to be non-null: %w", invalidRequestErr)
+	}
+
+	if incomingFine.ID != nil && *incomingFine.ID != *currentFine.ID {
+		return fmt.Errorf("'id' cannot be modified: %w", invalidRequestErr)
+	}
+
+	if incomingFine.CustomerID != nil && *incomingFine.CustomerID != *currentFine.CustomerID {
+		return fmt.Errorf("'customerid' cannot be modified: %w", invalidRequestErr)
+	}
+
+	if incomingFine.ISBN != nil && *incomingFine.ISBN != *currentFine.ISBN {
+		return fmt.Errorf("'isbn' cannot be modified: %w", invalidRequestErr)
+	}
+
+	if incomingFine.DaysOverdue != nil && *incomingFine.DaysOverdue != *currentFine.DaysOverdue {
+		return fmt.Errorf("'daysoverdue' cannot be modified: %w", invalidRequestErr)
+	}
+
+	if incomingFine.Amount != nil && *incomingFine.Amount != *currentFine.Amount {
+		return fmt.Errorf("'amount' cannot be modified, as fines are paid in full: %w", invalidRequestErr)
+	}
+
+	if incomingFine.TimeCreated != nil && !incomingFine.TimeCreated.Equal(*currentFine.TimeCreated) {
+		return fmt.Errorf("'timecreated' cannot be modified: %w", invalidRequestErr)
+	}
+
+	if incomingFine.TimeUpdated != nil && (currentFine.TimeUpdated == nil || !incomingFine.TimeUpdated.Equal(*currentFine.TimeUpdated)) {
+		return fmt.Errorf("'timeupdated' cannot be modified: %w", invalidRequestErr)
+	}
+
+	return nil
+}
+
+// settleFine
+	// outstanding --> paid
+	// outstanding --> waived
+	// any state --> the same state (no-op)
+func settleFine(currentFine *models.Fine, incomingState string, h *BooksHandler) (*models.Fine, error) {
+	if incomingState == *currentFine.State {
+		return currentFine, nil
+	}
+
+	if *currentFine.State != "outstanding" {
+		return nil, fmt.Errorf("The fine is already %s: %w", *currentFine.State, conflictErr)
+	}
+
+	if incomingState == "outstanding" {
+		return nil, fmt.Errorf("Invalid state transition requested: %w", conflictErr)
+	}
+
+	*currentFine.State = incomingState
+	currentFine.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+
+	return currentFine, nil
+}
+
+// GetFines allows a customer to see their fines, oldest first, along with the total they have yet to pay
+func (h *BooksHandler) GetFines(c *gin.Context) {
+	customerID := c.Param("customerid")
+
+	if h.FineDAOInterface == nil {
+		respondWithError(c, http.StatusNotImplemented, "Fines are not enabled.")
+		return
+	}
+
+	// Patrons may only see their own fines
+	if err := h.authorizeCustomer(c.Request.Context(), customerID); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	fines, err := h.FineDAOInterface.ReadByCustomer(ctx, customerID)
+
+	if err != nil {
+		respondWithDAOError(c, ctx, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, &models.FineLedger{
+		CustomerID: utils.ToPtr(customerID),
+		Balance: utils.ToPtr(outstandingBalance(fines)),
+		Fines: fines,
+	})
+}
+
+// UpdateFine allows a librarian to record that a fine was paid in full at the desk, or to waive it, by setting its state to "paid" or "waived"
+func (h *BooksHandler) UpdateFine(c *gin.Context) {
+	customerID := c.Param("customerid")
+	fineID := c.Param("fineid")
+
+	if h.FineDAOInterface == nil {
+		respondWithError(c, http.StatusNotImplemented, "Fines are not enabled.")
+		return
+	}
+
+	// Decode JSON to fine struct
+	incomingFine := new(models.Fine)
+	if err := h.decodeFine(c, incomingFine); err != nil {
+		respondWithDecodeError(c, err)
+		return
+	}
+
+	// If fields are not nil, ensure they are within range
+	if err := incomingFine.Validate(); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Only librarians may settle fines. The API takes no payments, so a fine is marked paid by the librarian who took the payment, never by the patron who owes it
+	if err := h.authorizeRole(c.Request.Context(), auth.RoleLibrarian); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	// The state is changed inside UpdateAtomically, so a fine paid and waived at the same time ends up in only one of the two states
+	updatedFine, err := h.FineDAOInterface.UpdateAtomically(ctx, fineID, func(currentFine *models.Fine) (*models.Fine, error) {
+		// Fines of other customers are hidden behind the same response as missing fines
+		if *currentFine.CustomerID != customerID {
+			return nil, dao.ErrFineNotFound
+		}
+
+		if err := validateLogicForUpdateFine(incomingFine, currentFine); err != nil {
+			return nil, err
+		}
+
+		return settleFine(currentFine, *incomingFine.State, h)
+	})
+
+	if err != nil {
+		respondWithUpdateError(c, ctx, err)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "fine updated", slog.String("fine_id", fineID), slog.String("state", *updatedFine.State))
+
+	c.IndentedJSON(http.StatusOK, updatedFine)
+}
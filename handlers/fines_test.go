@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example/library_project/auth"
+	"example/library_project/dao"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"log"
+)
+
+// unwritableFinesBookDAO fails to create any fine through UpdateAtomicallyWithFines, and passes every other call to the embedded BookDAO
+type unwritableFinesBookDAO struct {
+	dao.BookDAO
+}
+
+// unwritableFineTx fails Create, and passes ReadByCustomer to the embedded FineTx
+type unwritableFineTx struct {
+	dao.FineTx
+}
+
+func (f *unwritableFineTx) Create(newFine *models.Fine) error {
+	return errors.New("connection reset")
+}
+
+func (d *unwritableFinesBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	return d.BookDAO.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		return modify(currentBook, &unwritableFineTx{FineTx: fines})
+	})
+}
+
+func TestBooksHandler_Fines(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	for _, isbn := range []string{"00001", "00002", "00003"} {
+		bookDAO.Create(context.Background(), &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(arbitraryTime),
+			TimeUpdated: nil,
+		})
+	}
+
+	// The tests move the clock forward by changing ArbitraryTime
+	timeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, timeProvider)
+
+	h.AuthenticationDisabled = true
+	h.FineDAOInterface = daoFactory.FineDAO()
+
+	// Stands in for the authentication middleware, for the requests made as a patron or a librarian
+	var principal *auth.Principal
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		if principal != nil {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		}
+		c.Next()
+	})
+	r.PATCH("/books/:isbn", h.UpdateBook)
+	r.GET("/customers/:customerid/fines", h.GetFines)
+	r.PATCH("/customers/:customerid/fines/:fineid", h.UpdateFine)
+
+	request := func(method string, path string, body any) *httptest.ResponseRecorder {
+		var bodyBuffer bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&bodyBuffer).Encode(body)
+		}
+
+		req, err := http.NewRequest(method, path, &bodyBuffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	updateBook := func(isbn string, state string, customerID string) *httptest.ResponseRecorder {
+		return request("PATCH", "/books/"+isbn, &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr(state),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: utils.ToPtr(customerID),
+			TimeCreated: nil,
+			TimeUpdated: nil,
+		})
+	}
+
+	// borrow checks out the book at the current time, and returns it after the given time has passed
+	borrow := func(isbn string, customerID string, loanDuration time.Duration) time.Time {
+		assert.Equal(t, http.StatusOK, updateBook(isbn, "checked-out", customerID).Code)
+		timeProvider.ArbitraryTime = timeProvider.ArbitraryTime.Add(loanDuration)
+		assert.Equal(t, http.StatusOK, updateBook(isbn, "available", customerID).Code)
+		return timeProvider.ArbitraryTime
+	}
+
+	readLedger := func(customerID string) *models.FineLedger {
+		w := request("GET", "/customers/"+customerID+"/fines", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		ledger := new(models.FineLedger)
+		if err := json.NewDecoder(w.Body).Decode(ledger); err != nil {
+			t.Fatal(err)
+		}
+		return ledger
+	}
+
+	updateFine := func(customerID string, fineID string, fine *models.Fine) *httptest.ResponseRecorder {
+		return request("PATCH", "/customers/"+customerID+"/fines/"+fineID, fine)
+	}
+
+	t.Log("Returning a book on time, or exactly when it is due, charges no fine")
+	borrow("00001", "01", DefaultLoanPeriod/2)
+	borrow("00001", "01", DefaultLoanPeriod)
+	assert.Equal(t, &models.FineLedger{CustomerID: utils.ToPtr("01"), Balance: utils.ToPtr(0), Fines: []*models.Fine{}}, readLedger("01"))
+
+	t.Log("Every day, or part of a day, past the due date is charged the daily rate")
+	lateReturn := borrow("00001", "01", DefaultLoanPeriod + 2*24*time.Hour + time.Hour)
+
+	t.Log("The fine of a single return is capped")
+	cappedReturn := borrow("00002", "01", DefaultLoanPeriod + 100*24*time.Hour)
+
+	ledger := readLedger("01")
+	assert.Equal(t, 75 + DefaultFineCap, *ledger.Balance)
+	assert.Len(t, ledger.Fines, 2)
+
+	lateFine, cappedFine := ledger.Fines[0], ledger.Fines[1]
+	assert.Equal(t, &models.Fine{
+		ID: lateFine.ID,
+		CustomerID: utils.ToPtr("01"),
+		ISBN: utils.ToPtr("00001"),
+		DaysOverdue: utils.ToPtr(3),
+		Amount: utils.ToPtr(3 * DefaultFineDailyRate),
+		State: utils.ToPtr("outstanding"),
+		TimeCreated: utils.ToPtr(lateReturn),
+		TimeUpdated: nil,
+	}, lateFine)
+	assert.Equal(t, "00002", *cappedFine.ISBN)
+	assert.Equal(t, 100, *cappedFine.DaysOverdue)
+	assert.Equal(t, DefaultFineCap, *cappedFine.Amount)
+	assert.Equal(t, cappedReturn, *cappedFine.TimeCreated)
+
+	t.Log("Customers owing at least the checkout threshold may not check out another book")
+	h.FineCheckoutThreshold = DefaultFineCap
+	w := updateBook("00003", "checked-out", "01")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	refusedBook, err := bookDAO.Read(context.Background(), "00003")
+	assert.Nil(t, err)
+	assert.Equal(t, "available", *refusedBook.State)
+	assert.Equal(t, http.StatusOK, updateBook("00003", "checked-out", "02").Code)
+
+	t.Log("Patrons may only see their own fines")
+	principal = &auth.Principal{Subject: "patron-02", Method: auth.MethodJWT, Role: auth.RolePatron, CustomerID: "02"}
+	w = request("GET", "/customers/01/fines", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	t.Log("Patrons may neither mark their fines paid nor waive them")
+	principal = &auth.Principal{Subject: "patron-01", Method: auth.MethodJWT, Role: auth.RolePatron, CustomerID: "01"}
+	assert.Equal(t, http.StatusOK, request("GET", "/customers/01/fines", nil).Code)
+	w = updateFine("01", *cappedFine.ID, &models.Fine{State: utils.ToPtr("paid")})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = updateFine("01", *cappedFine.ID, &models.Fine{State: utils.ToPtr("waived")})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 75 + DefaultFineCap, *readLedger("01").Balance)
+
+	t.Log("The fines of a customer cannot be reached through another customer ID")
+	principal = &auth.Principal{Subject: "front-desk", Method: auth.MethodAPIKey, Role: auth.RoleLibrarian, CustomerID: ""}
+	w = updateFine("02", *lateFine.ID, &models.Fine{State: utils.ToPtr("paid")})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	t.Log("Librarians may record that a fine was paid")
+	paymentTime := timeProvider.ArbitraryTime.Add(time.Hour)
+	timeProvider.ArbitraryTime = paymentTime
+	w = updateFine("01", *cappedFine.ID, &models.Fine{State: utils.ToPtr("paid")})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	paidFine := new(models.Fine)
+	if err := json.NewDecoder(w.Body).Decode(paidFine); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "paid", *paidFine.State)
+	assert.Equal(t, paymentTime, *paidFine.TimeUpdated)
+
+	t.Log("Paying the same fine again is a no-op")
+	w = updateFine("01", *cappedFine.ID, &models.Fine{State: utils.ToPtr("paid")})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 75, *readLedger("01").Balance)
+
+	t.Log("Fines are paid in full, and only their state may be changed")
+	w = updateFine("01", *lateFine.ID, &models.Fine{State: utils.ToPtr("paid"), Amount: utils.ToPtr(25)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = updateFine("01", *lateFine.ID, &models.Fine{Amount: lateFine.Amount})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = updateFine("01", *lateFine.ID, &models.Fine{State: utils.ToPtr("forgiven")})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = updateFine("01", "f0", &models.Fine{State: utils.ToPtr("paid")})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	t.Log("Librarians may waive fines")
+	w = updateFine("01", *lateFine.ID, &models.Fine{State: utils.ToPtr("waived")})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Log("Settled fines cannot be settled differently, nor reopened")
+	w = updateFine("01", *lateFine.ID, &models.Fine{State: utils.ToPtr("paid")})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = updateFine("01", *cappedFine.ID, &models.Fine{State: utils.ToPtr("outstanding")})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	ledger = readLedger("01")
+	assert.Equal(t, 0, *ledger.Balance)
+	assert.Equal(t, "waived", *ledger.Fines[0].State)
+	assert.Equal(t, "paid", *ledger.Fines[1].State)
+	principal = nil
+
+	t.Log("Customers who settled their fines may check out again")
+	assert.Equal(t, http.StatusOK, updateBook("00001", "checked-out", "01").Code)
+}
+
+func TestBooksHandler_Fines_ChargedWithReturn(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	bookDAO.Create(context.Background(), &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	})
+
+	timeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(&unwritableFinesBookDAO{BookDAO: bookDAO}, timeProvider)
+
+	h.AuthenticationDisabled = true
+	h.FineDAOInterface = daoFactory.FineDAO()
+
+	r := gin.Default()
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	updateBook := func(state string) *httptest.ResponseRecorder {
+		bookJSON, _ := json.Marshal(&models.Book{
+			ISBN: utils.ToPtr("00001"),
+			State: utils.ToPtr(state),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: utils.ToPtr("01"),
+			TimeCreated: nil,
+			TimeUpdated: nil,
+		})
+
+		req, err := http.NewRequest("PATCH", "/books/00001", bytes.NewBuffer(bookJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Log("Books returned on time are returned, as they charge no fine")
+	assert.Equal(t, http.StatusOK, updateBook("checked-out").Code)
+	assert.Equal(t, http.StatusOK, updateBook("available").Code)
+
+	t.Log("A late return fails when its fine cannot be charged, and the book stays checked out so that the return can be retried")
+	assert.Equal(t, http.StatusOK, updateBook("checked-out").Code)
+	timeProvider.ArbitraryTime = timeProvider.ArbitraryTime.Add(DefaultLoanPeriod + time.Hour)
+	assert.Equal(t, http.StatusInternalServerError, updateBook("available").Code)
+
+	retrievedBook, err := bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "checked-out", *retrievedBook.State)
+
+	customerFines, err := daoFactory.FineDAO().ReadByCustomer(context.Background(), "01")
+	assert.Nil(t, err)
+	assert.Empty(t, customerFines)
+}
+
+func TestBooksHandler_Fines_NotConfigured(t *testing.T) {
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	h := NewBooksHandler(daoFactory.BookDAO(), &utils.TestingDateTimeProvider{ArbitraryTime: time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)})
+
+	h.AuthenticationDisabled = true
+
+	r := gin.Default()
+	r.GET("/customers/:customerid/fines", h.GetFines)
+	r.PATCH("/customers/:customerid/fines/:fineid", h.UpdateFine)
+
+	t.Log("Without a fine DAO, the fines endpoints are not implemented")
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/customers/01/fines", nil),
+		httptest.NewRequest("PATCH", "/customers/01/fines/1", bytes.NewBufferString(`{"state": "paid"}`)),
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+		actualError := new(models.ErrorResponse)
+		if err := json.NewDecoder(w.Body).Decode(actualError); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Fines are not enabled.", *actualError.Message)
+	}
+}
@@ -1,18 +1,240 @@
 package handlers
 
 import (	
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
-// GetAllBooks allows the client to get all of the books in the library
+// defaultPageSize is the number of books returned by GetAllBooks when the client provides a cursor but no limit, and maxPageSize is the largest limit allowed
+const defaultPageSize = 100
+const maxPageSize = 1000
+
+// bookCursor is the content of the opaque cursor handed to the client to fetch the next page of books.
+// It holds the sort key of the last book of the page, so that the next page starts right after that book even if books were created, deleted or updated in between
+type bookCursor struct {
+	// Sort is the sort order the cursor was made for, as given in the sort query parameter
+	Sort string `json:"s"`
+
+	SortTime *time.Time `json:"t,omitempty"`
+	ISBN string `json:"i"`
+}
+
+// sortParameter returns the sort order of the query as it is written in the sort query parameter
+func sortParameter(query *dao.BookQuery) string {
+	if query.SortDescending {
+		return "-" + query.SortBy
+	}
+	return query.SortBy
+}
+
+// encodeCursor returns the cursor of the page that starts after the given book
+func encodeCursor(query *dao.BookQuery, lastBook *models.Book) string {
+	key := dao.KeyOf(lastBook, query.SortBy)
+
+	cursorJSON, _ := json.Marshal(bookCursor{Sort: sortParameter(query), SortTime: key.SortTime, ISBN: key.ISBN})
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+// decodeCursor returns the key the page given by the cursor starts after. The cursor must have been made for the sort order of the query
+func decodeCursor(encodedCursor string, query *dao.BookQuery) (*dao.BookKey, error) {
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(encodedCursor)
+	if err != nil {
+		return nil, errors.New("Invalid 'cursor'.")
+	}
+
+	cursor := bookCursor{}
+	if err := json.Unmarshal(cursorJSON, &cursor); err != nil || cursor.ISBN == "" || cursor.Sort != sortParameter(query) {
+		return nil, errors.New("Invalid 'cursor'.")
+	}
+
+	// Only the books sorted by ISBN, or by a time they may not have, can have no sort time
+	if (cursor.SortTime == nil && query.SortBy == dao.SortByTimeCreated) || (cursor.SortTime != nil && query.SortBy == dao.SortByISBN) {
+		return nil, errors.New("Invalid 'cursor'.")
+	}
+
+	return &dao.BookKey{SortTime: cursor.SortTime, ISBN: cursor.ISBN}, nil
+}
+
+// parseTimeParameter parses an optional RFC 3339 query parameter
+func parseTimeParameter(c *gin.Context, name string) (*time.Time, error) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return nil, nil
+	}
+
+	parsedTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("Expected '%s' to be an RFC 3339 time, such as 2023-02-01T01:30:00Z.", name)
+	}
+
+	return &parsedTime, nil
+}
+
+// parseBookQuery converts the query parameters of GetAllBooks into a dao.BookQuery
+func parseBookQuery(c *gin.Context) (dao.BookQuery, error) {
+	query := dao.BookQuery{
+		SortBy: dao.SortByISBN,
+	}
+
+	// State
+	if state, ok := c.GetQuery("state"); ok {
+		if err := (&models.Book{State: &state}).Validate(); err != nil {
+			return query, err
+		}
+		query.State = &state
+	}
+
+	// Customer
+	var err error
+	if query.CustomerID, err = parseCustomerParameter(c); err != nil {
+		return query, err
+	}
+
+	// Times
+	if query.CreatedAfter, err = parseTimeParameter(c, "created_after"); err != nil {
+		return query, err
+	}
+
+	if query.CreatedBefore, err = parseTimeParameter(c, "created_before"); err != nil {
+		return query, err
+	}
+
+	if query.UpdatedAfter, err = parseTimeParameter(c, "updated_after"); err != nil {
+		return query, err
+	}
+
+	// Sort, where a leading "-" requests descending order
+	if sortBy, ok := c.GetQuery("sort"); ok {
+		query.SortDescending = strings.HasPrefix(sortBy, "-")
+		query.SortBy = strings.TrimPrefix(sortBy, "-")
+
+		if query.SortBy != dao.SortByISBN && query.SortBy != dao.SortByTimeCreated && query.SortBy != dao.SortByTimeUpdated && query.SortBy != dao.SortByTimeDue {
+			return query, errors.New("Invalid 'sort'. Sort must be equal to one of: \"isbn\", \"timecreated\", \"timeupdated\", or \"timedue\", optionally prefixed with \"-\" for descending order.")
+		}
+	}
+
+	if err := parsePage(c, &query); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// parsePage sets the limit of the query, and the key its page starts after, from the limit and cursor query parameters. The sort order of the query must already be set.
+// Without either parameter, the limit is left at zero, so that clients written before pagination still receive every book
+func parsePage(c *gin.Context, query *dao.BookQuery) error {
+	// Limit
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxPageSize {
+			return fmt.Errorf("Expected 'limit' to be an integer between 1 and %d.", maxPageSize)
+		}
+		query.Limit = parsedLimit
+	}
+
+	// Cursor
+	if encodedCursor, ok := c.GetQuery("cursor"); ok {
+		key, err := decodeCursor(encodedCursor, query)
+		if err != nil {
+			return err
+		}
+		query.After = key
+
+		if query.Limit == 0 {
+			query.Limit = defaultPageSize
+		}
+	}
+
+	return nil
+}
+
+// parseCustomerParameter parses the optional customer query parameter
+func parseCustomerParameter(c *gin.Context) (*string, error) {
+	customerID, ok := c.GetQuery("customer")
+	if !ok {
+		return nil, nil
+	}
+
+	if customerID == "" {
+		return nil, errors.New("'customer' cannot be the empty string.")
+	}
+
+	return &customerID, nil
+}
+
+// GetAllBooks allows the client to get the books in the library, either all at once as an array, or one page at a time when the limit or cursor query parameter is given.
+// The books can be filtered with the state, customer, created_after, created_before and updated_after query parameters, and sorted with the sort parameter.
+// A page is returned as a models.BookPage, whose next cursor is also sent in the X-Next-Cursor and Link headers
 func (h *BooksHandler) GetAllBooks(c *gin.Context) {
-	all_books, err := h.BookDAOInterface.ReadAll()
+	query, err := parseBookQuery(c)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondWithBooks(c, query)
+}
+
+// respondWithBooks responds with the books selected by the query along with their ETag. When the query has a limit, they are wrapped in a models.BookPage,
+// and the headers point to the next page
+func (h *BooksHandler) respondWithBooks(c *gin.Context, query dao.BookQuery) {
+	// Request one extra book to find out whether there is a next page
+	pageSize := query.Limit
+	if pageSize > 0 {
+		query.Limit = pageSize + 1
+	}
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	all_books, err := h.BookDAOInterface.Query(ctx, query)
 
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()}) // 500 status code if unsuccessful
+		respondWithDAOError(c, ctx, err) // 500 status code if unsuccessful
+		return
+	}
+
+	var nextCursor *string
+	if pageSize > 0 && len(all_books) > pageSize {
+		all_books = all_books[:pageSize]
+
+		nextCursor = utils.ToPtr(encodeCursor(&query, all_books[pageSize-1]))
+
+		nextURL := *c.Request.URL
+		nextQuery := nextURL.Query()
+		nextQuery.Set("cursor", *nextCursor)
+		nextURL.RawQuery = nextQuery.Encode()
+
+		c.Header("X-Next-Cursor", *nextCursor)
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL.RequestURI()))
+	}
+
+	h.setOverdue(all_books...)
+
+	// Let the client skip downloading the books again if its cached copy is still current
+	etag := booksETag(all_books, nextCursor)
+	c.Header("ETag", etag)
+	if ifNoneMatchSatisfied(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if pageSize == 0 {
+		c.IndentedJSON(http.StatusOK, all_books)
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, all_books)
-}
\ No newline at end of file
+	c.IndentedJSON(http.StatusOK, &models.BookPage{Books: all_books, Next: nextCursor})
+}
@@ -1,18 +1,22 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"example/library_project/dao"
 	"example/library_project/dao/inmemorydao"
 	"example/library_project/models"
 	"example/library_project/utils"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"context"
 	"fmt"
 	"log"
 )
@@ -60,9 +64,9 @@ func TestBooksHandler_GetAllBooks(t *testing.T) {
 
 	bookDAO := daoFactory.BookDAO()
 
-	bookDAO.Create(existingBook1)
-	bookDAO.Create(existingBook2)
-	bookDAO.Create(existingBook3)
+	bookDAO.Create(context.Background(), existingBook1)
+	bookDAO.Create(context.Background(), existingBook2)
+	bookDAO.Create(context.Background(), existingBook3)
 
 	fixedTimeProvider := &utils.TestingDateTimeProvider{
 		ArbitraryTime: arbitraryTime,
@@ -70,6 +74,8 @@ func TestBooksHandler_GetAllBooks(t *testing.T) {
 
 	h := NewBooksHandler(bookDAO, fixedTimeProvider)
 
+	h.AuthenticationDisabled = true
+
 	tests := []struct{
 		description string
 		expectedStatusCode int
@@ -150,4 +156,507 @@ func TestBooksHandler_GetAllBooks(t *testing.T) {
 			assert.Equal(t, currentTestCase.expectedError, actualError)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestBooksHandler_GetAllBooks_ETag(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	bookDAO.Create(context.Background(), &models.Book{
+		ISBN: utils.ToPtr("00001"), 
+		State: utils.ToPtr("available"), 
+		OnHoldCustomerID: nil, 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTime), 
+		TimeUpdated: nil,
+	})
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	r := gin.Default()
+	r.GET("/books", h.GetAllBooks)
+
+	getBooks := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/books", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Log("First request returns the books and an ETag")
+	w := getBooks("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	t.Log("Unchanged books are not sent again")
+	w = getBooks(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body)
+
+	t.Log("Modifying a book changes the ETag")
+	bookDAO.Update(context.Background(), &models.Book{
+		ISBN: utils.ToPtr("00001"), 
+		State: utils.ToPtr("on-hold"), 
+		OnHoldCustomerID: utils.ToPtr("01"), 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTime), 
+		TimeUpdated: utils.ToPtr(arbitraryTime),
+	})
+
+	w = getBooks(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestBooksHandler_GetAllBooks_QueryParameters(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	// Each book is created one hour after the previous one
+	bookDAO.Create(context.Background(), &models.Book{ISBN: utils.ToPtr("00001"), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime), TimeUpdated: nil})
+	bookDAO.Create(context.Background(), &models.Book{ISBN: utils.ToPtr("00002"), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("42"), TimeCreated: utils.ToPtr(arbitraryTime.Add(1 * time.Hour)), TimeUpdated: utils.ToPtr(arbitraryTime.Add(10 * time.Hour))})
+	bookDAO.Create(context.Background(), &models.Book{ISBN: utils.ToPtr("00003"), State: utils.ToPtr("on-hold"), OnHoldCustomerID: utils.ToPtr("42"), CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime.Add(2 * time.Hour)), TimeUpdated: utils.ToPtr(arbitraryTime.Add(5 * time.Hour))})
+	bookDAO.Create(context.Background(), &models.Book{ISBN: utils.ToPtr("00004"), State: utils.ToPtr("on-hold"), OnHoldCustomerID: utils.ToPtr("07"), CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime.Add(3 * time.Hour)), TimeUpdated: nil})
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	tests := []struct{
+		description string
+		queryString string
+		expectedStatusCode int
+		expectedISBNs []string
+		expectedNextCursor bool
+		expectedError *models.ErrorResponse
+	}{
+		{
+			description: "Filter by state",
+			queryString: "state=on-hold",
+			expectedStatusCode: 200,
+			expectedISBNs: []string{"00003", "00004"},
+			expectedNextCursor: false,
+			expectedError: nil,
+		},
+		{
+			description: "Filter by customer",
+			queryString: "customer=42",
+			expectedStatusCode: 200,
+			expectedISBNs: []string{"00002", "00003"},
+			expectedNextCursor: false,
+			expectedError: nil,
+		},
+		{
+			description: "Filter by time created",
+			queryString: "created_after=2023-02-01T02:30:00Z&created_before=2023-02-01T05:00:00Z",
+			expectedStatusCode: 200,
+			expectedISBNs: []string{"00003", "00004"},
+			expectedNextCursor: false,
+			expectedError: nil,
+		},
+		{
+			description: "Filter by time updated excludes books that were never updated",
+			queryString: "updated_after=2023-02-01T00:00:00Z",
+			expectedStatusCode: 200,
+			expectedISBNs: []string{"00002", "00003"},
+			expectedNextCursor: false,
+			expectedError: nil,
+		},
+		{
+			description: "Sort by time updated in descending order",
+			queryString: "sort=-timeupdated",
+			expectedStatusCode: 200,
+			expectedISBNs: []string{"00002", "00003", "00004", "00001"},
+			expectedNextCursor: false,
+			expectedError: nil,
+		},
+		{
+			description: "Sort by time created in ascending order with a limit",
+			queryString: "sort=timecreated&limit=3",
+			expectedStatusCode: 200,
+			expectedISBNs: []string{"00001", "00002", "00003"},
+			expectedNextCursor: true,
+			expectedError: nil,
+		},
+		{
+			description: "Limit equal to the number of matching books",
+			queryString: "limit=4",
+			expectedStatusCode: 200,
+			expectedISBNs: []string{"00001", "00002", "00003", "00004"},
+			expectedNextCursor: false,
+			expectedError: nil,
+		},
+		{
+			description: "Invalid state",
+			queryString: "state=lost",
+			expectedStatusCode: 400,
+			expectedISBNs: nil,
+			expectedNextCursor: false,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Invalid state provided. State must be equal to one of: \"available\", \"on-hold\", or \"checked-out\"."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "Invalid sort",
+			queryString: "sort=state",
+			expectedStatusCode: 400,
+			expectedISBNs: nil,
+			expectedNextCursor: false,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Invalid 'sort'. Sort must be equal to one of: \"isbn\", \"timecreated\", \"timeupdated\", or \"timedue\", optionally prefixed with \"-\" for descending order."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "Invalid limit",
+			queryString: "limit=0",
+			expectedStatusCode: 400,
+			expectedISBNs: nil,
+			expectedNextCursor: false,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Expected 'limit' to be an integer between 1 and 1000."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "Invalid time",
+			queryString: "created_after=yesterday",
+			expectedStatusCode: 400,
+			expectedISBNs: nil,
+			expectedNextCursor: false,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Expected 'created_after' to be an RFC 3339 time, such as 2023-02-01T01:30:00Z."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "Invalid cursor",
+			queryString: "cursor=not-a-cursor",
+			expectedStatusCode: 400,
+			expectedISBNs: nil,
+			expectedNextCursor: false,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Invalid 'cursor'."),
+				RequestID: nil,
+			},
+		},
+		{
+			description: "Cursor made for another sort order",
+			queryString: "sort=-isbn&cursor=eyJzIjoiaXNibiIsImkiOiIwMDAwMSJ9",
+			expectedStatusCode: 400,
+			expectedISBNs: nil,
+			expectedNextCursor: false,
+			expectedError: &models.ErrorResponse{
+				Message: utils.ToPtr("Invalid 'cursor'."),
+				RequestID: nil,
+			},
+		},
+	}
+
+	r := gin.Default()
+	r.GET("/books", h.GetAllBooks)
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		req, err := http.NewRequest("GET", "/books?"+currentTestCase.queryString, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+		assert.Equal(t, currentTestCase.expectedNextCursor, w.Header().Get("X-Next-Cursor") != "")
+
+		if currentTestCase.expectedISBNs != nil {
+			// Only the paginated requests receive their books wrapped in a page
+			actualBooks := make([]*models.Book, 0)
+			dec := json.NewDecoder(w.Body)
+			if strings.Contains(currentTestCase.queryString, "limit=") {
+				actualPage := new(models.BookPage)
+				if err := dec.Decode(actualPage); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, currentTestCase.expectedNextCursor, actualPage.Next != nil)
+				if actualPage.Next != nil {
+					assert.Equal(t, w.Header().Get("X-Next-Cursor"), *actualPage.Next)
+				}
+				actualBooks = actualPage.Books
+			} else if err := dec.Decode(&actualBooks); err != nil {
+				t.Fatal(err)
+			}
+
+			actualISBNs := make([]string, 0)
+			for _, actualBook := range actualBooks {
+				actualISBNs = append(actualISBNs, *actualBook.ISBN)
+			}
+
+			assert.Equal(t, currentTestCase.expectedISBNs, actualISBNs)
+		}
+
+		if currentTestCase.expectedError != nil {
+			// Decode response body into ErrorResponse struct
+			actualError := new(models.ErrorResponse)
+			dec := json.NewDecoder(w.Body)
+			if err := dec.Decode(&actualError); err != nil {
+				t.Fatal(err)
+			}
+
+			// Check if actual error is equal to expected
+			assert.Equal(t, currentTestCase.expectedError, actualError)
+		}
+	}
+}
+
+func TestBooksHandler_GetAllBooks_Pagination(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	numberOfBooks := 25
+	for i := 0; i < numberOfBooks; i++ {
+		bookDAO.Create(context.Background(), &models.Book{ISBN: utils.ToPtr(fmt.Sprintf("%05d", i)), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime), TimeUpdated: nil})
+	}
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	r := gin.Default()
+	r.GET("/books", h.GetAllBooks)
+
+	// Follow the Link header until there are no more pages
+	nextURL := "/books?limit=10&sort=-isbn"
+	actualISBNs := make([]string, 0)
+	numberOfPages := 0
+
+	for nextURL != "" {
+		req, err := http.NewRequest("GET", nextURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		actualPage := new(models.BookPage)
+		if err := json.NewDecoder(w.Body).Decode(actualPage); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, actualBook := range actualPage.Books {
+			actualISBNs = append(actualISBNs, *actualBook.ISBN)
+		}
+		numberOfPages++
+
+		nextURL = ""
+		if link := w.Header().Get("Link"); link != "" {
+			nextURL = strings.TrimSuffix(strings.TrimPrefix(link, "<"), ">; rel=\"next\"")
+			assert.Equal(t, w.Header().Get("X-Next-Cursor"), *actualPage.Next)
+		} else {
+			assert.Nil(t, actualPage.Next)
+		}
+	}
+
+	assert.Equal(t, 3, numberOfPages)
+	assert.Len(t, actualISBNs, numberOfBooks)
+	assert.Equal(t, "00024", actualISBNs[0])
+	assert.Equal(t, "00000", actualISBNs[numberOfBooks-1])
+}
+
+func TestBooksHandler_GetAllBooks_Unpaginated(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	numberOfBooks := defaultPageSize + 50
+	for i := 0; i < numberOfBooks; i++ {
+		bookDAO.Create(context.Background(), &models.Book{ISBN: utils.ToPtr(fmt.Sprintf("%05d", i)), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime), TimeUpdated: nil})
+	}
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	r := gin.Default()
+	r.GET("/books", h.GetAllBooks)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	t.Log("Without limit or cursor, every book is returned as an array, as before pagination")
+	w := get("/books")
+	actualBooks := make([]*models.Book, 0)
+	if err := json.NewDecoder(w.Body).Decode(&actualBooks); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, actualBooks, numberOfBooks)
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+	assert.Empty(t, w.Header().Get("Link"))
+
+	t.Log("A cursor without a limit returns a page of the default size")
+	w = get("/books?cursor=" + encodeCursor(&dao.BookQuery{SortBy: dao.SortByISBN}, actualBooks[9]))
+	actualPage := new(models.BookPage)
+	if err := json.NewDecoder(w.Body).Decode(actualPage); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, actualPage.Books, defaultPageSize)
+	assert.Equal(t, "00010", *actualPage.Books[0].ISBN)
+	assert.NotNil(t, actualPage.Next)
+}
+
+func TestBooksHandler_GetAllBooks_PaginationConcurrentChanges(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	for i := 0; i < 6; i++ {
+		bookDAO.Create(context.Background(), &models.Book{ISBN: utils.ToPtr(fmt.Sprintf("%05d", i)), State: utils.ToPtr("available"), OnHoldCustomerID: nil, CheckedOutCustomerID: nil, TimeCreated: utils.ToPtr(arbitraryTime), TimeUpdated: nil})
+	}
+
+	timeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, timeProvider)
+
+	h.AuthenticationDisabled = true
+
+	r := gin.Default()
+	r.GET("/books", h.GetAllBooks)
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	// getPage returns the ISBNs of the page and the URL of the next one
+	getPage := func(url string) ([]string, string) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		actualPage := new(models.BookPage)
+		if err := json.NewDecoder(w.Body).Decode(actualPage); err != nil {
+			t.Fatal(err)
+		}
+
+		isbns := make([]string, 0)
+		for _, actualBook := range actualPage.Books {
+			isbns = append(isbns, *actualBook.ISBN)
+		}
+
+		return isbns, strings.TrimSuffix(strings.TrimPrefix(w.Header().Get("Link"), "<"), ">; rel=\"next\"")
+	}
+
+	checkOut := func(isbn string) {
+		timeProvider.ArbitraryTime = timeProvider.ArbitraryTime.Add(time.Minute)
+
+		var bodyBuffer bytes.Buffer
+		json.NewEncoder(&bodyBuffer).Encode(&models.Book{ISBN: utils.ToPtr(isbn), State: utils.ToPtr("checked-out"), OnHoldCustomerID: nil, CheckedOutCustomerID: utils.ToPtr("01"), TimeCreated: nil, TimeUpdated: nil})
+
+		req, err := http.NewRequest("PATCH", "/books/"+isbn, &bodyBuffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	t.Log("Deleting a book of a page already read neither skips nor repeats books of the next page")
+	isbns, nextURL := getPage("/books?limit=3")
+	assert.Equal(t, []string{"00000", "00001", "00002"}, isbns)
+	assert.Nil(t, bookDAO.Delete(context.Background(), &models.Book{ISBN: utils.ToPtr("00001")}))
+	isbns, _ = getPage(nextURL)
+	assert.Equal(t, []string{"00003", "00004", "00005"}, isbns)
+
+	t.Log("Updating a book moves it to the front of the pages sorted by descending time updated, without moving the others")
+	checkOut("00002")
+	checkOut("00003")
+	isbns, nextURL = getPage("/books?limit=2&sort=-timeupdated")
+	assert.Equal(t, []string{"00003", "00002"}, isbns)
+	checkOut("00004")
+	isbns, _ = getPage(nextURL)
+	assert.Equal(t, []string{"00005", "00000"}, isbns)
+}
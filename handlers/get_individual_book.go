@@ -8,15 +8,29 @@ import (
 // GetIndividualBook allows the client to get an individual book in the library by its ISBN
 func (h *BooksHandler) GetIndividualBook(c *gin.Context) {
 	isbn := c.Param("isbn")
-	book, err := h.BookDAOInterface.Read(isbn)
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	book, err := h.BookDAOInterface.Read(ctx, isbn)
 
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()}) // 500 status code if unsuccessful
+		respondWithDAOError(c, ctx, err) // 500 status code if unsuccessful
 		return
 	}
 
 	if book == nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"ERROR": "REQUEST SUCCESSFUL. BOOK NOT FOUND"})
+		respondWithError(c, http.StatusNotFound, "REQUEST SUCCESSFUL. BOOK NOT FOUND")
+		return
+	}
+
+	h.setOverdue(book)
+
+	// Let the client skip downloading the book again if its cached copy is still current
+	etag := bookETag(book)
+	c.Header("ETag", etag)
+	if ifNoneMatchSatisfied(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
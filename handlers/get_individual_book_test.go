@@ -13,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"context"
 	"fmt"
 	"log"
 )
@@ -42,7 +43,7 @@ func TestBooksHandler_GetIndividualBook(t *testing.T) {
 
 	bookDAO := daoFactory.BookDAO()
 
-	bookDAO.Create(existingBook1)
+	bookDAO.Create(context.Background(), existingBook1)
 
 	fixedTimeProvider := &utils.TestingDateTimeProvider{
 		ArbitraryTime: arbitraryTime,
@@ -50,6 +51,8 @@ func TestBooksHandler_GetIndividualBook(t *testing.T) {
 
 	h := NewBooksHandler(bookDAO, fixedTimeProvider)
 
+	h.AuthenticationDisabled = true
+
 
 	tests := []struct{
 		description string
@@ -79,6 +82,7 @@ func TestBooksHandler_GetIndividualBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("REQUEST SUCCESSFUL. BOOK NOT FOUND"),
+				RequestID: nil,
 			},
 		},
 	}
@@ -124,4 +128,90 @@ func TestBooksHandler_GetIndividualBook(t *testing.T) {
 			assert.Equal(t, currentTestCase.expectedError, actualError)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestBooksHandler_GetIndividualBook_ETag(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	existingBook1 := &models.Book{
+		ISBN: utils.ToPtr("00001"), 
+		State: utils.ToPtr("available"), 
+		OnHoldCustomerID: nil, 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTime), 
+		TimeUpdated: nil,
+	}
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	bookDAO.Create(context.Background(), existingBook1)
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	tests := []struct{
+		description string
+		ifNoneMatch string
+		expectedStatusCode int
+	}{
+		{
+			description: "No If-None-Match header",
+			ifNoneMatch: "",
+			expectedStatusCode: 200,
+		},
+		{
+			description: "If-None-Match matches the current ETag",
+			ifNoneMatch: "\"1\"",
+			expectedStatusCode: 304,
+		},
+		{
+			description: "If-None-Match matches the current ETag as a weak ETag in a list",
+			ifNoneMatch: "\"7\", W/\"1\"",
+			expectedStatusCode: 304,
+		},
+		{
+			description: "If-None-Match is a stale ETag",
+			ifNoneMatch: "\"0\"",
+			expectedStatusCode: 200,
+		},
+	}
+
+	r := gin.Default()
+	r.GET("/books/:isbn", h.GetIndividualBook)
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		req, err := http.NewRequest("GET", "/books/00001", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if currentTestCase.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", currentTestCase.ifNoneMatch)
+		}
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+		assert.Equal(t, "\"1\"", w.Header().Get("ETag"))
+
+		if currentTestCase.expectedStatusCode == http.StatusNotModified {
+			assert.Empty(t, w.Body)
+		}
+	}
+}
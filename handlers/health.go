@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	statusUp = "up"
+	statusDown = "down"
+)
+
+// HealthCheck checks a single dependency, returning an error if it cannot be reached
+type HealthCheck func(ctx context.Context) error
+
+// HealthHandler serves the liveness and readiness endpoints used by load balancers and orchestrators
+type HealthHandler struct {
+	// Dependencies maps the name reported for each dependency to its check
+	Dependencies map[string]HealthCheck
+
+	// Timeout bounds every check, so that a hung dependency is reported as down instead of hanging the probe
+	Timeout time.Duration
+}
+
+func NewHealthHandler(dependencies map[string]HealthCheck, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{
+		Dependencies: dependencies,
+		Timeout: timeout,
+	}
+}
+
+// Liveness reports that the process is up and serving requests. It does not check any dependency, so that a database outage does not get the process restarted
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, models.HealthResponse{Status: statusUp, Dependencies: nil})
+}
+
+// unavailableError is the error reported for a dependency that is down. The response is served without credentials, so the actual error, which may name hosts, users or drivers, is only logged
+func unavailableError(name string) *string {
+	return utils.ToPtr(name + " unavailable")
+}
+
+// Readiness checks every dependency concurrently, and responds with 503 unless they are all up, so that no traffic is routed to an instance that cannot serve it
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Timeout)
+	defer cancel()
+
+	response := models.HealthResponse{
+		Status: statusUp,
+		Dependencies: make(map[string]models.DependencyStatus, len(h.Dependencies)),
+	}
+
+	type checkResult struct {
+		name string
+		dependencyStatus models.DependencyStatus
+	}
+
+	// Buffered, so that a check still running after the timeout does not block forever
+	results := make(chan checkResult, len(h.Dependencies))
+	start := time.Now()
+	for name, check := range h.Dependencies {
+		go func(name string, check HealthCheck) {
+			dependencyStatus := models.DependencyStatus{
+				Status: statusUp,
+				Duration: "",
+				Error: nil,
+			}
+			if err := check(ctx); err != nil {
+				slog.WarnContext(ctx, "readiness check failed", slog.String("dependency", name), slog.String("error", err.Error()))
+				dependencyStatus.Status = statusDown
+				dependencyStatus.Error = unavailableError(name)
+			}
+			dependencyStatus.Duration = time.Since(start).String()
+
+			results <- checkResult{name: name, dependencyStatus: dependencyStatus}
+		}(name, check)
+	}
+
+collect:
+	for range h.Dependencies {
+		select {
+		case result := <-results:
+			response.Dependencies[result.name] = result.dependencyStatus
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	// Once the timeout fires, select may pick it over a result that is already buffered, so collect the checks that finished before reporting the rest as down
+drain:
+	for {
+		select {
+		case result := <-results:
+			response.Dependencies[result.name] = result.dependencyStatus
+		default:
+			break drain
+		}
+	}
+
+	// Report the checks that did not finish in time as down
+	for name := range h.Dependencies {
+		if _, ok := response.Dependencies[name]; !ok {
+			slog.WarnContext(ctx, "readiness check did not complete in time", slog.String("dependency", name), slog.String("error", ctx.Err().Error()))
+			response.Dependencies[name] = models.DependencyStatus{
+				Status: statusDown,
+				Duration: time.Since(start).String(),
+				Error: unavailableError(name),
+			}
+		}
+	}
+
+	for _, dependencyStatus := range response.Dependencies {
+		if dependencyStatus.Status != statusUp {
+			response.Status = statusDown
+		}
+	}
+
+	if response.Status != statusUp {
+		c.IndentedJSON(http.StatusServiceUnavailable, response)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, response)
+}
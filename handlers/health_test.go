@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example/library_project/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"fmt"
+)
+
+func TestHealthHandler_Liveness(t *testing.T) {
+	// Liveness does not depend on the dependencies being up
+	h := NewHealthHandler(map[string]HealthCheck{
+		"database": func(ctx context.Context) error { return errors.New("connection refused") },
+	}, time.Second)
+
+	r := gin.Default()
+	r.GET("/healthz", h.Liveness)
+
+	req, err := http.NewRequest("GET", "/healthz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, models.HealthResponse{Status: "up", Dependencies: nil}, response)
+}
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ignoresContext := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	tests := []struct{
+		description string
+		dependencies map[string]HealthCheck
+		expectedStatusCode int
+		expectedStatus string
+		expectedDependencyStatuses map[string]string
+		expectedErrors map[string]string
+	}{
+		{
+			description: "Every dependency is up",
+			dependencies: map[string]HealthCheck{"database": up, "cache": up},
+			expectedStatusCode: 200,
+			expectedStatus: "up",
+			expectedDependencyStatuses: map[string]string{"database": "up", "cache": "up"},
+			expectedErrors: map[string]string{"database": "", "cache": ""},
+		},
+		{
+			description: "A dependency is down",
+			dependencies: map[string]HealthCheck{"database": down, "cache": up},
+			expectedStatusCode: 503,
+			expectedStatus: "down",
+			expectedDependencyStatuses: map[string]string{"database": "down", "cache": "up"},
+			expectedErrors: map[string]string{"database": "database unavailable", "cache": ""},
+		},
+		{
+			description: "A dependency does not answer before the timeout",
+			dependencies: map[string]HealthCheck{"database": hung},
+			expectedStatusCode: 503,
+			expectedStatus: "down",
+			expectedDependencyStatuses: map[string]string{"database": "down"},
+			expectedErrors: map[string]string{"database": "database unavailable"},
+		},
+		{
+			description: "A dependency ignoring the timeout does not hang the probe",
+			dependencies: map[string]HealthCheck{"database": ignoresContext},
+			expectedStatusCode: 503,
+			expectedStatus: "down",
+			expectedDependencyStatuses: map[string]string{"database": "down"},
+			expectedErrors: map[string]string{"database": "database unavailable"},
+		},
+		{
+			description: "No dependencies",
+			dependencies: map[string]HealthCheck{},
+			expectedStatusCode: 200,
+			expectedStatus: "up",
+			expectedDependencyStatuses: map[string]string{},
+			expectedErrors: map[string]string{},
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		h := NewHealthHandler(currentTestCase.dependencies, 50*time.Millisecond)
+
+		r := gin.Default()
+		r.GET("/readyz", h.Readiness)
+
+		req, err := http.NewRequest("GET", "/readyz", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		var response models.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, currentTestCase.expectedStatus, response.Status)
+		assert.Len(t, response.Dependencies, len(currentTestCase.expectedDependencyStatuses))
+		for name, expectedDependencyStatus := range currentTestCase.expectedDependencyStatuses {
+			assert.Equal(t, expectedDependencyStatus, response.Dependencies[name].Status)
+			// The actual error of the check is only logged, whether the check failed or timed out
+			if currentTestCase.expectedErrors[name] == "" {
+				assert.Nil(t, response.Dependencies[name].Error)
+			} else if assert.NotNil(t, response.Dependencies[name].Error) {
+				assert.Equal(t, currentTestCase.expectedErrors[name], *response.Dependencies[name].Error)
+			}
+			assert.NotEmpty(t, response.Dependencies[name].Duration)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// holdSweepBatchSize is how many books with an expired hold ReleaseExpiredHolds reads from the DAO at a time
+const holdSweepBatchSize = 100
+
+// isHoldExpired reports whether the book is on-hold and its hold expired before now
+func isHoldExpired(book *models.Book, now time.Time) bool {
+	return book.State != nil && *book.State == "on-hold" && book.HoldExpiry != nil && book.HoldExpiry.Before(now)
+}
+
+// releaseExpiredHold releases the hold on the book if it has expired, through the action table as if the customer had released it.
+// It reports whether the hold was released, which it is not when the book was checked out or its hold released since it was found
+func (h *BooksHandler) releaseExpiredHold(ctx context.Context, isbn string, now time.Time) (*models.Book, bool, error) {
+	released := false
+
+	updatedBook, err := h.BookDAOInterface.UpdateAtomically(ctx, isbn, func(currentBook *models.Book) (*models.Book, error) {
+		if !isHoldExpired(currentBook, now) {
+			released = false
+			return currentBook, nil
+		}
+
+		releaseRequest := &models.Book{
+			ISBN: currentBook.ISBN,
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: currentBook.OnHoldCustomerID,
+			CheckedOutCustomerID: nil,
+			TimeCreated: nil,
+			TimeUpdated: nil,
+		}
+
+		released = true
+		return actionTable[*currentBook.State][*releaseRequest.State](currentBook, releaseRequest, h)
+	})
+
+	if h.StateTransitions != nil && released {
+		h.StateTransitions.RecordStateTransition("on-hold", "available", transitionOutcome(err))
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return updatedBook, released, nil
+}
+
+// ReleaseExpiredHolds releases every hold that expired before the current time, so that each book is placed on-hold for the next customer in its hold queue, or becomes available.
+// It returns the number of holds released. A book that could not be released is logged and left for the next call, while the others are still released
+func (h *BooksHandler) ReleaseExpiredHolds(ctx context.Context) (int, error) {
+	now := h.DateTimeInterface.GetCurrentTime()
+
+	// Each batch starts after the last book of the previous one, so that the books that could not be released are not queried again
+	releasedCount := 0
+	var lastKey *dao.BookKey
+	for {
+		queryContext, cancel := h.daoContext(ctx)
+		books, err := h.BookDAOInterface.Query(queryContext, dao.BookQuery{
+			State: utils.ToPtr("on-hold"),
+			HoldExpiryBefore: now,
+			SortBy: dao.SortByISBN,
+			After: lastKey,
+			Limit: holdSweepBatchSize,
+		})
+		cancel()
+
+		if err != nil {
+			return releasedCount, fmt.Errorf("error querying expired holds: %w", err)
+		}
+
+		for _, book := range books {
+			updateContext, cancel := h.daoContext(ctx)
+			updatedBook, released, err := h.releaseExpiredHold(updateContext, *book.ISBN, *now)
+			cancel()
+
+			if err != nil {
+				if ctx.Err() != nil {
+					return releasedCount, ctx.Err()
+				}
+
+				slog.ErrorContext(ctx, "failed to release expired hold", slog.String("isbn", *book.ISBN), slog.String("error", err.Error()))
+				continue
+			}
+
+			if released {
+				releasedCount++
+				slog.InfoContext(ctx, "expired hold released", slog.String("isbn", *book.ISBN), slog.String("state", *updatedBook.State))
+			}
+		}
+
+		if len(books) < holdSweepBatchSize {
+			return releasedCount, nil
+		}
+		lastKey = dao.KeyOf(books[len(books)-1], dao.SortByISBN)
+	}
+}
+
+// RunHoldSweeper calls ReleaseExpiredHolds every interval until ctx is done. Errors are logged, and the next sweep tries again
+func (h *BooksHandler) RunHoldSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := h.ReleaseExpiredHolds(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to release expired holds", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
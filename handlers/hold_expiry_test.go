@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example/library_project/dao"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"log"
+)
+
+// failingBookDAO fails UpdateAtomically for the ISBNs in failingISBNs, and passes every other call to the embedded BookDAO
+type failingBookDAO struct {
+	dao.BookDAO
+	failingISBNs map[string]bool
+}
+
+func (d *failingBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	if d.failingISBNs[isbn] {
+		return nil, errors.New("connection reset")
+	}
+
+	return d.BookDAO.UpdateAtomically(ctx, isbn, modify)
+}
+
+func TestBooksHandler_HoldExpiry(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	for _, isbn := range []string{"00001", "00002", "00003"} {
+		bookDAO.Create(context.Background(), &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(arbitraryTime),
+			TimeUpdated: nil,
+		})
+	}
+
+	// A book placed on-hold before holds expired has no hold expiry, and is never released
+	bookDAO.Create(context.Background(), &models.Book{
+		ISBN: utils.ToPtr("00004"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("05"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	})
+
+	// The tests move the clock forward by changing ArbitraryTime
+	timeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	recorder := &recordingStateTransitionRecorder{}
+	h := NewBooksHandler(bookDAO, timeProvider)
+	h.AuthenticationDisabled = true
+	h.StateTransitions = recorder
+
+	r := gin.Default()
+	r.PATCH("/books/:isbn", h.UpdateBook)
+	r.POST("/books/:isbn/holds", h.JoinHoldQueue)
+
+	request := func(method string, path string, body any) *httptest.ResponseRecorder {
+		var bodyBuffer bytes.Buffer
+		json.NewEncoder(&bodyBuffer).Encode(body)
+
+		req, err := http.NewRequest(method, path, &bodyBuffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	placeHold := func(isbn string, customerID string) *models.Book {
+		w := request("PATCH", "/books/"+isbn, &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr("on-hold"),
+			OnHoldCustomerID: utils.ToPtr(customerID),
+			CheckedOutCustomerID: nil,
+			TimeCreated: nil,
+			TimeUpdated: nil,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		book := new(models.Book)
+		if err := json.NewDecoder(w.Body).Decode(book); err != nil {
+			t.Fatal(err)
+		}
+		return book
+	}
+
+	readBook := func(isbn string) *models.Book {
+		book, err := bookDAO.Read(context.Background(), isbn)
+		assert.Nil(t, err)
+		return book
+	}
+
+	releaseExpiredHolds := func() int {
+		releasedCount, err := h.ReleaseExpiredHolds(context.Background())
+		assert.Nil(t, err)
+		return releasedCount
+	}
+
+	t.Log("Placing a hold sets its expiry one pickup window later")
+	heldBook := placeHold("00001", "01")
+	assert.Equal(t, arbitraryTime.Add(DefaultHoldPickupWindow), *heldBook.HoldExpiry)
+	placeHold("00002", "03")
+
+	w := request("POST", "/books/00001/holds", &models.Hold{CustomerID: utils.ToPtr("02")})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	timeProvider.ArbitraryTime = arbitraryTime.Add(24 * time.Hour)
+	placeHold("00003", "04")
+
+	t.Log("Placing the same hold again does not extend it")
+	assert.Equal(t, arbitraryTime.Add(DefaultHoldPickupWindow), *placeHold("00001", "01").HoldExpiry)
+
+	t.Log("The hold expiry cannot be modified by the client")
+	w = request("PATCH", "/books/00001", &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("01"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: nil,
+		TimeUpdated: nil,
+		HoldExpiry: utils.ToPtr(arbitraryTime.Add(30 * 24 * time.Hour)),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Only the transitions of the sweeper are checked from here on
+	recorder.transitions = nil
+
+	t.Log("Nothing is released before the holds expire, nor at the exact time they expire")
+	timeProvider.ArbitraryTime = arbitraryTime.Add(DefaultHoldPickupWindow)
+	assert.Equal(t, 0, releaseExpiredHolds())
+	assert.Empty(t, recorder.transitions)
+
+	t.Log("Expired holds are released, passing the book to the next customer in its hold queue")
+	releaseTime := arbitraryTime.Add(DefaultHoldPickupWindow + time.Minute)
+	timeProvider.ArbitraryTime = releaseTime
+	assert.Equal(t, 2, releaseExpiredHolds())
+
+	advancedBook := readBook("00001")
+	assert.Equal(t, "on-hold", *advancedBook.State)
+	assert.Equal(t, "02", *advancedBook.OnHoldCustomerID)
+	assert.Nil(t, advancedBook.HoldQueue)
+	assert.Equal(t, releaseTime, *advancedBook.TimeUpdated)
+	assert.Equal(t, releaseTime.Add(DefaultHoldPickupWindow), *advancedBook.HoldExpiry)
+
+	releasedBook := readBook("00002")
+	assert.Equal(t, "available", *releasedBook.State)
+	assert.Nil(t, releasedBook.OnHoldCustomerID)
+	assert.Nil(t, releasedBook.HoldExpiry)
+
+	assert.Equal(t, "on-hold", *readBook("00003").State)
+	assert.Equal(t, "on-hold", *readBook("00004").State)
+
+	assert.Equal(t, []stateTransition{
+		{from: "on-hold", to: "available", outcome: transitionSucceeded},
+		{from: "on-hold", to: "available", outcome: transitionSucceeded},
+	}, recorder.transitions)
+
+	t.Log("Releasing expired holds again changes nothing")
+	assert.Equal(t, 0, releaseExpiredHolds())
+	assert.Equal(t, int64(3), readBook("00002").Version)
+
+	t.Log("Checking out an on-hold book clears its hold expiry")
+	w = request("PATCH", "/books/00001", &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("02"),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, readBook("00001").HoldExpiry)
+
+	timeProvider.ArbitraryTime = arbitraryTime.Add(24 * time.Hour + DefaultHoldPickupWindow + time.Minute)
+	assert.Equal(t, 1, releaseExpiredHolds())
+	assert.Equal(t, "available", *readBook("00003").State)
+	assert.Equal(t, "checked-out", *readBook("00001").State)
+	assert.Equal(t, "on-hold", *readBook("00004").State)
+}
+
+func TestBooksHandler_ReleaseExpiredHolds(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	numberOfBooks := 2 * holdSweepBatchSize + 10
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	for i := 0; i < numberOfBooks; i++ {
+		bookDAO.Create(context.Background(), &models.Book{
+			ISBN: utils.ToPtr(fmt.Sprintf("%05d", i)),
+			State: utils.ToPtr("on-hold"),
+			OnHoldCustomerID: utils.ToPtr("01"),
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(arbitraryTime),
+			TimeUpdated: nil,
+			HoldExpiry: utils.ToPtr(arbitraryTime.Add(time.Hour)),
+		})
+	}
+
+	timeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime.Add(2 * time.Hour),
+	}
+
+	t.Log("A book that cannot be released does not stop the others from being released, across every batch")
+	failingDAO := &failingBookDAO{BookDAO: bookDAO, failingISBNs: map[string]bool{"00000": true, "00150": true}}
+	h := NewBooksHandler(failingDAO, timeProvider)
+	h.AuthenticationDisabled = true
+
+	releasedCount, err := h.ReleaseExpiredHolds(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, numberOfBooks - 2, releasedCount)
+
+	remainingBooks, err := bookDAO.Query(context.Background(), dao.BookQuery{State: utils.ToPtr("on-hold")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"00000", "00150"}, []string{*remainingBooks[0].ISBN, *remainingBooks[1].ISBN})
+
+	t.Log("The books left behind are released by the next sweep")
+	failingDAO.failingISBNs = nil
+	releasedCount, err = h.ReleaseExpiredHolds(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, releasedCount)
+
+	t.Log("The sweeper stops once its context is canceled")
+	ctx, cancel := context.WithCancel(context.Background())
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		h.RunHoldSweeper(ctx, time.Millisecond)
+	}()
+	cancel()
+
+	select {
+	case <-sweeperDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the sweeper did not stop")
+	}
+}
+
+func TestBooksHandler_RunHoldSweeper(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+	bookDAO.Create(context.Background(), &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("on-hold"),
+		OnHoldCustomerID: utils.ToPtr("01"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+		HoldExpiry: utils.ToPtr(arbitraryTime.Add(time.Hour)),
+	})
+
+	h := NewBooksHandler(bookDAO, &utils.TestingDateTimeProvider{ArbitraryTime: arbitraryTime.Add(2 * time.Hour)})
+
+	h.AuthenticationDisabled = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.RunHoldSweeper(ctx, 10 * time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		book, err := bookDAO.Read(context.Background(), "00001")
+		return err == nil && *book.State == "available"
+	}, 5 * time.Second, 10 * time.Millisecond)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// holdPosition returns the position of the customer in the hold queue of the book, starting from 1, or 0 if the customer is not in the queue
+func holdPosition(book *models.Book, customerID string) int {
+	for i, waitingCustomerID := range book.HoldQueue {
+		if waitingCustomerID == customerID {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// joinHoldQueue
+	// checked-out --> checked-out (with the customer added to the end of the hold queue)
+	// on-hold --> on-hold (with the customer added to the end of the hold queue)
+func joinHoldQueue(currentBook *models.Book, customerID string, h *BooksHandler) (*models.Book, error) {
+	if (*currentBook.State == "available") {
+		return nil, fmt.Errorf("Joining the hold queue failed as the book is available, and can be placed on-hold instead: %w", conflictErr)
+	}
+
+	if (currentBook.OnHoldCustomerID != nil && *currentBook.OnHoldCustomerID == customerID) || (currentBook.CheckedOutCustomerID != nil && *currentBook.CheckedOutCustomerID == customerID) {
+		return nil, fmt.Errorf("Joining the hold queue failed as the customer already has the book %s: %w", *currentBook.State, conflictErr)
+	}
+
+	if holdPosition(currentBook, customerID) != 0 {
+		return nil, fmt.Errorf("Joining the hold queue failed as the customer is already in it: %w", conflictErr)
+	}
+
+	currentBook.HoldQueue = append(currentBook.HoldQueue, customerID)
+	currentBook.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+
+	return currentBook, nil
+}
+
+// leaveHoldQueue
+	// checked-out --> checked-out (with the customer removed from the hold queue)
+	// on-hold --> on-hold (with the customer removed from the hold queue)
+func leaveHoldQueue(currentBook *models.Book, customerID string, h *BooksHandler) (*models.Book, error) {
+	position := holdPosition(currentBook, customerID)
+	if position == 0 {
+		return nil, fmt.Errorf("The customer is not in the hold queue of the book: %w", holdNotFoundErr)
+	}
+
+	currentBook.HoldQueue = append(currentBook.HoldQueue[:position-1], currentBook.HoldQueue[position:]...)
+	currentBook.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+
+	return currentBook, nil
+}
+
+// JoinHoldQueue allows a customer to wait for a book that another customer has on-hold or checked-out.
+// Once the book is returned or its hold released, it is placed on-hold for the customers of the queue in the order they joined
+func (h *BooksHandler) JoinHoldQueue(c *gin.Context) {
+	isbn := c.Param("isbn")
+
+	// Decode JSON to hold struct
+	incomingHold := new(models.Hold)
+	if err := h.decodeHold(c, incomingHold); err != nil {
+		respondWithDecodeError(c, err)
+		return
+	}
+
+	// If fields are not nil, ensure they are within range
+	if err := incomingHold.Validate(); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if incomingHold.CustomerID == nil {
+		respondWithError(c, http.StatusBadRequest, "Expected 'customerid' to be non-null.")
+		return
+	}
+
+	if incomingHold.Position != nil {
+		respondWithError(c, http.StatusBadRequest, "Client cannot provide the position in the hold queue.")
+		return
+	}
+
+	customerID := *incomingHold.CustomerID
+
+	// Patrons may only join the queue for themselves
+	if err := h.authorizeCustomer(c.Request.Context(), customerID); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	// The queue is modified inside UpdateAtomically, so customers joining at the same time are all added, each in a position of their own
+	updatedBook, err := h.BookDAOInterface.UpdateAtomically(ctx, isbn, func(currentBook *models.Book) (*models.Book, error) {
+		if err := h.checkIfMatch(ifMatch, currentBook); err != nil {
+			return nil, err
+		}
+
+		return joinHoldQueue(currentBook, customerID, h)
+	})
+
+	if err != nil {
+		respondWithUpdateError(c, ctx, err)
+		return
+	}
+
+	position := holdPosition(updatedBook, customerID)
+	slog.InfoContext(c.Request.Context(), "hold queue joined", slog.String("isbn", isbn), slog.Int("position", position))
+
+	c.IndentedJSON(http.StatusCreated, &models.Hold{
+		CustomerID: utils.ToPtr(customerID),
+		Position: utils.ToPtr(position),
+	})
+}
+
+// GetHold allows a customer to see their position in the hold queue of a book
+func (h *BooksHandler) GetHold(c *gin.Context) {
+	isbn := c.Param("isbn")
+	customerID := c.Param("customerid")
+
+	// Patrons may only see their own position, as the queue would otherwise reveal who else is waiting
+	if err := h.authorizeCustomer(c.Request.Context(), customerID); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	book, err := h.BookDAOInterface.Read(ctx, isbn)
+
+	if err != nil {
+		respondWithDAOError(c, ctx, err)
+		return
+	}
+
+	if book == nil {
+		respondWithError(c, http.StatusNotFound, "Book not found.")
+		return
+	}
+
+	position := holdPosition(book, customerID)
+	if position == 0 {
+		respondWithError(c, http.StatusNotFound, "The customer is not in the hold queue of the book.")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, &models.Hold{
+		CustomerID: utils.ToPtr(customerID),
+		Position: utils.ToPtr(position),
+	})
+}
+
+// LeaveHoldQueue allows a customer to stop waiting for a book. The customers behind them move up one position
+func (h *BooksHandler) LeaveHoldQueue(c *gin.Context) {
+	isbn := c.Param("isbn")
+	customerID := c.Param("customerid")
+
+	// Patrons may only leave the queue for themselves
+	if err := h.authorizeCustomer(c.Request.Context(), customerID); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	_, err := h.BookDAOInterface.UpdateAtomically(ctx, isbn, func(currentBook *models.Book) (*models.Book, error) {
+		if err := h.checkIfMatch(ifMatch, currentBook); err != nil {
+			return nil, err
+		}
+
+		return leaveHoldQueue(currentBook, customerID, h)
+	})
+
+	if err != nil {
+		respondWithUpdateError(c, ctx, err)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "hold queue left", slog.String("isbn", isbn))
+
+	c.Status(http.StatusNoContent)
+}
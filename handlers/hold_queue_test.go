@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/library_project/auth"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"log"
+)
+
+func TestBooksHandler_HoldQueue(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	for _, isbn := range []string{"00001", "00002"} {
+		bookDAO.Create(context.Background(), &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(arbitraryTime),
+			TimeUpdated: nil,
+		})
+	}
+
+	h := NewBooksHandler(bookDAO, &utils.TestingDateTimeProvider{ArbitraryTime: arbitraryTime})
+
+	h.AuthenticationDisabled = true
+
+	// Stands in for the authentication middleware, for the requests made as a patron
+	var principal *auth.Principal
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		if principal != nil {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		}
+		c.Next()
+	})
+	r.GET("/books/:isbn", h.GetIndividualBook)
+	r.PATCH("/books/:isbn", h.UpdateBook)
+	r.POST("/books/:isbn/renewals", h.RenewBook)
+	r.POST("/books/:isbn/holds", h.JoinHoldQueue)
+	r.GET("/books/:isbn/holds/:customerid", h.GetHold)
+	r.DELETE("/books/:isbn/holds/:customerid", h.LeaveHoldQueue)
+
+	request := func(method string, path string, body any) *httptest.ResponseRecorder {
+		var bodyBuffer bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&bodyBuffer).Encode(body)
+		}
+
+		req, err := http.NewRequest(method, path, &bodyBuffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	joinRequest := func(customerID string) *models.Hold {
+		return &models.Hold{
+			CustomerID: utils.ToPtr(customerID),
+			Position: nil,
+		}
+	}
+
+	decodeHold := func(w *httptest.ResponseRecorder) *models.Hold {
+		hold := new(models.Hold)
+		if err := json.NewDecoder(w.Body).Decode(hold); err != nil {
+			t.Fatal(err)
+		}
+		return hold
+	}
+
+	readBook := func(isbn string) *models.Book {
+		book, err := bookDAO.Read(context.Background(), isbn)
+		assert.Nil(t, err)
+		return book
+	}
+
+	t.Log("An available book cannot be waited for, as it can be placed on-hold instead")
+	w := request("POST", "/books/00001/holds", joinRequest("02"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = request("PATCH", "/books/00001", &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("01"),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Log("Customers join the queue of a checked-out book in order")
+	w = request("POST", "/books/00001/holds", joinRequest("02"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, &models.Hold{CustomerID: utils.ToPtr("02"), Position: utils.ToPtr(1)}, decodeHold(w))
+
+	w = request("POST", "/books/00001/holds", joinRequest("03"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, &models.Hold{CustomerID: utils.ToPtr("03"), Position: utils.ToPtr(2)}, decodeHold(w))
+
+	t.Log("The queue is not part of the book sent to the client")
+	w = request("GET", "/books/00001", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "\"03\"")
+
+	t.Log("Customers cannot join twice, nor wait for a book they already have")
+	w = request("POST", "/books/00001/holds", joinRequest("02"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request("POST", "/books/00001/holds", joinRequest("01"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	t.Log("Invalid requests to join the queue are rejected")
+	w = request("POST", "/books/00001/holds", &models.Hold{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = request("POST", "/books/00001/holds", joinRequest(""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = request("POST", "/books/00001/holds", &models.Hold{CustomerID: utils.ToPtr("04"), Position: utils.ToPtr(1)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = request("POST", "/books/00009/holds", joinRequest("04"))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	t.Log("A loan cannot be renewed while customers are waiting for the book")
+	w = request("POST", "/books/00001/renewals", &models.Book{CheckedOutCustomerID: utils.ToPtr("01")})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	t.Log("Patrons may only join, view or leave the queue for themselves")
+	principal = &auth.Principal{Subject: "patron-03", Method: auth.MethodJWT, Role: auth.RolePatron, CustomerID: "03"}
+	w = request("POST", "/books/00001/holds", joinRequest("04"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("GET", "/books/00001/holds/02", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("DELETE", "/books/00001/holds/02", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("GET", "/books/00001/holds/03", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &models.Hold{CustomerID: utils.ToPtr("03"), Position: utils.ToPtr(2)}, decodeHold(w))
+	principal = nil
+
+	t.Log("Leaving the queue moves the customers behind up one position")
+	w = request("DELETE", "/books/00001/holds/02", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = request("DELETE", "/books/00001/holds/02", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = request("GET", "/books/00001/holds/02", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = request("GET", "/books/00001/holds/03", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, utils.ToPtr(1), decodeHold(w).Position)
+
+	w = request("POST", "/books/00001/holds", joinRequest("04"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, utils.ToPtr(2), decodeHold(w).Position)
+
+	t.Log("Returning the book places it on-hold for the first customer in the queue")
+	w = request("PATCH", "/books/00001", &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("01"),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	returnedBook := readBook("00001")
+	assert.Equal(t, "on-hold", *returnedBook.State)
+	assert.Equal(t, "03", *returnedBook.OnHoldCustomerID)
+	assert.Nil(t, returnedBook.CheckedOutCustomerID)
+	assert.Nil(t, returnedBook.TimeDue)
+	assert.Equal(t, []string{"04"}, returnedBook.HoldQueue)
+
+	t.Log("Customers can also join the queue of an on-hold book")
+	w = request("POST", "/books/00001/holds", joinRequest("05"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, utils.ToPtr(2), decodeHold(w).Position)
+
+	t.Log("Releasing the hold places the book on-hold for the next customer in the queue")
+	w = request("PATCH", "/books/00001", &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: utils.ToPtr("03"),
+		CheckedOutCustomerID: nil,
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	releasedBook := readBook("00001")
+	assert.Equal(t, "on-hold", *releasedBook.State)
+	assert.Equal(t, "04", *releasedBook.OnHoldCustomerID)
+	assert.Equal(t, []string{"05"}, releasedBook.HoldQueue)
+
+	t.Log("Only the customer the book is on-hold for can check it out")
+	checkoutRequest := func(customerID string) *models.Book {
+		return &models.Book{
+			ISBN: utils.ToPtr("00001"),
+			State: utils.ToPtr("checked-out"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: utils.ToPtr(customerID),
+			TimeCreated: nil,
+			TimeUpdated: nil,
+		}
+	}
+	w = request("PATCH", "/books/00001", checkoutRequest("05"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request("PATCH", "/books/00001", checkoutRequest("04"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Log("Once the queue is empty, returning the book makes it available")
+	w = request("DELETE", "/books/00001/holds/05", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = request("PATCH", "/books/00001", &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("04"),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	availableBook := readBook("00001")
+	assert.Equal(t, "available", *availableBook.State)
+	assert.Nil(t, availableBook.OnHoldCustomerID)
+	assert.Nil(t, availableBook.HoldQueue)
+
+	t.Log("The queues of other books are untouched")
+	assert.Nil(t, readBook("00002").HoldQueue)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isOverdue reports whether the book is checked-out and was due back before now
+func isOverdue(book *models.Book, now time.Time) bool {
+	return book.State != nil && *book.State == "checked-out" && book.TimeDue != nil && book.TimeDue.Before(now)
+}
+
+// setOverdue computes the Overdue flag of the books at the current time. It is called on every book sent to the client, since the flag is never stored
+func (h *BooksHandler) setOverdue(books ...*models.Book) {
+	now := *h.DateTimeInterface.GetCurrentTime()
+	for _, book := range books {
+		book.Overdue = isOverdue(book, now)
+	}
+}
+
+// GetOverdueBooks allows the client to get the books that are checked-out past their due date, the longest overdue first.
+// The books can be filtered with the customer query parameter, and are paginated like the books of GetAllBooks
+func (h *BooksHandler) GetOverdueBooks(c *gin.Context) {
+	query := dao.BookQuery{
+		State: utils.ToPtr("checked-out"),
+		DueBefore: h.DateTimeInterface.GetCurrentTime(),
+		SortBy: dao.SortByTimeDue,
+	}
+
+	var err error
+	if query.CustomerID, err = parseCustomerParameter(c); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := parsePage(c, &query); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondWithBooks(c, query)
+}
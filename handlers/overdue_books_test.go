@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"log"
+)
+
+func TestBooksHandler_Overdue(t *testing.T) {
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	loanPeriod := 7 * 24 * time.Hour
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	for _, isbn := range []string{"00001", "00002", "00003"} {
+		bookDAO.Create(context.Background(), &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(arbitraryTime),
+			TimeUpdated: nil,
+		})
+	}
+
+	// The tests move the clock forward by changing ArbitraryTime
+	timeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTime,
+	}
+
+	h := NewBooksHandler(bookDAO, timeProvider)
+
+	h.AuthenticationDisabled = true
+	h.LoanPeriod = loanPeriod
+
+	r := gin.Default()
+	r.GET("/books/overdue", h.GetOverdueBooks)
+	r.GET("/books/:isbn", h.GetIndividualBook)
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	request := func(method string, path string, body *models.Book, header map[string]string) *httptest.ResponseRecorder {
+		var bodyBuffer bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&bodyBuffer).Encode(body)
+		}
+
+		req, err := http.NewRequest(method, path, &bodyBuffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	decodeBook := func(w *httptest.ResponseRecorder) *models.Book {
+		book := new(models.Book)
+		if err := json.NewDecoder(w.Body).Decode(book); err != nil {
+			t.Fatal(err)
+		}
+		return book
+	}
+
+	getOverdueISBNs := func(query string) []string {
+		w := request("GET", "/books/overdue"+query, nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Only the paginated requests receive their books wrapped in a page
+		books := make([]*models.Book, 0)
+		if strings.Contains(query, "limit=") {
+			page := new(models.BookPage)
+			if err := json.NewDecoder(w.Body).Decode(page); err != nil {
+				t.Fatal(err)
+			}
+			books = page.Books
+		} else if err := json.NewDecoder(w.Body).Decode(&books); err != nil {
+			t.Fatal(err)
+		}
+
+		isbns := make([]string, 0, len(books))
+		for _, book := range books {
+			assert.True(t, book.Overdue)
+			isbns = append(isbns, *book.ISBN)
+		}
+		return isbns
+	}
+
+	checkoutRequest := func(isbn string, customerID string) *models.Book {
+		return &models.Book{
+			ISBN: utils.ToPtr(isbn),
+			State: utils.ToPtr("checked-out"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: utils.ToPtr(customerID),
+			TimeCreated: nil,
+			TimeUpdated: nil,
+		}
+	}
+
+	t.Log("Checkout sets the due date one loan period later")
+	w := request("PATCH", "/books/00001", checkoutRequest("00001", "01"), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	checkedOutBook := decodeBook(w)
+	assert.Equal(t, arbitraryTime.Add(loanPeriod), *checkedOutBook.TimeDue)
+	assert.False(t, checkedOutBook.Overdue)
+
+	timeProvider.ArbitraryTime = arbitraryTime.Add(24 * time.Hour)
+	w = request("PATCH", "/books/00002", checkoutRequest("00002", "02"), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, arbitraryTime.Add(24 * time.Hour + loanPeriod), *decodeBook(w).TimeDue)
+
+	t.Log("Checking out a book again does not move its due date")
+	w = request("PATCH", "/books/00001", checkoutRequest("00001", "01"), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, arbitraryTime.Add(loanPeriod), *decodeBook(w).TimeDue)
+
+	t.Log("The due date cannot be modified by the client")
+	modifiedDueDate := checkoutRequest("00001", "01")
+	modifiedDueDate.TimeDue = utils.ToPtr(arbitraryTime.Add(30 * 24 * time.Hour))
+	w = request("PATCH", "/books/00001", modifiedDueDate, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	t.Log("Nothing is overdue before the due date")
+	w = request("GET", "/books/00001", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, decodeBook(w).Overdue)
+	assert.Equal(t, "\"2\"", w.Header().Get("ETag"))
+	assert.Equal(t, []string{}, getOverdueISBNs(""))
+
+	t.Log("A book is not overdue at the exact time it is due")
+	timeProvider.ArbitraryTime = arbitraryTime.Add(loanPeriod)
+	assert.Equal(t, []string{}, getOverdueISBNs(""))
+
+	t.Log("A book is overdue once its due date has passed, which changes its ETag")
+	timeProvider.ArbitraryTime = arbitraryTime.Add(loanPeriod + time.Hour)
+	w = request("GET", "/books/00001", nil, map[string]string{"If-None-Match": "\"2\""})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, decodeBook(w).Overdue)
+	assert.Equal(t, "\"2-overdue\"", w.Header().Get("ETag"))
+	assert.Equal(t, []string{"00001"}, getOverdueISBNs(""))
+
+	t.Log("The overdue report lists the longest overdue books first, and can be filtered by customer")
+	timeProvider.ArbitraryTime = arbitraryTime.Add(loanPeriod + 48 * time.Hour)
+	assert.Equal(t, []string{"00001", "00002"}, getOverdueISBNs(""))
+	assert.Equal(t, []string{"00002"}, getOverdueISBNs("?customer=02"))
+	assert.Equal(t, []string{"00001"}, getOverdueISBNs("?limit=1"))
+
+	w = request("GET", "/books/overdue?limit=0", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	t.Log("An update conditional on the ETag from before the book was overdue fails")
+	returnRequest := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("01"),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	}
+	w = request("PATCH", "/books/00001", returnRequest, map[string]string{"If-Match": "\"2\""})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	t.Log("Returning the book clears its due date")
+	w = request("PATCH", "/books/00001", returnRequest, map[string]string{"If-Match": "\"2-overdue\""})
+	assert.Equal(t, http.StatusOK, w.Code)
+	returnedBook := decodeBook(w)
+	assert.Nil(t, returnedBook.TimeDue)
+	assert.False(t, returnedBook.Overdue)
+	assert.Equal(t, []string{"00002"}, getOverdueISBNs(""))
+
+	storedBook, err := bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Nil(t, storedBook.TimeDue)
+	assert.Equal(t, int64(3), storedBook.Version)
+}
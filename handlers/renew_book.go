@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hasWaitingCustomers reports whether another customer is waiting for the book, in which case its loan cannot be renewed
+func hasWaitingCustomers(book *models.Book) bool {
+	return book.OnHoldCustomerID != nil || len(book.HoldQueue) > 0
+}
+
+// renew
+	// checked-out --> checked-out, with the due date extended by one loan period
+func renew(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
+	if err := validateIDsForCheckedOut(incomingBook, currentBook); err != nil {
+		return nil, err
+	}
+
+	if (*currentBook.State != "checked-out") {
+		return nil, fmt.Errorf("Renewal failed as the book is not checked-out: %w", conflictErr)
+	}
+
+	if (*currentBook.CheckedOutCustomerID != *incomingBook.CheckedOutCustomerID) {
+		return nil, fmt.Errorf("Renewal failed as it is another customer who has the book checked-out: %w", conflictErr)
+	}
+
+	currentTime := h.DateTimeInterface.GetCurrentTime()
+	if isOverdue(currentBook, *currentTime) {
+		return nil, fmt.Errorf("Renewal failed as the book is overdue and must be returned: %w", conflictErr)
+	}
+
+	// Books checked out before renewals were counted have no renewal count, and are treated as never renewed
+	renewalCount := 0
+	if currentBook.RenewalCount != nil {
+		renewalCount = *currentBook.RenewalCount
+	}
+
+	if renewalCount >= h.MaxRenewals {
+		return nil, fmt.Errorf("Renewal failed as the loan has already been renewed %d times, which is the maximum: %w", renewalCount, conflictErr)
+	}
+
+	if hasWaitingCustomers(currentBook) {
+		return nil, fmt.Errorf("Renewal failed as another customer is waiting for the book: %w", conflictErr)
+	}
+
+	// The loan period is added to the current due date rather than to the current time, so renewing early does not cut the loan short
+	if currentBook.TimeDue == nil {
+		currentBook.TimeDue = h.dueTime(currentTime)
+	} else {
+		currentBook.TimeDue = h.dueTime(currentBook.TimeDue)
+	}
+	currentBook.RenewalCount = utils.ToPtr(renewalCount + 1)
+	currentBook.TimeUpdated = currentTime
+
+	return currentBook, nil
+}
+
+// RenewBook allows the customer who has a book checked-out to extend its due date by one loan period, at most MaxRenewals times per loan.
+// Like a request to return the book, the body names the customer in checkedoutcustomerid
+func (h *BooksHandler) RenewBook(c *gin.Context) {
+	isbn := c.Param("isbn")
+
+	// Decode JSON to book struct
+	incomingBook := new(models.Book)
+	if err := h.decodeBook(c, incomingBook); err != nil {
+		respondWithDecodeError(c, err)
+		return
+	}
+
+	// If fields are not nil, ensure they are within range
+	if err := incomingBook.Validate(); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Patrons may only renew their own loans
+	if err := h.authorizeCustomers(c.Request.Context(), incomingBook); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
+
+	// As in UpdateBook, the renewal is checked against the latest stored book, so two renewals racing each other cannot exceed the limit
+	renewedBook, err := h.BookDAOInterface.UpdateAtomically(ctx, isbn, func(currentBook *models.Book) (*models.Book, error) {
+		if err := h.checkIfMatch(ifMatch, currentBook); err != nil {
+			return nil, err
+		}
+
+		return renew(currentBook, incomingBook, h)
+	})
+
+	if err != nil {
+		respondWithUpdateError(c, ctx, err)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "loan renewed", slog.String("isbn", isbn), slog.Int("renewal_count", *renewedBook.RenewalCount), slog.Time("time_due", *renewedBook.TimeDue))
+
+	h.setOverdue(renewedBook)
+	c.Header("ETag", bookETag(renewedBook))
+	c.IndentedJSON(http.StatusOK, renewedBook)
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/library_project/auth"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"fmt"
+	"log"
+)
+
+func TestBooksHandler_RenewBook(t *testing.T) {
+	arbitraryTimeCreated := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	arbitraryTimeUpdated := time.Date(2023, 2, 2, 1, 30, 0, 0, time.UTC)
+	arbitraryTimeDue := arbitraryTimeUpdated.Add(DefaultLoanPeriod)
+	arbitraryCurrentTime := time.Date(2023, 2, 10, 1, 30, 0, 0, time.UTC)
+
+	checkedOutBook := func(customerID string, renewalCount *int) *models.Book {
+		return &models.Book{
+			ISBN: utils.ToPtr("00001"),
+			State: utils.ToPtr("checked-out"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: utils.ToPtr(customerID),
+			TimeCreated: utils.ToPtr(arbitraryTimeCreated),
+			TimeUpdated: utils.ToPtr(arbitraryTimeUpdated),
+			TimeDue: utils.ToPtr(arbitraryTimeDue),
+			RenewalCount: renewalCount,
+		}
+	}
+
+	renewalRequest := func(customerID string) *models.Book {
+		return &models.Book{
+			ISBN: nil,
+			State: nil,
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: utils.ToPtr(customerID),
+			TimeCreated: nil,
+			TimeUpdated: nil,
+		}
+	}
+
+	patron := &auth.Principal{Subject: "patron-01", Method: auth.MethodJWT, Role: auth.RolePatron, CustomerID: "01"}
+
+	tests := []struct {
+		description string
+		existingBook *models.Book
+		incomingBook *models.Book
+		principal *auth.Principal
+		ifMatch string
+		currentTime time.Time
+		expectedStatusCode int
+		expectedBook *models.Book
+	}{
+		{
+			description: "First renewal extends the due date by one loan period",
+			existingBook: checkedOutBook("01", utils.ToPtr(0)),
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 200,
+			expectedBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("checked-out"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: utils.ToPtr("01"),
+				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
+				TimeUpdated: utils.ToPtr(arbitraryCurrentTime),
+				TimeDue: utils.ToPtr(arbitraryTimeDue.Add(DefaultLoanPeriod)),
+				RenewalCount: utils.ToPtr(1),
+			},
+		},
+		{
+			description: "Book checked out before renewals were counted can be renewed",
+			existingBook: checkedOutBook("01", nil),
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 200,
+			expectedBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("checked-out"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: utils.ToPtr("01"),
+				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
+				TimeUpdated: utils.ToPtr(arbitraryCurrentTime),
+				TimeDue: utils.ToPtr(arbitraryTimeDue.Add(DefaultLoanPeriod)),
+				RenewalCount: utils.ToPtr(1),
+			},
+		},
+		{
+			description: "Patron renews their own loan, conditional on the current ETag",
+			existingBook: checkedOutBook("01", utils.ToPtr(1)),
+			incomingBook: renewalRequest("01"),
+			principal: patron,
+			ifMatch: "\"1\"",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 200,
+			expectedBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("checked-out"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: utils.ToPtr("01"),
+				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
+				TimeUpdated: utils.ToPtr(arbitraryCurrentTime),
+				TimeDue: utils.ToPtr(arbitraryTimeDue.Add(DefaultLoanPeriod)),
+				RenewalCount: utils.ToPtr(2),
+			},
+		},
+		{
+			description: "Loan already renewed the maximum number of times",
+			existingBook: checkedOutBook("01", utils.ToPtr(DefaultMaxRenewals)),
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 409,
+			expectedBook: nil,
+		},
+		{
+			description: "Book checked out by another customer",
+			existingBook: checkedOutBook("02", utils.ToPtr(0)),
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 409,
+			expectedBook: nil,
+		},
+		{
+			description: "Book is not checked-out",
+			existingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("available"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: nil,
+				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
+				TimeUpdated: nil,
+			},
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 409,
+			expectedBook: nil,
+		},
+		{
+			description: "Overdue book must be returned rather than renewed",
+			existingBook: checkedOutBook("01", utils.ToPtr(0)),
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryTimeDue.Add(time.Hour),
+			expectedStatusCode: 409,
+			expectedBook: nil,
+		},
+		{
+			description: "Book does not exist",
+			existingBook: nil,
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 404,
+			expectedBook: nil,
+		},
+		{
+			description: "Customer is not provided",
+			existingBook: checkedOutBook("01", utils.ToPtr(0)),
+			incomingBook: &models.Book{},
+			principal: nil,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 400,
+			expectedBook: nil,
+		},
+		{
+			description: "Patron renews the loan of another customer",
+			existingBook: checkedOutBook("02", utils.ToPtr(0)),
+			incomingBook: renewalRequest("02"),
+			principal: patron,
+			ifMatch: "",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 403,
+			expectedBook: nil,
+		},
+		{
+			description: "Renewal conditional on a stale ETag",
+			existingBook: checkedOutBook("01", utils.ToPtr(0)),
+			incomingBook: renewalRequest("01"),
+			principal: nil,
+			ifMatch: "\"7\"",
+			currentTime: arbitraryCurrentTime,
+			expectedStatusCode: 412,
+			expectedBook: nil,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		daoFactory := inmemorydao.NewInMemoryDAOFactory()
+		if err := daoFactory.Open(); err != nil {
+			log.Fatal("failed to open database connection: ", err)
+		}
+
+		if currentTestCase.existingBook != nil {
+			if err := daoFactory.BookDAO().Create(context.Background(), currentTestCase.existingBook); err != nil {
+				log.Fatal("failed to add book to DAO: ", err)
+			}
+		}
+
+		fixedTimeProvider := &utils.TestingDateTimeProvider{
+			ArbitraryTime: currentTestCase.currentTime,
+		}
+		h := NewBooksHandler(daoFactory.BookDAO(), fixedTimeProvider)
+		h.AuthenticationDisabled = true
+
+		// Stands in for the authentication middleware
+		principal := currentTestCase.principal
+		r := gin.Default()
+		r.Use(func(c *gin.Context) {
+			if principal != nil {
+				c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+			}
+			c.Next()
+		})
+		r.POST("/books/:isbn/renewals", h.RenewBook)
+
+		var body bytes.Buffer
+		if err := json.NewEncoder(&body).Encode(currentTestCase.incomingBook); err != nil {
+			log.Fatal("failed to encode book: ", err)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/books/00001/renewals", &body)
+		if currentTestCase.ifMatch != "" {
+			req.Header.Set("If-Match", currentTestCase.ifMatch)
+		}
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		if currentTestCase.expectedBook != nil {
+			renewedBook := new(models.Book)
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), renewedBook))
+			assert.Equal(t, currentTestCase.expectedBook, renewedBook)
+			assert.Equal(t, "\"2\"", w.Header().Get("ETag"))
+		} else {
+			var errorResponse models.ErrorResponse
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &errorResponse))
+			assert.NotNil(t, errorResponse.Message)
+		}
+
+		// A rejected renewal must leave the stored book untouched
+		if currentTestCase.existingBook != nil && currentTestCase.expectedBook == nil {
+			storedBook, err := daoFactory.BookDAO().Read(context.Background(), "00001")
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), storedBook.Version)
+		}
+
+		daoFactory.Close()
+	}
+}
+
+func TestBooksHandler_UpdateBook_RenewalCountCannotBeModified(t *testing.T) {
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	daoFactory.BookDAO().Create(context.Background(), &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("01"),
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+		TimeDue: utils.ToPtr(arbitraryTime.Add(DefaultLoanPeriod)),
+		RenewalCount: utils.ToPtr(0),
+	})
+
+	h := NewBooksHandler(daoFactory.BookDAO(), &utils.TestingDateTimeProvider{ArbitraryTime: arbitraryTime})
+
+	h.AuthenticationDisabled = true
+	r := gin.Default()
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	bookJSON, _ := json.Marshal(models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("01"),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+		RenewalCount: utils.ToPtr(-5),
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/books/00001", bytes.NewBuffer(bookJSON))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	storedBook, err := daoFactory.BookDAO().Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, *storedBook.RenewalCount)
+}
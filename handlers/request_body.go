@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"example/library_project/models"
+
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// decodeBook decodes the JSON body of the request into book. At most MaxBodyBytes of the body are read, so that a client cannot make the handler buffer an unbounded body
+func (h *BooksHandler) decodeBook(c *gin.Context, book *models.Book) error {
+	return h.decodeBody(c, book)
+}
+
+// decodeHold decodes the JSON body of the request into hold, with the same limit as decodeBook
+func (h *BooksHandler) decodeHold(c *gin.Context, hold *models.Hold) error {
+	return h.decodeBody(c, hold)
+}
+
+// decodeFine decodes the JSON body of the request into fine, with the same limit as decodeBook
+func (h *BooksHandler) decodeFine(c *gin.Context, fine *models.Fine) error {
+	return h.decodeBody(c, fine)
+}
+
+// decodeBody decodes the JSON body of the request into v, reading at most MaxBodyBytes of it
+func (h *BooksHandler) decodeBody(c *gin.Context, v any) error {
+	if h.MaxBodyBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxBodyBytes)
+	}
+
+	dec := json.NewDecoder(c.Request.Body)
+	return dec.Decode(v)
+}
+
+// respondWithDecodeError responds with 413 when the body was too large, and with 400 when it was not valid JSON
+func respondWithDecodeError(c *gin.Context, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		respondWithError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes.", maxBytesError.Limit))
+		return
+	}
+
+	respondWithError(c, http.StatusBadRequest, err.Error())
+}
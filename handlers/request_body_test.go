@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"fmt"
+	"log"
+)
+
+func TestBooksHandler_MaxBodyBytes(t *testing.T) {
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	existingBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)),
+		TimeUpdated: nil,
+	}
+	if err := daoFactory.BookDAO().Create(context.Background(), existingBook); err != nil {
+		log.Fatal("failed to add book to DAO: ", err)
+	}
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC),
+	}
+
+	h := NewBooksHandler(daoFactory.BookDAO(), fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+	h.MaxBodyBytes = 128
+
+	r := gin.Default()
+	r.POST("/books", h.CreateBook)
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	// Valid JSON, padded with whitespace beyond the limit
+	padding := strings.Repeat(" ", 256)
+
+	tests := []struct{
+		description string
+		method string
+		path string
+		body string
+		expectedStatusCode int
+	}{
+		{
+			description: "Book created with a small body",
+			method: "POST",
+			path: "/books",
+			body: `{"isbn": "00002", "state": "available"}`,
+			expectedStatusCode: 201,
+		},
+		{
+			description: "Book not created with a body over the limit",
+			method: "POST",
+			path: "/books",
+			body: `{"isbn": "00003", ` + padding + `"state": "available"}`,
+			expectedStatusCode: 413,
+		},
+		{
+			description: "Book updated with a small body",
+			method: "PATCH",
+			path: "/books/00001",
+			body: `{"isbn": "00001", "state": "on-hold", "onholdcustomerid": "01"}`,
+			expectedStatusCode: 200,
+		},
+		{
+			description: "Book not updated with a body over the limit",
+			method: "PATCH",
+			path: "/books/00001",
+			body: `{"isbn": "00001", ` + padding + `"state": "available", "onholdcustomerid": "01"}`,
+			expectedStatusCode: 413,
+		},
+		{
+			description: "Invalid JSON is still a bad request",
+			method: "POST",
+			path: "/books",
+			body: `{"isbn": `,
+			expectedStatusCode: 400,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(currentTestCase.method, currentTestCase.path, strings.NewReader(currentTestCase.body))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+	}
+
+	// The book is still on hold, since the request over the limit was rejected
+	book, err := daoFactory.BookDAO().Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "on-hold", *book.State)
+}
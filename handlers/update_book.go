@@ -1,19 +1,44 @@
 package handlers
 
 import (
+	"example/library_project/dao"
 	"example/library_project/models"
 	"example/library_project/utils"
 
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 var invalidRequestErr = errors.New("invalid request")
 var conflictErr = errors.New("conflict")
+var holdNotFoundErr = errors.New("hold not found")
+
+// Outcomes reported to the StateTransitionRecorder
+const (
+	transitionSucceeded = "success"
+	transitionConflict = "conflict"
+	transitionInvalid = "invalid"
+	transitionFailed = "error"
+)
+
+// transitionOutcome classifies the error returned by UpdateAtomically once the action table was reached
+func transitionOutcome(err error) string {
+	if err == nil {
+		return transitionSucceeded
+	} else if errors.Is(err, conflictErr) || errors.Is(err, dao.ErrConcurrentUpdate) {
+		return transitionConflict
+	} else if errors.Is(err, invalidRequestErr) {
+		return transitionInvalid
+	}
+
+	return transitionFailed
+}
 
 // validateLogicForUpdateBook validates requests for the logic unique to updating an existing book
 func validateLogicForUpdateBook(incomingBook *models.Book, currentBook *models.Book) (error) {	
@@ -62,9 +87,42 @@ func validateLogicForUpdateBook(incomingBook *models.Book, currentBook *models.B
 		
 	}
 
+	// Validate Time Due, which is set by checkout and cannot be changed by the client either
+	if incomingBook.TimeDue != nil {
+		if currentBook.TimeDue == nil || !incomingBook.TimeDue.Equal(*currentBook.TimeDue) {
+			return fmt.Errorf("'timedue' cannot be modified: %w", invalidRequestErr)
+		}
+	}
+
+	// Validate Hold Expiry, which is set when the book is placed on-hold and cannot be changed by the client either
+	if incomingBook.HoldExpiry != nil {
+		if currentBook.HoldExpiry == nil || !incomingBook.HoldExpiry.Equal(*currentBook.HoldExpiry) {
+			return fmt.Errorf("'holdexpiry' cannot be modified: %w", invalidRequestErr)
+		}
+	}
+
+	// Validate Renewal Count, which is only changed by renewing the loan
+	if incomingBook.RenewalCount != nil {
+		if currentBook.RenewalCount == nil || *incomingBook.RenewalCount != *currentBook.RenewalCount {
+			return fmt.Errorf("'renewalcount' cannot be modified: %w", invalidRequestErr)
+		}
+	}
+
 	return nil
 }
 
+// dueTime returns the time a book checked out at checkoutTime is due back
+func (h *BooksHandler) dueTime(checkoutTime *time.Time) *time.Time {
+	timeDue := checkoutTime.Add(h.LoanPeriod)
+	return &timeDue
+}
+
+// holdExpiry returns the time the hold on a book placed on-hold at holdTime expires
+func (h *BooksHandler) holdExpiry(holdTime *time.Time) *time.Time {
+	holdExpiry := holdTime.Add(h.HoldPickupWindow)
+	return &holdExpiry
+}
+
 // validateIDsForCheckedOut ensures the OnHoldCustomerID and CheckedOutCustomerID fields are correctly populated for the checkout and returnBook helper functions
 func validateIDsForCheckedOut(incomingBook *models.Book, currentBook *models.Book) (error) {
 	if (incomingBook.CheckedOutCustomerID == nil) {
@@ -99,7 +157,7 @@ func validateIDsForOnHold(incomingBook *models.Book, currentBook *models.Book) (
 	// available --> checked-out
 	// on-hold --> checked-out
 	// checked-out --> checked-out
-func checkout(currentBook *models.Book, incomingBook *models.Book, provider utils.DateTimeProvider) (*models.Book, error) {
+func checkout(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
 	if err := validateIDsForCheckedOut(incomingBook, currentBook); err != nil {
 		return nil, err
 	}
@@ -107,20 +165,25 @@ func checkout(currentBook *models.Book, incomingBook *models.Book, provider util
 	if (*currentBook.State == "available") {
 		*currentBook.State = "checked-out"
 		currentBook.CheckedOutCustomerID = incomingBook.CheckedOutCustomerID
-		currentBook.TimeUpdated = provider.GetCurrentTime()
+		currentBook.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+		currentBook.TimeDue = h.dueTime(currentBook.TimeUpdated)
+		currentBook.RenewalCount = utils.ToPtr(0)
 	} else if (*currentBook.State == "on-hold") {
 		if (*currentBook.OnHoldCustomerID == *incomingBook.CheckedOutCustomerID) { // ensure the customer who currently has it on-hold is the same one trying to check it out
 			*currentBook.State = "checked-out"
 			currentBook.OnHoldCustomerID = nil
+			currentBook.HoldExpiry = nil
 			currentBook.CheckedOutCustomerID = incomingBook.CheckedOutCustomerID
-			currentBook.TimeUpdated = provider.GetCurrentTime()
+			currentBook.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+			currentBook.TimeDue = h.dueTime(currentBook.TimeUpdated)
+			currentBook.RenewalCount = utils.ToPtr(0)
 		} else {
 			return nil, fmt.Errorf("Checkout failed as another customer has the book on-hold: %w", conflictErr)
 			// return nil, errors.New("Cannot complete checkout. Someone else has the book on-hold.")
 		}
 	} else if (*currentBook.State == "checked-out") {
 		if (*currentBook.CheckedOutCustomerID == *incomingBook.CheckedOutCustomerID) { // ensure the customer who currently has it checked out is the same one trying to check it out redundantly
-			// pass
+			// pass, as extending the loan is done by RenewBook
 		} else {
 			return nil, fmt.Errorf("Checkout failed as another customer has the book checked-out: %w", conflictErr)
 			// return nil, errors.New("Cannot complete checkout. Someone else has the book checked-out.")
@@ -134,7 +197,7 @@ func checkout(currentBook *models.Book, incomingBook *models.Book, provider util
 
 // conflict
 	// checked-out --> on-hold
-func conflict(currentBook *models.Book, incomingBook *models.Book, provider utils.DateTimeProvider) (*models.Book, error) {
+func conflict(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
 	return nil, fmt.Errorf("Invalid state transition requested: %w", conflictErr)
 	// return nil, errors.New("Invalid state transition requested.")
 }
@@ -142,7 +205,7 @@ func conflict(currentBook *models.Book, incomingBook *models.Book, provider util
 // placeHold
 	// available --> on-hold
 	// on-hold --> on-hold
-func placeHold(currentBook *models.Book, incomingBook *models.Book, provider utils.DateTimeProvider) (*models.Book, error) {
+func placeHold(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
 	if err := validateIDsForOnHold(incomingBook, currentBook); err != nil {
 		return nil, err
 	}
@@ -150,7 +213,8 @@ func placeHold(currentBook *models.Book, incomingBook *models.Book, provider uti
 	if (*currentBook.State == "available") {
 		*currentBook.State = "on-hold"
 		currentBook.OnHoldCustomerID = incomingBook.OnHoldCustomerID
-		currentBook.TimeUpdated = provider.GetCurrentTime()
+		currentBook.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+		currentBook.HoldExpiry = h.holdExpiry(currentBook.TimeUpdated)
 	} else if (*currentBook.State == "on-hold") {
 		if (*currentBook.OnHoldCustomerID == *incomingBook.OnHoldCustomerID) { // ensure the customer who currently has it on-hold is the same one trying to check it out
 			// pass
@@ -165,9 +229,23 @@ func placeHold(currentBook *models.Book, incomingBook *models.Book, provider uti
 	return currentBook, nil
 }
 
+// advanceHoldQueue places the book on-hold for the first customer in its hold queue, if anyone is waiting for it.
+// The customer gets a full pickup window from the time the book was last updated
+func advanceHoldQueue(book *models.Book, h *BooksHandler) {
+	if len(book.HoldQueue) == 0 {
+		return
+	}
+
+	*book.State = "on-hold"
+	book.OnHoldCustomerID = utils.ToPtr(book.HoldQueue[0])
+	book.HoldQueue = book.HoldQueue[1:]
+	book.HoldExpiry = h.holdExpiry(book.TimeUpdated)
+}
+
 // releaseHold
 	// on-hold --> available
-func releaseHold(currentBook *models.Book, incomingBook *models.Book, provider utils.DateTimeProvider) (*models.Book, error) {
+	// on-hold --> on-hold (for the first customer in the hold queue)
+func releaseHold(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
 	if err := validateIDsForOnHold(incomingBook, currentBook); err != nil {
 		return nil, err
 	}
@@ -176,7 +254,9 @@ func releaseHold(currentBook *models.Book, incomingBook *models.Book, provider u
 		if (*currentBook.OnHoldCustomerID == *incomingBook.OnHoldCustomerID) {
 			*currentBook.State = "available"
 			currentBook.OnHoldCustomerID = nil
-			currentBook.TimeUpdated = provider.GetCurrentTime()
+			currentBook.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+			currentBook.HoldExpiry = nil
+			advanceHoldQueue(currentBook, h)
 		} else {
 			return nil, fmt.Errorf("Releasing hold failed as it is another customer who has the book on-hold: %w", conflictErr)
 			// return nil, errors.New("Someone else has this book on hold. You cannot release the hold on a book that do not currently have on-hold.")
@@ -188,7 +268,8 @@ func releaseHold(currentBook *models.Book, incomingBook *models.Book, provider u
 
 // returnBook
 	// checked-out --> available
-func returnBook(currentBook *models.Book, incomingBook *models.Book, provider utils.DateTimeProvider) (*models.Book, error) {
+	// checked-out --> on-hold (for the first customer in the hold queue)
+func returnBook(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
 	if err := validateIDsForCheckedOut(incomingBook, currentBook); err != nil {
 		return nil, err
 	}
@@ -197,7 +278,10 @@ func returnBook(currentBook *models.Book, incomingBook *models.Book, provider ut
 		if (*currentBook.CheckedOutCustomerID == *incomingBook.CheckedOutCustomerID) {
 			*currentBook.State = "available"
 			currentBook.CheckedOutCustomerID = nil
-			currentBook.TimeUpdated = provider.GetCurrentTime()
+			currentBook.TimeUpdated = h.DateTimeInterface.GetCurrentTime()
+			currentBook.TimeDue = nil
+			currentBook.RenewalCount = nil
+			advanceHoldQueue(currentBook, h)
 		} else {
 			return nil, fmt.Errorf("Returning the book failed as it is another customer who has the book checked-out: %w", conflictErr)
 			// return nil, errors.New("Someone else has this book checked-out. You cannot return a book that you did not check out.")
@@ -210,11 +294,12 @@ func returnBook(currentBook *models.Book, incomingBook *models.Book, provider ut
 // noOperation
 	// available --> available
 	// on-hold --> on-hold (when ID's match)
-func noOperation(currentBook *models.Book, incomingBook *models.Book, provider utils.DateTimeProvider) (*models.Book, error) {
+func noOperation(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
 	return currentBook, nil
 }
 
-var actionTable = map[string]map[string]func(currentBook *models.Book, incomingBook *models.Book, provider utils.DateTimeProvider) (*models.Book, error) {
+// actionTable maps the current state and the requested state to the function carrying out the transition. The handler gives the functions the time and the lending policy
+var actionTable = map[string]map[string]func(currentBook *models.Book, incomingBook *models.Book, h *BooksHandler) (*models.Book, error) {
 	"available": {
 		"available": noOperation,
 		"checked-out": checkout,
@@ -230,64 +315,133 @@ var actionTable = map[string]map[string]func(currentBook *models.Book, incomingB
 	},
 }
 
+// respondWithUpdateError responds with the status code matching an error returned by UpdateAtomically while updating a book or a fine
+func respondWithUpdateError(c *gin.Context, ctx context.Context, err error) {
+	if errors.Is(err, dao.ErrBookNotFound) {
+		respondWithError(c, http.StatusNotFound, "Book not found.")
+	} else if errors.Is(err, dao.ErrFineNotFound) {
+		respondWithError(c, http.StatusNotFound, "Fine not found.")
+	} else if errors.Is(err, holdNotFoundErr) {
+		respondWithError(c, http.StatusNotFound, err.Error())
+	} else if errors.Is(err, preconditionFailedErr) {
+		respondWithError(c, http.StatusPreconditionFailed, err.Error())
+	} else if errors.Is(err, invalidRequestErr) {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+	} else if errors.Is(err, conflictErr) || errors.Is(err, dao.ErrConcurrentUpdate) {
+		respondWithError(c, http.StatusConflict, err.Error())
+	} else {
+		respondWithDAOError(c, ctx, err)
+	}
+}
+
 // UpdateBook allows the client to update the state of an existing book in the library
 func (h *BooksHandler) UpdateBook(c *gin.Context) {
 	isbn := c.Param("isbn")
 
-	currentBook, err := h.BookDAOInterface.Read(isbn)
-	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()})
-		return
-	}
-
-	if currentBook == nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"ERROR": "Book not found."})
-		return
-	}	
-
 	// Decode JSON to book struct
 	incomingBook := new(models.Book) // the "new" keyword allocates memory for models.Book, and returns a pointer to it
-	dec := json.NewDecoder(c.Request.Body)
-	if err := dec.Decode(incomingBook); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"ERROR": err.Error()})
+	if err := h.decodeBook(c, incomingBook); err != nil {
+		respondWithDecodeError(c, err)
 		return
 	}
 
 	// If fields are not nil, ensure they are within range
 	if err := incomingBook.Validate(); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"ERROR": err.Error()})
+		respondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Validate logic
-	if err := validateLogicForUpdateBook(incomingBook, currentBook); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"ERROR": err.Error()})
+	// Patrons may only act for their own customer ID, whatever transition the request turns out to be
+	if err := h.authorizeCustomers(c.Request.Context(), incomingBook); err != nil {
+		respondWithError(c, http.StatusForbidden, err.Error())
 		return
 	}
 
-	// Now we will pass the current state and incoming state to the action table
-	currentState := currentBook.State // this is a pointer
+	ifMatch := c.GetHeader("If-Match")
 
-	incomingState := *incomingBook.State  // due to validateLogicForUpdateBook, we know incomingBook.State is not nil so we can de-reference it
+	ctx, cancel := h.daoContext(c.Request.Context())
+	defer cancel()
 
-	currentBook, err = actionTable[*currentState][incomingState](currentBook, incomingBook, h.DateTimeInterface)
-	if err != nil {
-		if errors.Is(err, invalidRequestErr) {
-			c.IndentedJSON(http.StatusBadRequest, gin.H{"ERROR": err.Error()})
-			return
-		} else if errors.Is(err, conflictErr) {
-			c.IndentedJSON(http.StatusConflict, gin.H{"ERROR": err.Error()})
-			return
-		} else {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()})
-			return
+	// The states of the last transition attempted, which stay empty if the request was rejected before reaching the action table
+	var transitionFrom, transitionTo string
+
+	// The fine charged for a late return, which is created along with the returned book
+	var chargedFine *models.Fine
+
+	// The logic validation and the state transition run inside UpdateAtomicallyWithFines, so they always see the latest stored book.
+	// If two requests race for the same book, the second one is applied to the result of the first, and is rejected by the action table if it is no longer valid.
+	// The balance of a customer checking out the book and the fine of a late return are read and written in the same atomic operation as the book
+	updatedBook, err := h.BookDAOInterface.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		// If the client made the update conditional, make sure it has seen the latest version of the book
+		if err := h.checkIfMatch(ifMatch, currentBook); err != nil {
+			return nil, err
+		}
+
+		// Validate logic
+		if err := validateLogicForUpdateBook(incomingBook, currentBook); err != nil {
+			return nil, err
+		}
+
+		// Now we will pass the current state and incoming state to the action table
+		currentState := currentBook.State // this is a pointer
+
+		incomingState := *incomingBook.State  // due to validateLogicForUpdateBook, we know incomingBook.State is not nil so we can de-reference it
+
+		transitionFrom, transitionTo = *currentState, incomingState
+
+		// Customers owing too much in fines may not check out another book. Checking out a book the customer already has stays a no-op, whatever they owe
+		if incomingState == "checked-out" && *currentState != "checked-out" {
+			balance, err := h.checkoutBalance(fines, incomingBook)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := h.refuseCheckout(balance); err != nil {
+				return nil, err
+			}
 		}
+
+		// The loan being returned, if the request returns the book, so that a late return can be fined
+		var returnedLoan *models.Book
+		if *currentState == "checked-out" && incomingState == "available" {
+			returnedLoan = currentBook.Copy()
+		}
+
+		updatedBook, err := actionTable[*currentState][incomingState](currentBook, incomingBook, h)
+		if err != nil || returnedLoan == nil {
+			return updatedBook, err
+		}
+
+		chargedFine, err = h.chargeFine(fines, returnedLoan, *updatedBook.TimeUpdated)
+		if err != nil {
+			return nil, err
+		}
+
+		return updatedBook, nil
+	})
+
+	if h.StateTransitions != nil && transitionFrom != "" {
+		h.StateTransitions.RecordStateTransition(transitionFrom, transitionTo, transitionOutcome(err))
 	}
 
-	if err := h.BookDAOInterface.Update(currentBook); err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"ERROR": err.Error()})
+	if transitionFrom != "" {
+		slog.DebugContext(c.Request.Context(), "book state transition", slog.String("isbn", isbn), slog.String("from", transitionFrom), slog.String("to", transitionTo), slog.String("outcome", transitionOutcome(err)))
+	}
+
+	if err != nil {
+		respondWithUpdateError(c, ctx, err)
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, currentBook)
-}
\ No newline at end of file
+	if transitionFrom != *updatedBook.State {
+		slog.InfoContext(c.Request.Context(), "book state changed", slog.String("isbn", isbn), slog.String("from", transitionFrom), slog.String("to", *updatedBook.State))
+	}
+
+	if chargedFine != nil {
+		slog.InfoContext(c.Request.Context(), "fine charged", slog.String("isbn", *chargedFine.ISBN), slog.String("fine_id", *chargedFine.ID), slog.Int("days_overdue", *chargedFine.DaysOverdue), slog.Int("amount", *chargedFine.Amount))
+	}
+
+	h.setOverdue(updatedBook)
+	c.Header("ETag", bookETag(updatedBook))
+	c.IndentedJSON(http.StatusOK, updatedBook)
+}
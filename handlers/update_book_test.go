@@ -8,12 +8,14 @@ import (
 	"example/library_project/utils"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"context"
 	"fmt"
 	"log"
 )
@@ -21,6 +23,8 @@ import (
 func TestBooksHandler_UpdateBook(t *testing.T) {
 	arbitraryTimeCreated := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
 	arbitraryTimeUpdated := time.Date(2023, 2, 2, 1, 30, 0, 0, time.UTC)
+	arbitraryTimeDue := arbitraryTimeUpdated.Add(DefaultLoanPeriod)
+	arbitraryHoldExpiry := arbitraryTimeUpdated.Add(DefaultHoldPickupWindow)
 
 	incorrectTimeCreated := time.Date(2023, 3, 1, 1, 30, 0, 0, time.UTC)
 	incorrectTimeUpdated := time.Date(2023, 3, 3, 1, 30, 0, 0, time.UTC)
@@ -366,42 +370,42 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 
 	bookDAO := daoFactory.BookDAO()
 
-	bookDAO.Create(existingBook1)
-	bookDAO.Create(existingBook2)
-	bookDAO.Create(existingBook3)
-	bookDAO.Create(existingBook4)
-	bookDAO.Create(existingBook5)
-	bookDAO.Create(existingBook6)
-	bookDAO.Create(existingBook7)
-	bookDAO.Create(existingBook8)
-	bookDAO.Create(existingBook9)
-	bookDAO.Create(existingBook10)
-	bookDAO.Create(existingBook11)
-	bookDAO.Create(existingBook12)
-	bookDAO.Create(existingBook13)
-	bookDAO.Create(existingBook14)
-	bookDAO.Create(existingBook15)
-	bookDAO.Create(existingBook16)
-	bookDAO.Create(existingBook17)
+	bookDAO.Create(context.Background(), existingBook1)
+	bookDAO.Create(context.Background(), existingBook2)
+	bookDAO.Create(context.Background(), existingBook3)
+	bookDAO.Create(context.Background(), existingBook4)
+	bookDAO.Create(context.Background(), existingBook5)
+	bookDAO.Create(context.Background(), existingBook6)
+	bookDAO.Create(context.Background(), existingBook7)
+	bookDAO.Create(context.Background(), existingBook8)
+	bookDAO.Create(context.Background(), existingBook9)
+	bookDAO.Create(context.Background(), existingBook10)
+	bookDAO.Create(context.Background(), existingBook11)
+	bookDAO.Create(context.Background(), existingBook12)
+	bookDAO.Create(context.Background(), existingBook13)
+	bookDAO.Create(context.Background(), existingBook14)
+	bookDAO.Create(context.Background(), existingBook15)
+	bookDAO.Create(context.Background(), existingBook16)
+	bookDAO.Create(context.Background(), existingBook17)
 	// existingBook18 is used for the "Book not found" test case
-	bookDAO.Create(existingBook19)
-	bookDAO.Create(existingBook20)
-	bookDAO.Create(existingBook21)
-	bookDAO.Create(existingBook22)
-	bookDAO.Create(existingBook23)
-	bookDAO.Create(existingBook24)
-	bookDAO.Create(existingBook25)
-	bookDAO.Create(existingBook26)
-	bookDAO.Create(existingBook27)
-	bookDAO.Create(existingBook28)
-	bookDAO.Create(existingBook29)
-	bookDAO.Create(existingBook30)
-	bookDAO.Create(existingBook31)
-	bookDAO.Create(existingBook32)
-	bookDAO.Create(existingBook33)
-	bookDAO.Create(existingBook34)
-	bookDAO.Create(existingBook35)
-	bookDAO.Create(existingBook36)
+	bookDAO.Create(context.Background(), existingBook19)
+	bookDAO.Create(context.Background(), existingBook20)
+	bookDAO.Create(context.Background(), existingBook21)
+	bookDAO.Create(context.Background(), existingBook22)
+	bookDAO.Create(context.Background(), existingBook23)
+	bookDAO.Create(context.Background(), existingBook24)
+	bookDAO.Create(context.Background(), existingBook25)
+	bookDAO.Create(context.Background(), existingBook26)
+	bookDAO.Create(context.Background(), existingBook27)
+	bookDAO.Create(context.Background(), existingBook28)
+	bookDAO.Create(context.Background(), existingBook29)
+	bookDAO.Create(context.Background(), existingBook30)
+	bookDAO.Create(context.Background(), existingBook31)
+	bookDAO.Create(context.Background(), existingBook32)
+	bookDAO.Create(context.Background(), existingBook33)
+	bookDAO.Create(context.Background(), existingBook34)
+	bookDAO.Create(context.Background(), existingBook35)
+	bookDAO.Create(context.Background(), existingBook36)
 
 	fixedTimeProvider := &utils.TestingDateTimeProvider{
 		ArbitraryTime: arbitraryTimeUpdated,
@@ -409,6 +413,8 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 
 	h := NewBooksHandler(bookDAO, fixedTimeProvider)
 
+	h.AuthenticationDisabled = true
+
 	tests := []struct{
 		description string
 		currentBook *models.Book
@@ -458,6 +464,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 				CheckedOutCustomerID: nil,
 				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
 				TimeUpdated: utils.ToPtr(arbitraryTimeUpdated),
+				HoldExpiry: utils.ToPtr(arbitraryHoldExpiry),
 			},
 			expectedError: nil,
 		},
@@ -480,6 +487,8 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 				CheckedOutCustomerID: utils.ToPtr("02"),
 				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
 				TimeUpdated: utils.ToPtr(arbitraryTimeUpdated),
+				TimeDue: utils.ToPtr(arbitraryTimeDue),
+				RenewalCount: utils.ToPtr(0),
 			},
 			expectedError: nil,
 		},
@@ -590,6 +599,8 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 				CheckedOutCustomerID: utils.ToPtr("06"),
 				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
 				TimeUpdated: utils.ToPtr(arbitraryTimeUpdated),
+				TimeDue: utils.ToPtr(arbitraryTimeDue),
+				RenewalCount: utils.ToPtr(0),
 			},
 			expectedError: nil,
 		},
@@ -630,6 +641,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Invalid state transition requested: conflict"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -647,6 +659,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Returning the book failed as it is another customer who has the book checked-out: conflict"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -664,6 +677,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Checkout failed as another customer has the book checked-out: conflict"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -681,6 +695,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Invalid state transition requested: conflict"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -698,6 +713,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Releasing hold failed as it is another customer who has the book on-hold: conflict"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -715,6 +731,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Checkout failed as another customer has the book on-hold: conflict"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -732,6 +749,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Placing hold failed as another customer has the book on-hold: conflict"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -749,6 +767,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'state' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -766,6 +785,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Invalid state provided. State must be equal to one of: \"available\", \"on-hold\", or \"checked-out\"."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -783,6 +803,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Book not found."),
+				RequestID: nil,
 			},
 		},
 		{
@@ -800,6 +821,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'checkedoutcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -817,6 +839,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'onholdcustomerid' to be null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -834,6 +857,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'checkedoutcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -851,6 +875,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'checkedoutcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -868,6 +893,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'onholdcustomerid' to be null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -885,6 +911,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'checkedoutcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -902,6 +929,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'onholdcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -919,6 +947,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'checkedoutcustomerid' to be null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -936,6 +965,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'onholdcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -953,6 +983,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'onholdcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -970,6 +1001,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'checkedoutcustomerid' to be null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -987,6 +1019,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("Expected 'onholdcustomerid' to be non-null: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -1004,6 +1037,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("'timecreated' cannot be modified: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -1021,6 +1055,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("'timeupdated' cannot be modified: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -1038,6 +1073,7 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			expectedBook: nil,
 			expectedError: &models.ErrorResponse{
 				Message: utils.ToPtr("'timecreated' cannot be modified: invalid request"),
+				RequestID: nil,
 			},
 		},
 		{
@@ -1059,6 +1095,8 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 				CheckedOutCustomerID: utils.ToPtr("02"),
 				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
 				TimeUpdated: utils.ToPtr(arbitraryTimeUpdated),
+				TimeDue: utils.ToPtr(arbitraryTimeDue),
+				RenewalCount: utils.ToPtr(0),
 			},
 			expectedError: nil,
 		},
@@ -1081,6 +1119,8 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 				CheckedOutCustomerID: utils.ToPtr("02"),
 				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
 				TimeUpdated: utils.ToPtr(arbitraryTimeUpdated),
+				TimeDue: utils.ToPtr(arbitraryTimeDue),
+				RenewalCount: utils.ToPtr(0),
 			},
 			expectedError: nil,
 		},
@@ -1103,6 +1143,8 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 				CheckedOutCustomerID: utils.ToPtr("02"),
 				TimeCreated: utils.ToPtr(arbitraryTimeCreated),
 				TimeUpdated: utils.ToPtr(arbitraryTimeUpdated),
+				TimeDue: utils.ToPtr(arbitraryTimeDue),
+				RenewalCount: utils.ToPtr(0),
 			},
 			expectedError: nil,
 		},
@@ -1153,4 +1195,376 @@ func TestBooksHandler_UpdateBook(t *testing.T) {
 			assert.Equal(t, currentTestCase.expectedError, actualError)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestBooksHandler_UpdateBook_ConcurrentCheckout(t *testing.T) {
+	arbitraryTimeCreated := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	arbitraryTimeUpdated := time.Date(2023, 2, 2, 1, 30, 0, 0, time.UTC)
+
+	existingBook1 := &models.Book{
+		ISBN: utils.ToPtr("00001"), 
+		State: utils.ToPtr("available"), 
+		OnHoldCustomerID: nil, 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTimeCreated), 
+		TimeUpdated: nil,
+	}
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	bookDAO.Create(context.Background(), existingBook1)
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTimeUpdated,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	r := gin.Default()
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	// Every customer tries to checkout the same available book at the same time. Exactly one of them must win
+	numberOfCustomers := 20
+	statusCodes := make([]int, numberOfCustomers)
+
+	var wg sync.WaitGroup
+	for customer := 0; customer < numberOfCustomers; customer++ {
+		wg.Add(1)
+		go func(customer int) {
+			defer wg.Done()
+
+			incomingBook := &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("checked-out"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: utils.ToPtr(fmt.Sprintf("%02d", customer)),
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			}
+			bookJSON, _ := json.Marshal(incomingBook)
+
+			req, err := http.NewRequest("PATCH", "/books/00001", bytes.NewBuffer(bookJSON))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			statusCodes[customer] = w.Code
+		}(customer)
+	}
+	wg.Wait()
+
+	winners := 0
+	for _, statusCode := range statusCodes {
+		if statusCode == http.StatusOK {
+			winners++
+		} else {
+			assert.Equal(t, http.StatusConflict, statusCode)
+		}
+	}
+	assert.Equal(t, 1, winners)
+
+	storedBook, err := bookDAO.Read(context.Background(), "00001")
+	assert.Nil(t, err)
+	assert.Equal(t, "checked-out", *storedBook.State)
+	assert.NotNil(t, storedBook.CheckedOutCustomerID)
+}
+
+func TestBooksHandler_UpdateBook_IfMatch(t *testing.T) {
+	arbitraryTimeCreated := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	arbitraryTimeUpdated := time.Date(2023, 2, 2, 1, 30, 0, 0, time.UTC)
+
+	existingBook1 := &models.Book{
+		ISBN: utils.ToPtr("00001"), 
+		State: utils.ToPtr("available"), 
+		OnHoldCustomerID: nil, 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTimeCreated), 
+		TimeUpdated: nil,
+	}
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	bookDAO.Create(context.Background(), existingBook1)
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTimeUpdated,
+	}
+
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+
+	h.AuthenticationDisabled = true
+
+	tests := []struct{
+		description string
+		ifMatch string
+		incomingBook *models.Book
+		expectedStatusCode int
+		expectedETag string
+		expectedState string
+	}{
+		{
+			description: "Stale ETag",
+			ifMatch: "\"0\"",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("on-hold"),
+				OnHoldCustomerID: utils.ToPtr("04"),
+				CheckedOutCustomerID: nil,
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 412,
+			expectedETag: "",
+			expectedState: "available",
+		},
+		{
+			description: "Current ETag",
+			ifMatch: "\"1\"",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("on-hold"),
+				OnHoldCustomerID: utils.ToPtr("04"),
+				CheckedOutCustomerID: nil,
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 200,
+			expectedETag: "\"2\"",
+			expectedState: "on-hold",
+		},
+		{
+			description: "ETag that was current before the previous update",
+			ifMatch: "\"1\"",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("available"),
+				OnHoldCustomerID: utils.ToPtr("04"),
+				CheckedOutCustomerID: nil,
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 412,
+			expectedETag: "",
+			expectedState: "on-hold",
+		},
+		{
+			description: "Idempotent update does not change the ETag",
+			ifMatch: "*",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("on-hold"),
+				OnHoldCustomerID: utils.ToPtr("04"),
+				CheckedOutCustomerID: nil,
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 200,
+			expectedETag: "\"2\"",
+			expectedState: "on-hold",
+		},
+	}
+
+	r := gin.Default()
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		bookJSON, _ := json.Marshal(*currentTestCase.incomingBook)
+
+		req, err := http.NewRequest("PATCH", "/books/00001", bytes.NewBuffer(bookJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", currentTestCase.ifMatch)
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+		assert.Equal(t, currentTestCase.expectedETag, w.Header().Get("ETag"))
+
+		storedBook, err := bookDAO.Read(context.Background(), "00001")
+		assert.Nil(t, err)
+		assert.Equal(t, currentTestCase.expectedState, *storedBook.State)
+	}
+}
+
+// stateTransition is one call recorded by recordingStateTransitionRecorder
+type stateTransition struct {
+	from string
+	to string
+	outcome string
+}
+
+// recordingStateTransitionRecorder keeps every state transition it is notified of
+type recordingStateTransitionRecorder struct {
+	transitions []stateTransition
+}
+
+func (r *recordingStateTransitionRecorder) RecordStateTransition(from string, to string, outcome string) {
+	r.transitions = append(r.transitions, stateTransition{from: from, to: to, outcome: outcome})
+}
+
+func TestBooksHandler_UpdateBook_StateTransitions(t *testing.T) {
+	arbitraryTimeCreated := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+	arbitraryTimeUpdated := time.Date(2023, 2, 2, 1, 30, 0, 0, time.UTC)
+
+	existingBook1 := &models.Book{
+		ISBN: utils.ToPtr("00001"), 
+		State: utils.ToPtr("available"), 
+		OnHoldCustomerID: nil, 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTimeCreated), 
+		TimeUpdated: nil,
+	}
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+
+	if err := daoFactory.Open(); err != nil {
+		log.Fatal("failed to open database connection: ", err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := daoFactory.BookDAO()
+
+	bookDAO.Create(context.Background(), existingBook1)
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: arbitraryTimeUpdated,
+	}
+
+	recorder := &recordingStateTransitionRecorder{transitions: nil}
+	h := NewBooksHandler(bookDAO, fixedTimeProvider)
+	h.AuthenticationDisabled = true
+	h.StateTransitions = recorder
+
+	tests := []struct{
+		description string
+		isbn string
+		incomingBook *models.Book
+		expectedStatusCode int
+		expectedTransition *stateTransition
+	}{
+		{
+			description: "Successful checkout",
+			isbn: "00001",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("checked-out"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: utils.ToPtr("02"),
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 200,
+			expectedTransition: &stateTransition{from: "available", to: "checked-out", outcome: "success"},
+		},
+		{
+			description: "Checkout by another customer",
+			isbn: "00001",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("checked-out"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: utils.ToPtr("03"),
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 409,
+			expectedTransition: &stateTransition{from: "checked-out", to: "checked-out", outcome: "conflict"},
+		},
+		{
+			description: "Return without a customer ID",
+			isbn: "00001",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: utils.ToPtr("available"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: nil,
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 400,
+			expectedTransition: &stateTransition{from: "checked-out", to: "available", outcome: "invalid"},
+		},
+		{
+			description: "Missing state is rejected before reaching the action table",
+			isbn: "00001",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("00001"),
+				State: nil,
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: nil,
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 400,
+			expectedTransition: nil,
+		},
+		{
+			description: "Missing book",
+			isbn: "99999",
+			incomingBook: &models.Book{
+				ISBN: utils.ToPtr("99999"),
+				State: utils.ToPtr("available"),
+				OnHoldCustomerID: nil,
+				CheckedOutCustomerID: nil,
+				TimeCreated: nil,
+				TimeUpdated: nil,
+			},
+			expectedStatusCode: 404,
+			expectedTransition: nil,
+		},
+	}
+
+	r := gin.Default()
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		recorder.transitions = nil
+
+		bookJSON, _ := json.Marshal(*currentTestCase.incomingBook)
+
+		req, err := http.NewRequest("PATCH", "/books/" + currentTestCase.isbn, bytes.NewBuffer(bookJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		if currentTestCase.expectedTransition == nil {
+			assert.Empty(t, recorder.transitions)
+		} else {
+			assert.Equal(t, []stateTransition{*currentTestCase.expectedTransition}, recorder.transitions)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package logging writes structured JSON logs with log/slog, and tags every line logged while serving a request with the request's ID and trace
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDKey is the log attribute holding the request ID
+const requestIDKey = "request_id"
+
+// traceIDKey and spanIDKey are the log attributes holding the trace and span of the request, so that log lines can be matched with spans
+const (
+	traceIDKey = "trace_id"
+	spanIDKey = "span_id"
+)
+
+// New returns a logger writing JSON lines to w, dropping records less severe than level.
+// Records logged with a context carrying a request ID or a recording span, such as with slog.InfoContext(c.Request.Context(), ...), are tagged with them
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(&contextHandler{
+		handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}),
+	})
+}
+
+// ParseLevel parses one of "debug", "info", "warn" or "error"
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+
+	return level, nil
+}
+
+// contextHandler adds the request ID and the trace found in the context of each record before passing it on
+type contextHandler struct {
+	handler slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	requestID := RequestID(ctx)
+	spanContext := trace.SpanContextFromContext(ctx)
+
+	if requestID != "" || spanContext.IsValid() {
+		record = record.Clone()
+	}
+
+	if requestID != "" {
+		record.AddAttrs(slog.String(requestIDKey, requestID))
+	}
+
+	if spanContext.IsValid() {
+		record.AddAttrs(slog.String(traceIDKey, spanContext.TraceID().String()), slog.String(spanIDKey, spanContext.SpanID().String()))
+	}
+
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{handler: h.handler.WithGroup(name)}
+}
@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct{
+		description string
+		name string
+		expectedLevel slog.Level
+		expectErr bool
+	}{
+		{description: "Debug", name: "debug", expectedLevel: slog.LevelDebug, expectErr: false},
+		{description: "Info", name: "info", expectedLevel: slog.LevelInfo, expectErr: false},
+		{description: "Warn", name: "warn", expectedLevel: slog.LevelWarn, expectErr: false},
+		{description: "Error", name: "error", expectedLevel: slog.LevelError, expectErr: false},
+		{description: "Unknown level", name: "verbose", expectedLevel: slog.LevelInfo, expectErr: true},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		level, err := ParseLevel(currentTestCase.name)
+
+		if currentTestCase.expectErr {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, currentTestCase.expectedLevel, level)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct{
+		description string
+		ctx context.Context
+		level slog.Level
+		logAt slog.Level
+		expectLine bool
+		expectedRequestID interface{}
+	}{
+		{
+			description: "Line logged with a request ID",
+			ctx: WithRequestID(context.Background(), "abc123"),
+			level: slog.LevelInfo,
+			logAt: slog.LevelInfo,
+			expectLine: true,
+			expectedRequestID: "abc123",
+		},
+		{
+			description: "Line logged without a request ID",
+			ctx: context.Background(),
+			level: slog.LevelInfo,
+			logAt: slog.LevelWarn,
+			expectLine: true,
+			expectedRequestID: nil,
+		},
+		{
+			description: "Line below the configured level",
+			ctx: WithRequestID(context.Background(), "abc123"),
+			level: slog.LevelInfo,
+			logAt: slog.LevelDebug,
+			expectLine: false,
+			expectedRequestID: nil,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		var out bytes.Buffer
+		logger := New(&out, currentTestCase.level).With(slog.String("component", "test"))
+
+		logger.Log(currentTestCase.ctx, currentTestCase.logAt, "something happened", slog.Int("count", 2))
+
+		if !currentTestCase.expectLine {
+			assert.Empty(t, out.String())
+			continue
+		}
+
+		// Every line is a JSON object
+		var line map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "something happened", line["msg"])
+		assert.Equal(t, currentTestCase.logAt.String(), line["level"])
+		assert.Equal(t, "test", line["component"])
+		assert.Equal(t, 2.0, line["count"])
+		assert.Equal(t, currentTestCase.expectedRequestID, line["request_id"])
+	}
+}
+
+func TestNew_Trace(t *testing.T) {
+	tracerProvider := sdktrace.NewTracerProvider()
+	ctx, span := tracerProvider.Tracer("test").Start(WithRequestID(context.Background(), "abc123"), "request")
+	defer span.End()
+
+	var out bytes.Buffer
+	New(&out, slog.LevelInfo).InfoContext(ctx, "something happened")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	// Log lines carry the trace and span of the request, so that they can be found from the trace
+	assert.Equal(t, "abc123", line["request_id"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), line["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), line["span_id"])
+}
@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"example/library_project/models"
+
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, slog.LevelInfo)
+
+	r := gin.New()
+	r.Use(Middleware(logger), Recovery(logger))
+	r.GET("/books/:isbn", func(c *gin.Context) {
+		// Handlers log with the request's context, so that their lines carry the request ID too
+		logger.InfoContext(c.Request.Context(), "handler called")
+
+		switch c.Param("isbn") {
+		case "panic":
+			panic("something went wrong")
+		case "broken":
+			c.Status(http.StatusInternalServerError)
+		default:
+			c.Status(http.StatusOK)
+		}
+	})
+
+	tests := []struct{
+		description string
+		path string
+		incomingRequestID string
+		expectPropagated bool
+		expectedStatusCode int
+		expectedLevel string
+	}{
+		{
+			description: "Request ID is generated",
+			path: "/books/00001",
+			incomingRequestID: "",
+			expectPropagated: false,
+			expectedStatusCode: 200,
+			expectedLevel: "INFO",
+		},
+		{
+			description: "Request ID is propagated",
+			path: "/books/00001",
+			incomingRequestID: "upstream-1234",
+			expectPropagated: true,
+			expectedStatusCode: 200,
+			expectedLevel: "INFO",
+		},
+		{
+			description: "Request ID that is too long is replaced",
+			path: "/books/00001",
+			incomingRequestID: strings.Repeat("a", 129),
+			expectPropagated: false,
+			expectedStatusCode: 200,
+			expectedLevel: "INFO",
+		},
+		{
+			description: "Request ID with control characters is replaced",
+			path: "/books/00001",
+			incomingRequestID: "abc\tdef",
+			expectPropagated: false,
+			expectedStatusCode: 200,
+			expectedLevel: "INFO",
+		},
+		{
+			description: "Server error is logged as an error",
+			path: "/books/broken",
+			incomingRequestID: "upstream-5678",
+			expectPropagated: true,
+			expectedStatusCode: 500,
+			expectedLevel: "ERROR",
+		},
+		{
+			description: "Panic is recovered",
+			path: "/books/panic",
+			incomingRequestID: "upstream-9012",
+			expectPropagated: true,
+			expectedStatusCode: 500,
+			expectedLevel: "ERROR",
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		out.Reset()
+
+		req, err := http.NewRequest("GET", currentTestCase.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if currentTestCase.incomingRequestID != "" {
+			req.Header.Set(RequestIDHeader, currentTestCase.incomingRequestID)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		requestID := w.Header().Get(RequestIDHeader)
+		if currentTestCase.expectPropagated {
+			assert.Equal(t, currentTestCase.incomingRequestID, requestID)
+		} else {
+			assert.Len(t, requestID, 32)
+		}
+
+		// Every line logged while serving the request carries its ID, and the last one describes the request
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		var lastLine map[string]interface{}
+		for _, line := range lines {
+			lastLine = map[string]interface{}{}
+			if err := json.Unmarshal([]byte(line), &lastLine); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, requestID, lastLine["request_id"])
+		}
+
+		assert.Equal(t, "request served", lastLine["msg"])
+		assert.Equal(t, currentTestCase.expectedLevel, lastLine["level"])
+		assert.Equal(t, "GET", lastLine["method"])
+		assert.Equal(t, "/books/:isbn", lastLine["route"])
+		assert.Equal(t, currentTestCase.path, lastLine["path"])
+		assert.Equal(t, float64(currentTestCase.expectedStatusCode), lastLine["status"])
+
+		if currentTestCase.path == "/books/panic" {
+			var errorResponse models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&errorResponse); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, models.ErrorResponse{Message: errorResponse.Message, RequestID: &requestID}, errorResponse)
+			assert.Equal(t, 3, len(lines))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDHeader is the header from which a request ID is propagated, and in which it is returned
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the length of a propagated request ID, so that a client cannot bloat every log line
+const maxRequestIDLength = 128
+
+// requestIDContextKey is the key of the request ID in a context
+type requestIDContextKey struct{}
+
+// WithRequestID returns a copy of ctx carrying requestID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// newRequestID returns a random 128-bit ID, hex-encoded
+func newRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// crypto/rand does not fail on supported platforms, and a request should not fail for want of an ID
+		return "unknown"
+	}
+
+	return hex.EncodeToString(id[:])
+}
+
+// validRequestID reports whether a request ID received from a client is safe to propagate: not empty, not too long, and made of printable ASCII
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,103 +1,298 @@
 package main
 
 import (
+	"example/library_project/auth"
+	"example/library_project/config"
 	"example/library_project/handlers"
+	"example/library_project/logging"
+	"example/library_project/metrics"
+	"example/library_project/ratelimit"
+	"example/library_project/tracing"
 	// "example/library_project/models"
 	"example/library_project/dao"
 	"example/library_project/utils"
 
 	"example/library_project/dao/inmemorydao"
 	"example/library_project/dao/mysqldao"
+	"example/library_project/dao/postgresdao"
+	"example/library_project/dao/sqlitedao"
 
 	"example/library_project/testdata"
 
-	// "net/http"
+	"net/http"
 	"github.com/gin-gonic/gin"
-	// "errors"
-	// "time"
+	"database/sql"
+	"go.opentelemetry.io/otel"
+	"errors"
+	"time"
 	// "encoding/json"
+	"context"
 	"fmt"
 
 	// "reflect"
 	// "strconv"
 
-	"log"
+	"log/slog"
 
 	"os"
 	"os/signal"
 	"syscall"
 )
 
+// fatal logs msg with err and exits, replacing log.Fatal so that the last line is JSON like every other
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.String("error", err.Error()))
+	os.Exit(1)
+}
+
+// readinessTimeout bounds the checks made by the readiness endpoint, which load balancers expect to answer quickly
+const readinessTimeout = 2 * time.Second
+
+// newDAOFactory returns the factory for the storage solution selected by the validated database configuration
+func newDAOFactory(databaseConfig config.DatabaseConfig) (dao.DAOFactory, error) {
+	pool := dao.ConnectionPool{
+		MaxOpenConns: databaseConfig.Pool.MaxOpenConns,
+		MaxIdleConns: databaseConfig.Pool.MaxIdleConns,
+		ConnMaxLifetime: databaseConfig.Pool.ConnMaxLifetime,
+		ConnMaxIdleTime: databaseConfig.Pool.ConnMaxIdleTime,
+	}
+
+	switch databaseConfig.Driver {
+	case "mysql":
+		mysqlDAOFactory := mysqldao.NewMySQLDAOFactoryFromDSN(databaseConfig.MySQLDSN())
+		mysqlDAOFactory.Pool = pool
+
+		// Pending migrations are applied on startup unless migrate is "verify", in which case startup fails instead
+		if databaseConfig.Migrate == "verify" {
+			mysqlDAOFactory.MigrationMode = mysqldao.MigrationModeVerify
+		}
+
+		return mysqlDAOFactory, nil
+	case "postgres":
+		postgresDAOFactory := postgresdao.NewPostgresDAOFactoryFromDSN(databaseConfig.PostgresDSN())
+		postgresDAOFactory.Pool = pool
+		return postgresDAOFactory, nil
+	case "sqlite":
+		// The database file is created if it does not exist
+		return sqlitedao.NewSQLiteDAOFactory(databaseConfig.Path), nil
+	case "inmemory":
+		return inmemorydao.NewInMemoryDAOFactory(), nil
+	default:
+		return nil, fmt.Errorf("unexpected dao selection %q", databaseConfig.Driver)
+	}
+}
+
 func main() {
 
+	// Until the configuration is loaded, log at the default level
+	slog.SetDefault(logging.New(os.Stdout, slog.LevelInfo))
+
+	// Every setting, from the file named by LIBRARY_CONFIG_FILE and the environment
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+
+	logLevel, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+	logger := logging.New(os.Stdout, logLevel)
+	slog.SetDefault(logger)
+
+	slog.Info("effective configuration", slog.String("config", cfg.String()))
+
+	// Spans are only exported when an exporter is configured, but trace context is always propagated
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+
 	// DAO selection
-	var daoFactory dao.DAOFactory
-	daoSelection := os.Getenv("DAO_SELECTION")
-	testMode := os.Getenv("TEST_MODE")
-
-	if daoSelection == "inmemory" {
-		daoFactory = inmemorydao.NewInMemoryDAOFactory()
-	} else if daoSelection == "mysql" {
-		// Environment variables for database
-		dbUsername := os.Getenv("LIBRARY_DB_USERNAME")
-		dbPassword := os.Getenv("LIBRARY_DB_PASSWORD")
-		dbHost := os.Getenv("LIBRARY_DB_HOST")
-		dbPort := os.Getenv("LIBRARY_DB_PORT")
-		dbName := os.Getenv("LIBRARY_DB_NAME")
-
-		daoFactory = mysqldao.NewMySQLDAOFactory(dbUsername, dbPassword, dbHost, dbPort, dbName)
-	} else {
-		log.Fatal("unexpected dao selection")
+	daoFactory, err := newDAOFactory(cfg.Database)
+	if err != nil {
+		fatal("failed to select the storage solution", err)
+	}
+
+	// "migrate" runs a schema migration command instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		mysqlDAOFactory, ok := daoFactory.(*mysqldao.MySQLDAOFactory)
+		if !ok {
+			fatal("invalid command", errors.New("the migrate command requires DAO_SELECTION=mysql"))
+		}
+
+		if err := runMigrateCommand(mysqlDAOFactory, os.Args[2:]); err != nil {
+			fatal("the migrate command failed", err)
+		}
+		return
 	}
 
 	// Open the database connection
 	if err := daoFactory.Open(); err != nil {
-		log.Fatal("failed to open database connection: ", err)
+		fatal("failed to open database connection", err)
 	}
 
-	// Defer closing the database connection
-	signalsChannel := make(chan os.Signal, 1)
-	signal.Notify(signalsChannel, syscall.SIGINT)
+	// Every DAO call is timed and its errors counted, labeled by the storage solution
+	appMetrics := metrics.New()
+	bookDAO := appMetrics.InstrumentBookDAO(daoFactory.BookDAO(), cfg.Database.Driver)
 
-	go func() {
-		receivedSignal := <- signalsChannel
-		fmt.Println("RECEIVED SIGNAL...")
+	fineDAO := appMetrics.InstrumentFineDAO(daoFactory.FineDAO(), cfg.Database.Driver)
 
-		if receivedSignal == syscall.SIGINT {
-			fmt.Println("SIGNAL IS SIGINT...")
-			daoFactory.Close()
-			fmt.Println("ABOUT TO EXIT...")
-			os.Exit(0)
-		}
-	}()
+	// Every DAO call is also recorded as a span of the request's trace
+	bookDAO = tracing.InstrumentBookDAO(bookDAO, otel.GetTracerProvider(), cfg.Database.Driver)
+	fineDAO = tracing.InstrumentFineDAO(fineDAO, otel.GetTracerProvider(), cfg.Database.Driver)
 
-	// Instantiate DAO
-	bookDAO := daoFactory.BookDAO()
+	// The SQL storage solutions also export the statistics of their connection pool
+	if dbStatsSource, ok := daoFactory.(interface{ DB() *sql.DB }); ok {
+		appMetrics.RegisterDBStats(dbStatsSource.DB(), cfg.Database.Driver)
+	}
 
 	// If in integration test mode, instantiate test data and add to database
-	if testMode == "integration" {
+	if cfg.TestMode == "integration" {
 		testBooks, err := testdata.InstantiateIntegrationTestData()
 		if err != nil{
-			log.Fatal("failed to instantiate test data")
+			fatal("failed to instantiate test data", err)
 		}
 
 		for _, currentTestBook := range testBooks {
-			if err := bookDAO.Create(currentTestBook); err != nil{
-				log.Fatal("failed to add test data to DAO")
+			if err := bookDAO.Create(context.Background(), currentTestBook); err != nil{
+				fatal("failed to add test data to DAO", err)
 			}
 		}
 	}
 
 	realTimeProvider := &utils.ProductionDateTimeProvider{}
 	h := handlers.NewBooksHandler(bookDAO, realTimeProvider)
+	h.DAOTimeout = cfg.Database.Timeout
+	h.MaxBodyBytes = int64(cfg.Server.MaxBodyBytes)
+	h.LoanPeriod = cfg.Loans.Period
+	h.MaxRenewals = cfg.Loans.MaxRenewals
+	h.HoldPickupWindow = cfg.Loans.HoldPickupWindow
+	h.FineDAOInterface = fineDAO
+	h.FineDailyRate = cfg.Fines.DailyRate
+	h.FineCap = cfg.Fines.Cap
+	h.FineCheckoutThreshold = cfg.Fines.CheckoutThreshold
+	h.StateTransitions = appMetrics
+	h.AuthenticationDisabled = !cfg.Auth.Enabled
+
+	// gin's own text logs are replaced by one JSON line per request, tagged with its X-Request-ID
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
 
-	router := gin.Default()
-	router.GET("/books", h.GetAllBooks)
-	router.GET("/books/:isbn", h.GetIndividualBook)
-	router.POST("/books", h.CreateBook)
-	router.DELETE("/books/:isbn", h.DeleteBook)
-	router.PATCH("/books/:isbn", h.UpdateBook)
+	// X-Forwarded-For only gives the client IP when the request came through a trusted proxy, so that clients cannot choose the IP they are rate limited by
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("invalid configuration", err)
+	}
+
+	// The request's span is started first, so that the log lines of the request carry its trace
+	router.Use(tracing.Middleware(otel.GetTracerProvider(), otel.GetTextMapPropagator()), logging.Middleware(logger), logging.Recovery(logger), appMetrics.Middleware())
+
+	// When authentication is enabled, only the book and customer routes require credentials, so that probes and scrapers need none
+	books := router.Group("/books")
+	customers := router.Group("/customers")
+	if cfg.Auth.Enabled {
+		authenticator, err := auth.Load(cfg.Auth)
+		if err != nil {
+			daoFactory.Close()
+			fatal("failed to load the authentication keys", err)
+		}
+		// Failed authentications are throttled by IP before the credentials are checked, so that keys and tokens cannot be guessed
+		if cfg.RateLimit.Enabled {
+			authFailureLimiter := ratelimit.NewLimiter(cfg.RateLimit.AuthFailures.RequestsPerSecond, cfg.RateLimit.AuthFailures.Burst).AuthFailureMiddleware()
+			books.Use(authFailureLimiter)
+			customers.Use(authFailureLimiter)
+		}
+		books.Use(authenticator.Middleware())
+		customers.Use(authenticator.Middleware())
+	} else {
+		slog.Warn("authentication is disabled by auth.enabled, anyone who can reach the server can modify books")
+	}
+
+	// Reads and writes are throttled by separate buckets, after authentication so that clients are told apart by their identity.
+	// The book and customer routes share the buckets, so that a client's budget is the same whichever routes it calls
+	reads := books.Group("")
+	writes := books.Group("")
+	customerReads := customers.Group("")
+	customerWrites := customers.Group("")
+	if cfg.RateLimit.Enabled {
+		readLimiter := ratelimit.NewLimiter(cfg.RateLimit.Read.RequestsPerSecond, cfg.RateLimit.Read.Burst).Middleware()
+		writeLimiter := ratelimit.NewLimiter(cfg.RateLimit.Write.RequestsPerSecond, cfg.RateLimit.Write.Burst).Middleware()
+		reads.Use(readLimiter)
+		writes.Use(writeLimiter)
+		customerReads.Use(readLimiter)
+		customerWrites.Use(writeLimiter)
+	}
+	reads.GET("", h.GetAllBooks)
+	reads.GET("/overdue", h.GetOverdueBooks)
+	reads.GET("/:isbn", h.GetIndividualBook)
+	reads.GET("/:isbn/holds/:customerid", h.GetHold)
+	writes.POST("", h.CreateBook)
+	writes.DELETE("/:isbn", h.DeleteBook)
+	writes.PATCH("/:isbn", h.UpdateBook)
+	writes.POST("/:isbn/renewals", h.RenewBook)
+	writes.POST("/:isbn/holds", h.JoinHoldQueue)
+	writes.DELETE("/:isbn/holds/:customerid", h.LeaveHoldQueue)
+	customerReads.GET("/:customerid/fines", h.GetFines)
+	customerWrites.PATCH("/:customerid/fines/:fineid", h.UpdateFine)
+
+	// Liveness only tells whether the process is up, while readiness also pings the storage solution
+	healthHandler := handlers.NewHealthHandler(map[string]handlers.HealthCheck{"database": daoFactory.Ping}, readinessTimeout)
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+
+	router.GET("/metrics", gin.WrapH(appMetrics.Handler()))
+
+	server := &http.Server{
+		Addr: cfg.Server.Address(),
+		Handler: router,
+		ReadTimeout: cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout: cfg.Server.IdleTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+	}
+
+	listener, err := newListener(cfg.Server)
+	if err != nil {
+		daoFactory.Close()
+		fatal("failed to listen", err)
+	}
+
+	signalContext, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if cfg.Server.TLSEnabled() {
+		slog.Info("listening for HTTPS", slog.String("address", listener.Addr().String()))
+	} else {
+		slog.Info("listening for HTTP", slog.String("address", listener.Addr().String()))
+	}
+	// Expired holds are released in the background for as long as the server runs
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		h.RunHoldSweeper(signalContext, cfg.Loans.HoldSweepInterval)
+	}()
+
+	serveErr := serve(signalContext, server, listener, cfg.Server.ShutdownTimeout)
+
+	// The server can also stop without a signal, so the sweeper is stopped explicitly
+	stop()
+	<-sweeperDone
+
+	// Only close the database connection once no handler or sweeper can use it anymore
+	if err := daoFactory.Close(); err != nil {
+		slog.Error("failed to close database connection", slog.String("error", err.Error()))
+	}
+
+	// Export the spans that are still buffered
+	tracingContext, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingContext); err != nil {
+		slog.Error("failed to export the remaining spans", slog.String("error", err.Error()))
+	}
+
+	if serveErr != nil {
+		fatal("the server did not shut down cleanly", serveErr)
+	}
 
-	fmt.Println("ABOUT TO CALL ROUTER.RUN...")
-	router.Run("localhost:8080")
+	slog.Info("shut down cleanly")
 }
\ No newline at end of file
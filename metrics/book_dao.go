@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"time"
+)
+
+// instrumentedBookDAO times every call to the wrapped BookDAO and counts the calls that fail
+type instrumentedBookDAO struct {
+	bookDAO dao.BookDAO
+	backend string
+	metrics *Metrics
+}
+
+// InstrumentBookDAO wraps bookDAO so that its calls are reported under the given backend name, such as "mysql"
+func (m *Metrics) InstrumentBookDAO(bookDAO dao.BookDAO, backend string) dao.BookDAO {
+	return &instrumentedBookDAO{
+		bookDAO: bookDAO,
+		backend: backend,
+		metrics: m,
+	}
+}
+
+// errorKind classifies a DAO error, so that expected outcomes such as a missing book can be told apart from failures of the backend
+func errorKind(err error) string {
+	if errors.Is(err, dao.ErrBookNotFound) || errors.Is(err, dao.ErrFineNotFound) {
+		return "not_found"
+	} else if errors.Is(err, dao.ErrBookAlreadyExists) || errors.Is(err, dao.ErrFineAlreadyExists) {
+		return "already_exists"
+	} else if errors.Is(err, dao.ErrConcurrentUpdate) {
+		return "concurrent_update"
+	} else if errors.Is(err, dao.ErrVersionMismatch) {
+		return "version_mismatch"
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	} else if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+
+	return "other"
+}
+
+// observe records a call to operation that started at start and returned err
+func (d *instrumentedBookDAO) observe(operation string, start time.Time, err error) {
+	d.metrics.daoOperationDuration.WithLabelValues(d.backend, operation).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		d.metrics.daoOperationErrors.WithLabelValues(d.backend, operation, errorKind(err)).Inc()
+	}
+}
+
+func (d *instrumentedBookDAO) Create(ctx context.Context, newBook *models.Book) error {
+	start := time.Now()
+	err := d.bookDAO.Create(ctx, newBook)
+	d.observe("Create", start, err)
+
+	return err
+}
+
+func (d *instrumentedBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	start := time.Now()
+	book, err := d.bookDAO.Read(ctx, isbn)
+	d.observe("Read", start, err)
+
+	return book, err
+}
+
+func (d *instrumentedBookDAO) ReadAll(ctx context.Context) ([]*models.Book, error) {
+	start := time.Now()
+	books, err := d.bookDAO.ReadAll(ctx)
+	d.observe("ReadAll", start, err)
+
+	return books, err
+}
+
+func (d *instrumentedBookDAO) Query(ctx context.Context, query dao.BookQuery) ([]*models.Book, error) {
+	start := time.Now()
+	books, err := d.bookDAO.Query(ctx, query)
+	d.observe("Query", start, err)
+
+	return books, err
+}
+
+func (d *instrumentedBookDAO) Update(ctx context.Context, book *models.Book) error {
+	start := time.Now()
+	err := d.bookDAO.Update(ctx, book)
+	d.observe("Update", start, err)
+
+	return err
+}
+
+func (d *instrumentedBookDAO) Delete(ctx context.Context, book *models.Book) error {
+	start := time.Now()
+	err := d.bookDAO.Delete(ctx, book)
+	d.observe("Delete", start, err)
+
+	return err
+}
+
+func (d *instrumentedBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	// Errors returned by modify are the caller's own decisions, such as a rejected state transition, so they are not counted as DAO errors
+	var modifyErr error
+	start := time.Now()
+	book, err := d.bookDAO.UpdateAtomically(ctx, isbn, func(currentBook *models.Book) (*models.Book, error) {
+		modifiedBook, err := modify(currentBook)
+		modifyErr = err
+		return modifiedBook, err
+	})
+
+	if err != nil && modifyErr != nil && errors.Is(err, modifyErr) {
+		d.observe("UpdateAtomically", start, nil)
+	} else {
+		d.observe("UpdateAtomically", start, err)
+	}
+
+	return book, err
+}
+
+func (d *instrumentedBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	// As for UpdateAtomically, errors returned by modify are not counted as DAO errors
+	var modifyErr error
+	start := time.Now()
+	book, err := d.bookDAO.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		modifiedBook, err := modify(currentBook, fines)
+		modifyErr = err
+		return modifiedBook, err
+	})
+
+	if err != nil && modifyErr != nil && errors.Is(err, modifyErr) {
+		d.observe("UpdateAtomicallyWithFines", start, nil)
+	} else {
+		d.observe("UpdateAtomicallyWithFines", start, err)
+	}
+
+	return book, err
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"example/library_project/dao"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_InstrumentBookDAO(t *testing.T) {
+	m := NewWithRegistry(prometheus.NewRegistry(), prometheus.NewRegistry())
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	bookDAO := m.InstrumentBookDAO(daoFactory.BookDAO(), "inmemory")
+
+	newBook := func() *models.Book {
+		return &models.Book{
+			ISBN: utils.ToPtr("00001"),
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)),
+			TimeUpdated: nil,
+		}
+	}
+
+	canceledContext, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rejectedErr := errors.New("rejected by the caller")
+
+	tests := []struct{
+		description string
+		call func() error
+		expectedOperation string
+		expectedErrorKind string
+	}{
+		{
+			description: "Successful Create",
+			call: func() error { return bookDAO.Create(context.Background(), newBook()) },
+			expectedOperation: "Create",
+			expectedErrorKind: "",
+		},
+		{
+			description: "Create of an existing book",
+			call: func() error { return bookDAO.Create(context.Background(), newBook()) },
+			expectedOperation: "Create",
+			expectedErrorKind: "already_exists",
+		},
+		{
+			description: "Read of a missing book is not an error",
+			call: func() error { _, err := bookDAO.Read(context.Background(), "99999"); return err },
+			expectedOperation: "Read",
+			expectedErrorKind: "",
+		},
+		{
+			description: "ReadAll with a canceled context",
+			call: func() error { _, err := bookDAO.ReadAll(canceledContext); return err },
+			expectedOperation: "ReadAll",
+			expectedErrorKind: "canceled",
+		},
+		{
+			description: "Successful Query",
+			call: func() error { _, err := bookDAO.Query(context.Background(), dao.BookQuery{}); return err },
+			expectedOperation: "Query",
+			expectedErrorKind: "",
+		},
+		{
+			description: "Successful Update",
+			call: func() error { return bookDAO.Update(context.Background(), newBook()) },
+			expectedOperation: "Update",
+			expectedErrorKind: "",
+		},
+		{
+			description: "UpdateAtomically rejected by modify is not a DAO error",
+			call: func() error {
+				_, err := bookDAO.UpdateAtomically(context.Background(), "00001", func(currentBook *models.Book) (*models.Book, error) {
+					return nil, rejectedErr
+				})
+				return err
+			},
+			expectedOperation: "UpdateAtomically",
+			expectedErrorKind: "",
+		},
+		{
+			description: "UpdateAtomically of a missing book",
+			call: func() error {
+				_, err := bookDAO.UpdateAtomically(context.Background(), "99999", func(currentBook *models.Book) (*models.Book, error) {
+					return currentBook, nil
+				})
+				return err
+			},
+			expectedOperation: "UpdateAtomically",
+			expectedErrorKind: "not_found",
+		},
+		{
+			description: "UpdateAtomicallyWithFines rejected by modify is not a DAO error",
+			call: func() error {
+				_, err := bookDAO.UpdateAtomicallyWithFines(context.Background(), "00001", func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+					return nil, rejectedErr
+				})
+				return err
+			},
+			expectedOperation: "UpdateAtomicallyWithFines",
+			expectedErrorKind: "",
+		},
+		{
+			description: "Successful Delete",
+			call: func() error { return bookDAO.Delete(context.Background(), newBook()) },
+			expectedOperation: "Delete",
+			expectedErrorKind: "",
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		errorsBefore := testutil.CollectAndCount(m.daoOperationErrors)
+
+		currentTestCase.call()
+
+		if currentTestCase.expectedErrorKind == "" {
+			assert.Equal(t, errorsBefore, testutil.CollectAndCount(m.daoOperationErrors))
+		} else {
+			assert.Equal(t, 1.0, testutil.ToFloat64(m.daoOperationErrors.WithLabelValues("inmemory", currentTestCase.expectedOperation, currentTestCase.expectedErrorKind)))
+		}
+	}
+
+	// Every call is timed whatever its outcome, so each operation has been timed as many times as it was called
+	expectedCounts := map[string]uint64{"Create": 2, "Read": 1, "ReadAll": 1, "Query": 1, "Update": 1, "UpdateAtomically": 2, "UpdateAtomicallyWithFines": 1, "Delete": 1}
+	for operation, expectedCount := range expectedCounts {
+		assert.Equal(t, expectedCount, sampleCount(t, m.daoOperationDuration, "inmemory", operation), operation)
+	}
+}
+
+// sampleCount returns how many observations the histogram has for the given label values
+func sampleCount(t *testing.T, histogram *prometheus.HistogramVec, labelValues ...string) uint64 {
+	metric, err := histogram.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collected := make(chan prometheus.Metric, 1)
+	metric.(prometheus.Histogram).Collect(collected)
+
+	var written dto.Metric
+	if err := (<-collected).Write(&written); err != nil {
+		t.Fatal(err)
+	}
+
+	return written.GetHistogram().GetSampleCount()
+}
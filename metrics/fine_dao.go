@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+	"time"
+)
+
+// instrumentedFineDAO times every call to the wrapped FineDAO and counts the calls that fail, under the same metrics as instrumentedBookDAO
+type instrumentedFineDAO struct {
+	fineDAO dao.FineDAO
+	backend string
+	metrics *Metrics
+}
+
+// InstrumentFineDAO wraps fineDAO so that its calls are reported under the given backend name. Its operations are prefixed with "Fine", such as "FineCreate", to tell them apart from those of the BookDAO
+func (m *Metrics) InstrumentFineDAO(fineDAO dao.FineDAO, backend string) dao.FineDAO {
+	return &instrumentedFineDAO{
+		fineDAO: fineDAO,
+		backend: backend,
+		metrics: m,
+	}
+}
+
+// observe records a call to operation that started at start and returned err
+func (d *instrumentedFineDAO) observe(operation string, start time.Time, err error) {
+	d.metrics.daoOperationDuration.WithLabelValues(d.backend, "Fine" + operation).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		d.metrics.daoOperationErrors.WithLabelValues(d.backend, "Fine" + operation, errorKind(err)).Inc()
+	}
+}
+
+func (d *instrumentedFineDAO) Create(ctx context.Context, newFine *models.Fine) error {
+	start := time.Now()
+	err := d.fineDAO.Create(ctx, newFine)
+	d.observe("Create", start, err)
+
+	return err
+}
+
+func (d *instrumentedFineDAO) Read(ctx context.Context, id string) (*models.Fine, error) {
+	start := time.Now()
+	fine, err := d.fineDAO.Read(ctx, id)
+	d.observe("Read", start, err)
+
+	return fine, err
+}
+
+func (d *instrumentedFineDAO) ReadByCustomer(ctx context.Context, customerID string) ([]*models.Fine, error) {
+	start := time.Now()
+	fines, err := d.fineDAO.ReadByCustomer(ctx, customerID)
+	d.observe("ReadByCustomer", start, err)
+
+	return fines, err
+}
+
+func (d *instrumentedFineDAO) UpdateAtomically(ctx context.Context, id string, modify func(currentFine *models.Fine) (*models.Fine, error)) (*models.Fine, error) {
+	// Errors returned by modify are the caller's own decisions, such as a fine that was already paid, so they are not counted as DAO errors
+	var modifyErr error
+	start := time.Now()
+	fine, err := d.fineDAO.UpdateAtomically(ctx, id, func(currentFine *models.Fine) (*models.Fine, error) {
+		modifiedFine, err := modify(currentFine)
+		modifyErr = err
+		return modifiedFine, err
+	})
+
+	if err != nil && modifyErr != nil && errors.Is(err, modifyErr) {
+		d.observe("UpdateAtomically", start, nil)
+	} else {
+		d.observe("UpdateAtomically", start, err)
+	}
+
+	return fine, err
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_InstrumentFineDAO(t *testing.T) {
+	m := NewWithRegistry(prometheus.NewRegistry(), prometheus.NewRegistry())
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	fineDAO := m.InstrumentFineDAO(daoFactory.FineDAO(), "inmemory")
+
+	newFine := func() *models.Fine {
+		return &models.Fine{
+			ID: utils.ToPtr("f1"),
+			CustomerID: utils.ToPtr("01"),
+			ISBN: utils.ToPtr("00001"),
+			DaysOverdue: utils.ToPtr(1),
+			Amount: utils.ToPtr(25),
+			State: utils.ToPtr("outstanding"),
+			TimeCreated: utils.ToPtr(time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)),
+			TimeUpdated: nil,
+		}
+	}
+
+	canceledContext, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rejectedErr := errors.New("rejected by the caller")
+
+	tests := []struct{
+		description string
+		call func() error
+		expectedOperation string
+		expectedErrorKind string
+	}{
+		{
+			description: "Successful Create",
+			call: func() error { return fineDAO.Create(context.Background(), newFine()) },
+			expectedOperation: "FineCreate",
+			expectedErrorKind: "",
+		},
+		{
+			description: "Create of an existing fine",
+			call: func() error { return fineDAO.Create(context.Background(), newFine()) },
+			expectedOperation: "FineCreate",
+			expectedErrorKind: "already_exists",
+		},
+		{
+			description: "Read of a missing fine is not an error",
+			call: func() error { _, err := fineDAO.Read(context.Background(), "f2"); return err },
+			expectedOperation: "FineRead",
+			expectedErrorKind: "",
+		},
+		{
+			description: "ReadByCustomer with a canceled context",
+			call: func() error { _, err := fineDAO.ReadByCustomer(canceledContext, "01"); return err },
+			expectedOperation: "FineReadByCustomer",
+			expectedErrorKind: "canceled",
+		},
+		{
+			description: "UpdateAtomically rejected by modify is not a DAO error",
+			call: func() error {
+				_, err := fineDAO.UpdateAtomically(context.Background(), "f1", func(currentFine *models.Fine) (*models.Fine, error) {
+					return nil, rejectedErr
+				})
+				return err
+			},
+			expectedOperation: "FineUpdateAtomically",
+			expectedErrorKind: "",
+		},
+		{
+			description: "UpdateAtomically of a missing fine",
+			call: func() error {
+				_, err := fineDAO.UpdateAtomically(context.Background(), "f2", func(currentFine *models.Fine) (*models.Fine, error) {
+					return currentFine, nil
+				})
+				return err
+			},
+			expectedOperation: "FineUpdateAtomically",
+			expectedErrorKind: "not_found",
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		errorsBefore := testutil.CollectAndCount(m.daoOperationErrors)
+
+		currentTestCase.call()
+
+		if currentTestCase.expectedErrorKind == "" {
+			assert.Equal(t, errorsBefore, testutil.CollectAndCount(m.daoOperationErrors))
+		} else {
+			assert.Equal(t, 1.0, testutil.ToFloat64(m.daoOperationErrors.WithLabelValues("inmemory", currentTestCase.expectedOperation, currentTestCase.expectedErrorKind)))
+		}
+	}
+
+	expectedCounts := map[string]uint64{"FineCreate": 2, "FineRead": 1, "FineReadByCustomer": 1, "FineUpdateAtomically": 2}
+	for operation, expectedCount := range expectedCounts {
+		assert.Equal(t, expectedCount, sampleCount(t, m.daoOperationDuration, "inmemory", operation), operation)
+	}
+}
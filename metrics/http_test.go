@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"fmt"
+)
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := NewWithRegistry(prometheus.NewRegistry(), prometheus.NewRegistry())
+
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/books/:isbn", func(c *gin.Context) {
+		if c.Param("isbn") == "missing" {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"ERROR": "Book not found."})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{})
+	})
+
+	tests := []struct{
+		description string
+		method string
+		path string
+	}{
+		{description: "Found book", method: "GET", path: "/books/00001"},
+		{description: "Another found book under the same route", method: "GET", path: "/books/00002"},
+		{description: "Missing book", method: "GET", path: "/books/missing"},
+		{description: "Unknown path", method: "GET", path: "/nowhere/00001"},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		req, err := http.NewRequest(currentTestCase.method, currentTestCase.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+	}
+
+	// Requests are labeled by route rather than path, so both found books share a series
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/books/:isbn", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/books/:isbn", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "unmatched", "404")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.httpRequests))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.httpRequestDuration))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := NewWithRegistry(registry, registry)
+
+	m.RecordStateTransition("available", "checked-out", "success")
+	m.RecordStateTransition("available", "checked-out", "success")
+	m.RecordStateTransition("checked-out", "on-hold", "conflict")
+
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `library_book_state_transitions_total{from="available",outcome="success",to="checked-out"} 2`)
+	assert.Contains(t, w.Body.String(), `library_book_state_transitions_total{from="checked-out",outcome="conflict",to="on-hold"} 1`)
+}
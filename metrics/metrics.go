@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric exported by the API
+const namespace = "library"
+
+// Metrics holds the Prometheus collectors for HTTP requests, DAO calls and book state transitions
+type Metrics struct {
+	registerer prometheus.Registerer
+	gatherer prometheus.Gatherer
+
+	httpRequests *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	daoOperationDuration *prometheus.HistogramVec
+	daoOperationErrors *prometheus.CounterVec
+	bookStateTransitions *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them with a new registry, along with the standard Go runtime and process collectors
+func New() *Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	return NewWithRegistry(registry, registry)
+}
+
+// NewWithRegistry creates the collectors and registers them with registerer. Handler serves the metrics collected by gatherer
+func NewWithRegistry(registerer prometheus.Registerer, gatherer prometheus.Gatherer) *Metrics {
+	m := &Metrics{
+		registerer: registerer,
+		gatherer: gatherer,
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests served, by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name: "http_request_duration_seconds",
+			Help: "Time taken to serve HTTP requests, by method, route and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		daoOperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name: "dao_operation_duration_seconds",
+			Help: "Time taken by BookDAO and FineDAO calls, by backend and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"backend", "operation"}),
+		daoOperationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name: "dao_operation_errors_total",
+			Help: "Number of BookDAO and FineDAO calls that failed, by backend, method and kind of error.",
+		}, []string{"backend", "operation", "error"}),
+		bookStateTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name: "book_state_transitions_total",
+			Help: "Number of book state transitions requested through UpdateBook, by current state, requested state and outcome.",
+		}, []string{"from", "to", "outcome"}),
+	}
+
+	registerer.MustRegister(m.httpRequests, m.httpRequestDuration, m.daoOperationDuration, m.daoOperationErrors, m.bookStateTransitions)
+
+	return m
+}
+
+// Handler serves the collected metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// RecordStateTransition counts a transition requested from one book state to another, and its outcome
+func (m *Metrics) RecordStateTransition(from string, to string, outcome string) {
+	m.bookStateTransitions.WithLabelValues(from, to, outcome).Inc()
+}
+
+// RegisterDBStats exports the statistics of a database connection pool, such as open, in-use and idle connections and the time spent waiting for one, labeled with dbName
+func (m *Metrics) RegisterDBStats(db *sql.DB, dbName string) {
+	m.registerer.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
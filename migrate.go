@@ -0,0 +1,83 @@
+package main
+
+import (
+	"example/library_project/dao/mysqldao"
+
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// migrateUsage describes the arguments accepted by runMigrateCommand
+const migrateUsage = "usage: library_project migrate up | down [steps] | status"
+
+// runMigrateCommand runs "migrate up", "migrate down [steps]" or "migrate status" against the MySQL database, where args excludes "migrate"
+func runMigrateCommand(daoFactory *mysqldao.MySQLDAOFactory, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	// The command manages the schema itself, so Open must not migrate or verify it first
+	daoFactory.MigrationMode = mysqldao.MigrationModeSkip
+
+	if err := daoFactory.Open(); err != nil {
+		return fmt.Errorf("failed to open database connection: %w", err)
+	}
+	defer daoFactory.Close()
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+
+		appliedCount, err := daoFactory.MigrateUp()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", appliedCount)
+
+	case "down":
+		if len(args) > 2 {
+			return errors.New(migrateUsage)
+		}
+
+		steps := 1
+		if len(args) == 2 {
+			parsedSteps, err := strconv.Atoi(args[1])
+			if err != nil || parsedSteps < 1 {
+				return fmt.Errorf("steps must be a positive integer, got %q", args[1])
+			}
+			steps = parsedSteps
+		}
+
+		revertedCount, err := daoFactory.MigrateDown(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", revertedCount)
+
+	case "status":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+
+		statuses, err := daoFactory.MigrationStatus()
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -24,6 +24,34 @@ type Book struct{
 
 	// TimeUpdated is the time the book was last updated. It is immutable by the client
 	TimeUpdated  		*time.Time	`json:"timeupdated"`
+
+	// TimeDue is the time a checked-out book is due back, set from the loan period when the book is checked out. It is immutable by the client
+	TimeDue			*time.Time	`json:"timedue"`
+
+	// RenewalCount is the number of times the current loan was renewed. It is zero when the book is checked out, null while it is not, and immutable by the client
+	RenewalCount		*int		`json:"renewalcount"`
+
+	// HoldQueue lists the customers waiting for the book after the one who has it on-hold or checked-out, in the order they joined.
+	// It is not part of the JSON body, so that customers only learn their own position in the queue, through the holds endpoints
+	HoldQueue		[]string	`json:"-"`
+
+	// HoldExpiry is the time an on-hold book must be checked out by, set from the pickup window when the book is placed on-hold. Once it passes, the hold is released. It is immutable by the client
+	HoldExpiry		*time.Time	`json:"holdexpiry"`
+
+	// Overdue is true when the book is checked-out past its due date. It is computed by the handlers whenever a book is sent to the client, and never stored
+	Overdue			bool		`json:"overdue"`
+
+	// Version is incremented by the DAO every time the stored book changes, starting from 1 when it is created.
+	// It is not part of the JSON body. Instead, it is exposed to the client through the ETag header
+	Version			int64		`json:"-"`
+}
+
+// BookPage is one page of books, returned when the client paginates with the limit or cursor query parameters
+type BookPage struct{
+	Books			[]*Book		`json:"books"`
+
+	// Next is the cursor of the next page, or null on the last page
+	Next			*string		`json:"next"`
 }
 
 // Validate ensures that all fields provided in the request are within range for both creating a new book and updating an existing book
@@ -58,4 +86,45 @@ func (incomingBook *Book) Validate() (error) {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// Copy returns a deep copy of the book, so that the copy can be modified without affecting the original
+func (b *Book) Copy() *Book {
+	if b == nil {
+		return nil
+	}
+
+	return &Book{
+		ISBN: copyPtr(b.ISBN),
+		State: copyPtr(b.State),
+		OnHoldCustomerID: copyPtr(b.OnHoldCustomerID),
+		CheckedOutCustomerID: copyPtr(b.CheckedOutCustomerID),
+		TimeCreated: copyPtr(b.TimeCreated),
+		TimeUpdated: copyPtr(b.TimeUpdated),
+		TimeDue: copyPtr(b.TimeDue),
+		RenewalCount: copyPtr(b.RenewalCount),
+		HoldQueue: copySlice(b.HoldQueue),
+		HoldExpiry: copyPtr(b.HoldExpiry),
+		Overdue: b.Overdue,
+		Version: b.Version,
+	}
+}
+
+// copyPtr returns a pointer to a copy of the value p points to, or nil if p is nil
+func copyPtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	v := *p
+	return &v
+}
+
+// copySlice returns a copy of s, or nil if s is nil
+func copySlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+
+	return append(make([]T, 0, len(s)), s...)
+}
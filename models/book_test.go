@@ -50,4 +50,48 @@ func TestBook_Validate(t *testing.T){
 			assert.EqualError(t, actual, currentTestCase.expectedErrorMessage)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestBook_Copy(t *testing.T){
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	original := &Book{
+		ISBN: utils.ToPtr("00000"), 
+		State: utils.ToPtr("on-hold"), 
+		OnHoldCustomerID: utils.ToPtr("01"), 
+		CheckedOutCustomerID: nil, 
+		TimeCreated: utils.ToPtr(arbitraryTime), 
+		TimeUpdated: utils.ToPtr(arbitraryTime),
+		TimeDue: utils.ToPtr(arbitraryTime.Add(14 * 24 * time.Hour)),
+		RenewalCount: utils.ToPtr(1),
+		HoldQueue: []string{"02", "03"},
+		HoldExpiry: utils.ToPtr(arbitraryTime.Add(72 * time.Hour)),
+		Overdue: true,
+		Version: 3,
+	}
+
+	copied := original.Copy()
+	assert.Equal(t, original, copied)
+
+	// Modifying the copy through its pointers must leave the original untouched
+	*copied.State = "checked-out"
+	copied.OnHoldCustomerID = nil
+	copied.CheckedOutCustomerID = utils.ToPtr("01")
+	*copied.TimeUpdated = arbitraryTime.Add(time.Hour)
+	*copied.TimeDue = arbitraryTime
+	*copied.RenewalCount = 2
+	copied.HoldQueue[0] = "04"
+	*copied.HoldExpiry = arbitraryTime
+
+	assert.Equal(t, "on-hold", *original.State)
+	assert.Equal(t, "01", *original.OnHoldCustomerID)
+	assert.Nil(t, original.CheckedOutCustomerID)
+	assert.Equal(t, arbitraryTime, *original.TimeUpdated)
+	assert.Equal(t, arbitraryTime.Add(14 * 24 * time.Hour), *original.TimeDue)
+	assert.Equal(t, 1, *original.RenewalCount)
+	assert.Equal(t, []string{"02", "03"}, original.HoldQueue)
+	assert.Equal(t, arbitraryTime.Add(72 * time.Hour), *original.HoldExpiry)
+
+	var nilBook *Book
+	assert.Nil(t, nilBook.Copy())
+}
@@ -2,4 +2,7 @@ package models
 
 type ErrorResponse struct {
 	Message *string `json:"ERROR"`
-}
\ No newline at end of file
+
+	// RequestID identifies the request in the server's logs, so that clients can quote it when reporting a problem
+	RequestID *string `json:"REQUESTID,omitempty"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+	"errors"
+)
+
+// Fine represents a fee charged to a customer for returning a book after its due date
+type Fine struct{
+	// ID is a unique identifier for the fine, assigned when the fine is charged. It is immutable by the client
+	ID			*string		`json:"id"`
+
+	// CustomerID identifies the customer who returned the book late. It is immutable by the client
+	CustomerID		*string		`json:"customerid"`
+
+	// ISBN identifies the book that was returned late. It is immutable by the client
+	ISBN			*string		`json:"isbn"`
+
+	// DaysOverdue is the number of days the book was returned after its due date, counting a partial day as a whole one. It is immutable by the client
+	DaysOverdue		*int		`json:"daysoverdue"`
+
+	// Amount is the fee charged, in the smallest unit of the currency such as cents. It is immutable by the client
+	Amount			*int		`json:"amount"`
+
+	// State is the current state of the fine. It can be "outstanding", "paid", or "waived". This field must be provided in any request to pay or waive a fine
+	State			*string		`json:"state"`
+
+	// TimeCreated is the time the fine was charged, which is the time the book was returned. It is immutable by the client
+	TimeCreated		*time.Time	`json:"timecreated"`
+
+	// TimeUpdated is the time the fine was paid or waived. It is immutable by the client
+	TimeUpdated		*time.Time	`json:"timeupdated"`
+}
+
+// FineLedger lists the fines of a customer, oldest first, along with the total of the fines they have yet to pay
+type FineLedger struct{
+	CustomerID		*string		`json:"customerid"`
+	Balance			*int		`json:"balance"`
+	Fines			[]*Fine		`json:"fines"`
+}
+
+// Validate ensures that all fields provided in a request to pay or waive a fine are within range
+func (incomingFine *Fine) Validate() (error) {
+	if incomingFine.ID != nil {
+		if *incomingFine.ID == "" {
+			return errors.New("Fine ID cannot be the empty string.")
+		}
+	}
+
+	if incomingFine.CustomerID != nil {
+		if *incomingFine.CustomerID == "" {
+			return errors.New("Customer ID cannot be the empty string.")
+		}
+	}
+
+	if incomingFine.ISBN != nil {
+		if *incomingFine.ISBN == "" {
+			return errors.New("ISBN cannot be the empty string.")
+		}
+	}
+
+	if incomingFine.State != nil {
+		if ((*incomingFine.State != "outstanding") && (*incomingFine.State != "paid") && (*incomingFine.State != "waived")) {
+			return errors.New("Invalid state provided. State must be equal to one of: \"outstanding\", \"paid\", or \"waived\".")
+		}
+	}
+
+	return nil
+}
+
+// Copy returns a deep copy of the fine, so that the copy can be modified without affecting the original
+func (f *Fine) Copy() *Fine {
+	if f == nil {
+		return nil
+	}
+
+	return &Fine{
+		ID: copyPtr(f.ID),
+		CustomerID: copyPtr(f.CustomerID),
+		ISBN: copyPtr(f.ISBN),
+		DaysOverdue: copyPtr(f.DaysOverdue),
+		Amount: copyPtr(f.Amount),
+		State: copyPtr(f.State),
+		TimeCreated: copyPtr(f.TimeCreated),
+		TimeUpdated: copyPtr(f.TimeUpdated),
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+	"time"
+	"example/library_project/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFine_Validate(t *testing.T){
+	tests := []struct{
+		description string
+		fine *Fine
+		expectedErrorMessage string
+	}{
+		{
+			description: "Only the state is provided",
+			fine: &Fine{
+				State: utils.ToPtr("paid"),
+			},
+			expectedErrorMessage: "",
+		},
+		{
+			description: "Customer ID is the empty string",
+			fine: &Fine{
+				CustomerID: utils.ToPtr(""),
+				State: utils.ToPtr("paid"),
+			},
+			expectedErrorMessage: "Customer ID cannot be the empty string.",
+		},
+		{
+			description: "Invalid state",
+			fine: &Fine{
+				State: utils.ToPtr("forgiven"),
+			},
+			expectedErrorMessage: "Invalid state provided. State must be equal to one of: \"outstanding\", \"paid\", or \"waived\".",
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		t.Log(currentTestCase.description)
+		actual := currentTestCase.fine.Validate()
+
+		if (currentTestCase.expectedErrorMessage == "") {
+			assert.Nil(t, actual)
+		} else {
+			assert.NotNil(t, actual)
+			assert.EqualError(t, actual, currentTestCase.expectedErrorMessage)
+		}
+	}
+}
+
+func TestFine_Copy(t *testing.T){
+	arbitraryTime := time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)
+
+	original := &Fine{
+		ID: utils.ToPtr("f1"),
+		CustomerID: utils.ToPtr("01"),
+		ISBN: utils.ToPtr("00000"),
+		DaysOverdue: utils.ToPtr(3),
+		Amount: utils.ToPtr(75),
+		State: utils.ToPtr("outstanding"),
+		TimeCreated: utils.ToPtr(arbitraryTime),
+		TimeUpdated: nil,
+	}
+
+	copied := original.Copy()
+	assert.Equal(t, original, copied)
+
+	// Modifying the copy through its pointers must leave the original untouched
+	*copied.State = "paid"
+	*copied.Amount = 0
+	copied.TimeUpdated = utils.ToPtr(arbitraryTime.Add(time.Hour))
+
+	assert.Equal(t, "outstanding", *original.State)
+	assert.Equal(t, 75, *original.Amount)
+	assert.Nil(t, original.TimeUpdated)
+
+	var nilFine *Fine
+	assert.Nil(t, nilFine.Copy())
+}
@@ -0,0 +1,14 @@
+package models
+
+// HealthResponse is the body of the liveness and readiness endpoints. Status is "up" only if every dependency is up
+type HealthResponse struct {
+	Status string `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// DependencyStatus reports whether a dependency such as the database could be reached, and how long the check took
+type DependencyStatus struct {
+	Status string `json:"status"`
+	Duration string `json:"duration"`
+	Error *string `json:"error,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"errors"
+)
+
+// Hold represents a customer waiting in the hold queue of a book, behind the customer who has it on-hold or checked-out
+type Hold struct{
+	// CustomerID identifies the waiting customer. This field must be provided in any request to join the hold queue of a book
+	CustomerID	*string	`json:"customerid"`
+
+	// Position is the place of the customer in the hold queue, starting from 1 for the customer who gets the book next. It is immutable by the client
+	Position	*int	`json:"position"`
+}
+
+// Validate ensures that all fields provided in a request to join a hold queue are within range
+func (incomingHold *Hold) Validate() (error) {
+	if incomingHold.CustomerID != nil {
+		if *incomingHold.CustomerID == "" {
+			return errors.New("Customer ID cannot be the empty string.")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package ratelimit throttles each client of the API with its own token bucket
+package ratelimit
+
+import (
+	"example/library_project/auth"
+	"example/library_project/logging"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// sweepInterval is how often the buckets of clients that went quiet are dropped, so that the number of buckets stays bounded
+const sweepInterval = time.Minute
+
+// bucket is the token bucket of one client
+type bucket struct {
+	limiter *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter gives each client a token bucket of the same rate and size. Each request takes a token, and is rejected when the bucket is empty
+type Limiter struct {
+	limit rate.Limit
+	burst int
+
+	mutex sync.Mutex
+	buckets map[string]*bucket
+	lastSweep time.Time
+
+	// DateTimeInterface is the clock against which the buckets refill
+	DateTimeInterface utils.DateTimeProvider
+}
+
+// NewLimiter returns a limiter refilling each client's bucket at requestsPerSecond, up to burst tokens
+func NewLimiter(requestsPerSecond float64, burst int) *Limiter {
+	return &Limiter{
+		limit: rate.Limit(requestsPerSecond),
+		burst: burst,
+		buckets: make(map[string]*bucket),
+		lastSweep: time.Time{},
+		DateTimeInterface: &utils.ProductionDateTimeProvider{},
+	}
+}
+
+// Allow takes a token from the bucket of key. If the bucket is empty, it returns false and how long until a token is available
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := *l.DateTimeInterface.GetCurrentTime()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	reservation := l.bucketOf(key, now).limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// The request is rejected, so it must not keep the token it reserved
+		reservation.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// wait returns how long until the bucket of key has a token, without taking it
+func (l *Limiter) wait(key string) time.Duration {
+	now := *l.DateTimeInterface.GetCurrentTime()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	reservation := l.bucketOf(key, now).limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+
+	return delay
+}
+
+// charge takes a token from the bucket of key even when it is empty, in which case the bucket owes it and takes longer to refill
+func (l *Limiter) charge(key string) {
+	now := *l.DateTimeInterface.GetCurrentTime()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.bucketOf(key, now).limiter.ReserveN(now, 1)
+}
+
+// bucketOf returns the bucket of key, creating a full one if the client has none. The mutex must be held
+func (l *Limiter) bucketOf(key string, now time.Time) *bucket {
+	l.sweep(now)
+
+	currentBucket, ok := l.buckets[key]
+	if !ok {
+		currentBucket = &bucket{limiter: rate.NewLimiter(l.limit, l.burst), lastSeen: now}
+		l.buckets[key] = currentBucket
+	}
+	currentBucket.lastSeen = now
+
+	return currentBucket
+}
+
+// sweep drops the buckets that have been idle long enough to be full again, which is the same as having no bucket at all
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	refillTime := time.Duration(float64(l.burst) / float64(l.limit) * float64(time.Second))
+	for key, currentBucket := range l.buckets {
+		if now.Sub(currentBucket.lastSeen) > refillTime {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ClientKey identifies the client of a request: the authenticated principal if there is one, or else the client IP.
+// The IP is taken from X-Forwarded-For only when the request came through one of the router's trusted proxies
+func ClientKey(c *gin.Context) string {
+	if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok {
+		return "principal:" + principal.Subject
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+// Middleware rejects the requests of clients whose bucket is empty with 429, and a Retry-After header giving the number of seconds until they may retry.
+// It must run after the authentication middleware, so that authenticated clients are told apart by their identity rather than their IP
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := ClientKey(c)
+
+		allowed, delay := l.Allow(key)
+		if allowed {
+			c.Next()
+			return
+		}
+
+		reject(c, key, delay)
+	}
+}
+
+// AuthFailureMiddleware throttles failed authentications by client IP, so that API keys and tokens cannot be guessed at the rate of the other buckets.
+// It must run before the authentication middleware: the requests of an IP whose bucket is empty are rejected with 429 before their credentials are checked,
+// and only the requests answered with 401 take a token
+func (l *Limiter) AuthFailureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+
+		if delay := l.wait(key); delay > 0 {
+			reject(c, key, delay)
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() == http.StatusUnauthorized {
+			l.charge(key)
+		}
+	}
+}
+
+// reject answers the request of the client key with 429, and a Retry-After header giving the number of seconds until delay has passed
+func reject(c *gin.Context, key string, delay time.Duration) {
+	retryAfter := int(math.Ceil(delay.Seconds()))
+	slog.InfoContext(c.Request.Context(), "rate limit exceeded", slog.String("client", key), slog.Int("retry_after", retryAfter))
+
+	message := fmt.Sprintf("Too many requests, retry in %d seconds.", retryAfter)
+	errorResponse := models.ErrorResponse{
+		Message: &message,
+		RequestID: nil,
+	}
+	if requestID := logging.RequestID(c.Request.Context()); requestID != "" {
+		errorResponse.RequestID = &requestID
+	}
+
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse)
+}
@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"example/library_project/auth"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	clock := &utils.TestingDateTimeProvider{ArbitraryTime: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+	// One token per second, and up to two at once
+	limiter := NewLimiter(1, 2)
+	limiter.DateTimeInterface = clock
+
+	tests := []struct{
+		description string
+		advance time.Duration
+		key string
+		expectAllowed bool
+		expectedDelay time.Duration
+	}{
+		{description: "First request uses the burst", advance: 0, key: "a", expectAllowed: true, expectedDelay: 0},
+		{description: "Second request uses the burst", advance: 0, key: "a", expectAllowed: true, expectedDelay: 0},
+		{description: "Third request at once is rejected", advance: 0, key: "a", expectAllowed: false, expectedDelay: time.Second},
+		{description: "Rejected requests do not use tokens", advance: 500 * time.Millisecond, key: "a", expectAllowed: false, expectedDelay: 500 * time.Millisecond},
+		{description: "Another client has its own bucket", advance: 0, key: "b", expectAllowed: true, expectedDelay: 0},
+		{description: "Request once a token was refilled", advance: 500 * time.Millisecond, key: "a", expectAllowed: true, expectedDelay: 0},
+		{description: "Bucket is empty again", advance: 0, key: "a", expectAllowed: false, expectedDelay: time.Second},
+		{description: "Idle client gets a full bucket", advance: time.Hour, key: "a", expectAllowed: true, expectedDelay: 0},
+		{description: "Full bucket allows a burst", advance: 0, key: "a", expectAllowed: true, expectedDelay: 0},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		clock.ArbitraryTime = clock.ArbitraryTime.Add(currentTestCase.advance)
+
+		allowed, delay := limiter.Allow(currentTestCase.key)
+
+		assert.Equal(t, currentTestCase.expectAllowed, allowed)
+		assert.Equal(t, currentTestCase.expectedDelay, delay)
+	}
+
+	// The buckets of idle clients were dropped by the sweep that ran after an hour
+	assert.Equal(t, 1, len(limiter.buckets))
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	clock := &utils.TestingDateTimeProvider{ArbitraryTime: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+	// One token every two seconds, and a single one at once
+	limiter := NewLimiter(0.5, 1)
+	limiter.DateTimeInterface = clock
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatalf("failed to clear the trusted proxies: %v", err)
+	}
+	r.Use(func(c *gin.Context) {
+		// Stands in for the authentication middleware
+		if subject := c.GetHeader("X-Test-Subject"); subject != "" {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), &auth.Principal{Subject: subject, Method: auth.MethodAPIKey, Role: auth.RoleAdmin, CustomerID: ""}))
+		}
+		c.Next()
+	}, limiter.Middleware())
+	r.GET("/books", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct{
+		description string
+		remoteAddr string
+		forwardedFor string
+		subject string
+		expectedStatusCode int
+		expectedRetryAfter string
+	}{
+		{description: "First request of a client", remoteAddr: "192.0.2.1:1234", forwardedFor: "", subject: "", expectedStatusCode: 200, expectedRetryAfter: ""},
+		{description: "Second request of the client from another port", remoteAddr: "192.0.2.1:5678", forwardedFor: "", subject: "", expectedStatusCode: 429, expectedRetryAfter: "2"},
+		{description: "Spoofed X-Forwarded-For is ignored", remoteAddr: "192.0.2.1:1234", forwardedFor: "198.51.100.7", subject: "", expectedStatusCode: 429, expectedRetryAfter: "2"},
+		{description: "Another IP", remoteAddr: "192.0.2.2:1234", forwardedFor: "", subject: "", expectedStatusCode: 200, expectedRetryAfter: ""},
+		{description: "Authenticated client from a throttled IP", remoteAddr: "192.0.2.1:1234", forwardedFor: "", subject: "catalogue-sync", expectedStatusCode: 200, expectedRetryAfter: ""},
+		{description: "Authenticated client from another IP", remoteAddr: "192.0.2.3:1234", forwardedFor: "", subject: "catalogue-sync", expectedStatusCode: 429, expectedRetryAfter: "2"},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/books", nil)
+		req.RemoteAddr = currentTestCase.remoteAddr
+		if currentTestCase.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", currentTestCase.forwardedFor)
+		}
+		if currentTestCase.subject != "" {
+			req.Header.Set("X-Test-Subject", currentTestCase.subject)
+		}
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+		assert.Equal(t, currentTestCase.expectedRetryAfter, w.Header().Get("Retry-After"))
+
+		if currentTestCase.expectedStatusCode == http.StatusTooManyRequests {
+			var errorResponse models.ErrorResponse
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &errorResponse))
+			assert.NotNil(t, errorResponse.Message)
+		}
+	}
+}
+
+func TestLimiter_AuthFailureMiddleware(t *testing.T) {
+	clock := &utils.TestingDateTimeProvider{ArbitraryTime: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+	// One failure every two seconds, and two at once
+	limiter := NewLimiter(0.5, 2)
+	limiter.DateTimeInterface = clock
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatalf("failed to clear the trusted proxies: %v", err)
+	}
+	r.Use(limiter.AuthFailureMiddleware(), func(c *gin.Context) {
+		// Stands in for the authentication middleware
+		if c.GetHeader("X-Test-Key") != "valid" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	})
+	r.GET("/books", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct{
+		description string
+		advance time.Duration
+		remoteAddr string
+		key string
+		expectedStatusCode int
+		expectedRetryAfter string
+	}{
+		{description: "Authenticated requests take no token", advance: 0, remoteAddr: "192.0.2.1:1234", key: "valid", expectedStatusCode: 200, expectedRetryAfter: ""},
+		{description: "Authenticated requests take no token, again", advance: 0, remoteAddr: "192.0.2.1:1234", key: "valid", expectedStatusCode: 200, expectedRetryAfter: ""},
+		{description: "First failure uses the burst", advance: 0, remoteAddr: "192.0.2.1:1234", key: "guess-1", expectedStatusCode: 401, expectedRetryAfter: ""},
+		{description: "Second failure uses the burst", advance: 0, remoteAddr: "192.0.2.1:1234", key: "guess-2", expectedStatusCode: 401, expectedRetryAfter: ""},
+		{description: "Guesses are rejected before the credentials are checked", advance: 0, remoteAddr: "192.0.2.1:1234", key: "guess-3", expectedStatusCode: 429, expectedRetryAfter: "2"},
+		{description: "Valid credentials from the throttled IP are rejected too", advance: 0, remoteAddr: "192.0.2.1:1234", key: "valid", expectedStatusCode: 429, expectedRetryAfter: "2"},
+		{description: "Another IP has its own bucket", advance: 0, remoteAddr: "192.0.2.2:1234", key: "guess-4", expectedStatusCode: 401, expectedRetryAfter: ""},
+		{description: "Guess once a token was refilled", advance: 2 * time.Second, remoteAddr: "192.0.2.1:1234", key: "guess-5", expectedStatusCode: 401, expectedRetryAfter: ""},
+		{description: "Bucket is empty again", advance: 0, remoteAddr: "192.0.2.1:1234", key: "guess-6", expectedStatusCode: 429, expectedRetryAfter: "2"},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		clock.ArbitraryTime = clock.ArbitraryTime.Add(currentTestCase.advance)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/books", nil)
+		req.RemoteAddr = currentTestCase.remoteAddr
+		req.Header.Set("X-Test-Key", currentTestCase.key)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+		assert.Equal(t, currentTestCase.expectedRetryAfter, w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"example/library_project/config"
+
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// trackedHandler counts the requests being handled, because the handlers of the connections dropped by http.Server.Close keep running until they return
+type trackedHandler struct {
+	handler http.Handler
+	mu sync.Mutex
+	closed bool
+	inFlight sync.WaitGroup
+}
+
+func (h *trackedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	h.inFlight.Add(1)
+	h.mu.Unlock()
+
+	defer h.inFlight.Done()
+	h.handler.ServeHTTP(w, r)
+}
+
+// wait refuses any further request and returns once every request being handled has finished
+func (h *trackedHandler) wait() {
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+
+	h.inFlight.Wait()
+}
+
+// serve runs server on listener until ctx is done, then stops accepting connections and waits up to shutdownTimeout for in-flight requests to finish.
+// Connections still busy after shutdownTimeout are dropped, which cancels the context of their requests, but serve still waits for their handlers to return.
+// It returns once no handler is running, so that the caller can safely release what the handlers use, such as the database connection
+func serve(ctx context.Context, server *http.Server, listener net.Listener, shutdownTimeout time.Duration) error {
+	handler := &trackedHandler{handler: server.Handler}
+	if handler.handler == nil {
+		handler.handler = http.DefaultServeMux
+	}
+	server.Handler = handler
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server stopped unexpectedly: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for in-flight requests to finish", slog.Duration("shutdown_timeout", shutdownTimeout))
+
+	shutdownContext, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownContext); err != nil {
+		// Drop the connections that are still busy, and wait for their handlers to give up rather than leaving them to a closed database
+		server.Close()
+		slog.Warn("dropped the connections of the requests still in flight, waiting for their handlers to return")
+		handler.wait()
+		return fmt.Errorf("failed to finish in-flight requests within %s: %w", shutdownTimeout, err)
+	}
+	handler.wait()
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server stopped unexpectedly: %w", err)
+	}
+
+	return nil
+}
+
+// newListener listens on the configured address, wrapped in TLS when a certificate and key are configured
+func newListener(serverConfig config.ServerConfig) (net.Listener, error) {
+	listener, err := net.Listen("tcp", serverConfig.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	if !serverConfig.TLSEnabled() {
+		return listener, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(serverConfig.TLSCertFile, serverConfig.TLSKeyFile)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion: tls.VersionTLS12,
+	}
+
+	return tls.NewListener(listener, tlsConfig), nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"context"
+	"fmt"
+)
+
+// startSlowServer serves a handler that takes requestDuration to respond, even once its request is canceled, and returns its URL,
+// the channel receiving the result of serve, and the number of handlers running
+func startSlowServer(t *testing.T, ctx context.Context, requestDuration time.Duration, shutdownTimeout time.Duration) (string, chan error, *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runningHandlers := &atomic.Int32{}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			runningHandlers.Add(1)
+			defer runningHandlers.Add(-1)
+
+			time.Sleep(requestDuration)
+			io.WriteString(w, "done")
+		}),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, server, listener, shutdownTimeout)
+	}()
+
+	return fmt.Sprintf("http://%s/", listener.Addr()), serveErr, runningHandlers
+}
+
+func TestServe_FinishesInFlightRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	url, serveErr, _ := startSlowServer(t, ctx, 200*time.Millisecond, 5*time.Second)
+
+	responseChannel := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responseChannel <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		responseChannel <- string(body)
+	}()
+
+	// Shut down while the request is in flight
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	assert.Nil(t, <-serveErr)
+
+	select {
+	case body := <-responseChannel:
+		assert.Equal(t, "done", body)
+	case <-time.After(time.Second):
+		t.Fatal("the in-flight request did not complete")
+	}
+
+	// New connections are refused once serve has returned
+	_, err := http.Get(url)
+	assert.NotNil(t, err)
+}
+
+func TestServe_ShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	url, serveErr, runningHandlers := startSlowServer(t, ctx, 500*time.Millisecond, 100*time.Millisecond)
+
+	go http.Get(url)
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-serveErr:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// The handler of the dropped connection has returned by then, so it cannot use what the caller releases next
+		assert.Equal(t, int32(0), runningHandlers.Load())
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not give up after the shutdown timeout")
+	}
+}
@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// isbnKey is the span attribute holding the ISBN of the book a DAO call is about
+const isbnKey = attribute.Key("library.isbn")
+
+// backendKey is the span attribute holding the storage solution behind the DAO
+const backendKey = attribute.Key("library.dao.backend")
+
+// tracedBookDAO records a span for every call to the wrapped BookDAO
+type tracedBookDAO struct {
+	bookDAO dao.BookDAO
+	backend string
+	tracer trace.Tracer
+}
+
+// InstrumentBookDAO wraps bookDAO so that each of its calls is recorded as a span named after the method, such as "BookDAO.UpdateAtomically"
+func InstrumentBookDAO(bookDAO dao.BookDAO, tracerProvider trace.TracerProvider, backend string) dao.BookDAO {
+	return &tracedBookDAO{
+		bookDAO: bookDAO,
+		backend: backend,
+		tracer: tracerProvider.Tracer(instrumentationName),
+	}
+}
+
+// start starts the span of a call to operation
+func (d *tracedBookDAO) start(ctx context.Context, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	attributes = append(attributes, backendKey.String(d.backend))
+
+	return d.tracer.Start(ctx, "BookDAO." + operation, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attributes...))
+}
+
+// end records err on span, if any, and ends it. A missing book or fine is an expected outcome rather than a failure, so it does not mark the span as failed
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, dao.ErrBookNotFound) && !errors.Is(err, dao.ErrFineNotFound) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+
+	span.End()
+}
+
+// isbnOf returns the ISBN attribute of book, which may not have one yet
+func isbnOf(book *models.Book) attribute.KeyValue {
+	if book == nil || book.ISBN == nil {
+		return isbnKey.String("")
+	}
+
+	return isbnKey.String(*book.ISBN)
+}
+
+func (d *tracedBookDAO) Create(ctx context.Context, newBook *models.Book) error {
+	ctx, span := d.start(ctx, "Create", isbnOf(newBook))
+	err := d.bookDAO.Create(ctx, newBook)
+	end(span, err)
+
+	return err
+}
+
+func (d *tracedBookDAO) Read(ctx context.Context, isbn string) (*models.Book, error) {
+	ctx, span := d.start(ctx, "Read", isbnKey.String(isbn))
+	book, err := d.bookDAO.Read(ctx, isbn)
+	span.SetAttributes(attribute.Bool("library.book.found", book != nil))
+	end(span, err)
+
+	return book, err
+}
+
+func (d *tracedBookDAO) ReadAll(ctx context.Context) ([]*models.Book, error) {
+	ctx, span := d.start(ctx, "ReadAll")
+	books, err := d.bookDAO.ReadAll(ctx)
+	span.SetAttributes(attribute.Int("library.books.count", len(books)))
+	end(span, err)
+
+	return books, err
+}
+
+func (d *tracedBookDAO) Query(ctx context.Context, query dao.BookQuery) ([]*models.Book, error) {
+	ctx, span := d.start(ctx, "Query", attribute.Int("library.query.limit", query.Limit))
+	books, err := d.bookDAO.Query(ctx, query)
+	span.SetAttributes(attribute.Int("library.books.count", len(books)))
+	end(span, err)
+
+	return books, err
+}
+
+func (d *tracedBookDAO) Update(ctx context.Context, book *models.Book) error {
+	ctx, span := d.start(ctx, "Update", isbnOf(book))
+	err := d.bookDAO.Update(ctx, book)
+	end(span, err)
+
+	return err
+}
+
+func (d *tracedBookDAO) Delete(ctx context.Context, book *models.Book) error {
+	ctx, span := d.start(ctx, "Delete", isbnOf(book))
+	err := d.bookDAO.Delete(ctx, book)
+	end(span, err)
+
+	return err
+}
+
+func (d *tracedBookDAO) UpdateAtomically(ctx context.Context, isbn string, modify func(currentBook *models.Book) (*models.Book, error)) (*models.Book, error) {
+	ctx, span := d.start(ctx, "UpdateAtomically", isbnKey.String(isbn))
+
+	// modify runs in between the read and the write, and is where the state transition happens, so it gets a span of its own
+	var modifyErr error
+	book, err := d.bookDAO.UpdateAtomically(ctx, isbn, func(currentBook *models.Book) (*models.Book, error) {
+		_, modifySpan := d.tracer.Start(ctx, "BookDAO.UpdateAtomically.modify", trace.WithAttributes(isbnKey.String(isbn)))
+		defer modifySpan.End()
+
+		modifiedBook, err := modify(currentBook)
+		modifyErr = err
+		if err != nil {
+			modifySpan.RecordError(err)
+		}
+		return modifiedBook, err
+	})
+
+	// Errors returned by modify are the caller's own decisions, such as a rejected state transition, so they do not mark the span as failed
+	if err != nil && modifyErr != nil && errors.Is(err, modifyErr) {
+		span.RecordError(err)
+		span.End()
+	} else {
+		end(span, err)
+	}
+
+	return book, err
+}
+
+func (d *tracedBookDAO) UpdateAtomicallyWithFines(ctx context.Context, isbn string, modify func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error)) (*models.Book, error) {
+	ctx, span := d.start(ctx, "UpdateAtomicallyWithFines", isbnKey.String(isbn))
+
+	// As for UpdateAtomically, modify gets a span of its own, and its errors do not mark the span as failed
+	var modifyErr error
+	book, err := d.bookDAO.UpdateAtomicallyWithFines(ctx, isbn, func(currentBook *models.Book, fines dao.FineTx) (*models.Book, error) {
+		_, modifySpan := d.tracer.Start(ctx, "BookDAO.UpdateAtomicallyWithFines.modify", trace.WithAttributes(isbnKey.String(isbn)))
+		defer modifySpan.End()
+
+		modifiedBook, err := modify(currentBook, fines)
+		modifyErr = err
+		if err != nil {
+			modifySpan.RecordError(err)
+		}
+		return modifiedBook, err
+	})
+
+	if err != nil && modifyErr != nil && errors.Is(err, modifyErr) {
+		span.RecordError(err)
+		span.End()
+	} else {
+		end(span, err)
+	}
+
+	return book, err
+}
@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"example/library_project/dao"
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestInstrumentBookDAO(t *testing.T) {
+	tracerProvider, exporter := newTestTracerProvider()
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := InstrumentBookDAO(daoFactory.BookDAO(), tracerProvider, "inmemory")
+
+	newBook := func() *models.Book {
+		return &models.Book{
+			ISBN: utils.ToPtr("00001"),
+			State: utils.ToPtr("available"),
+			OnHoldCustomerID: nil,
+			CheckedOutCustomerID: nil,
+			TimeCreated: utils.ToPtr(time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)),
+			TimeUpdated: nil,
+		}
+	}
+
+	canceledContext, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct{
+		description string
+		call func() error
+		expectedSpanNames []string
+		expectedISBN interface{}
+		expectedStatus codes.Code
+		expectedEvents int
+	}{
+		{
+			description: "Successful Create",
+			call: func() error { return bookDAO.Create(context.Background(), newBook()) },
+			expectedSpanNames: []string{"BookDAO.Create"},
+			expectedISBN: "00001",
+			expectedStatus: codes.Unset,
+			expectedEvents: 0,
+		},
+		{
+			description: "Create of an existing book fails",
+			call: func() error { return bookDAO.Create(context.Background(), newBook()) },
+			expectedSpanNames: []string{"BookDAO.Create"},
+			expectedISBN: "00001",
+			expectedStatus: codes.Error,
+			expectedEvents: 1,
+		},
+		{
+			description: "Read",
+			call: func() error { _, err := bookDAO.Read(context.Background(), "00001"); return err },
+			expectedSpanNames: []string{"BookDAO.Read"},
+			expectedISBN: "00001",
+			expectedStatus: codes.Unset,
+			expectedEvents: 0,
+		},
+		{
+			description: "ReadAll with a canceled context fails",
+			call: func() error { _, err := bookDAO.ReadAll(canceledContext); return err },
+			expectedSpanNames: []string{"BookDAO.ReadAll"},
+			expectedISBN: nil,
+			expectedStatus: codes.Error,
+			expectedEvents: 1,
+		},
+		{
+			description: "Query",
+			call: func() error { _, err := bookDAO.Query(context.Background(), dao.BookQuery{}); return err },
+			expectedSpanNames: []string{"BookDAO.Query"},
+			expectedISBN: nil,
+			expectedStatus: codes.Unset,
+			expectedEvents: 0,
+		},
+		{
+			description: "UpdateAtomically rejected by modify does not fail the span",
+			call: func() error {
+				_, err := bookDAO.UpdateAtomically(context.Background(), "00001", func(currentBook *models.Book) (*models.Book, error) {
+					return nil, errors.New("rejected by the caller")
+				})
+				return err
+			},
+			expectedSpanNames: []string{"BookDAO.UpdateAtomically.modify", "BookDAO.UpdateAtomically"},
+			expectedISBN: "00001",
+			expectedStatus: codes.Unset,
+			expectedEvents: 1,
+		},
+		{
+			description: "UpdateAtomically of a missing book is not a failure",
+			call: func() error {
+				_, err := bookDAO.UpdateAtomically(context.Background(), "99999", func(currentBook *models.Book) (*models.Book, error) {
+					return currentBook, nil
+				})
+				return err
+			},
+			expectedSpanNames: []string{"BookDAO.UpdateAtomically"},
+			expectedISBN: "99999",
+			expectedStatus: codes.Unset,
+			expectedEvents: 1,
+		},
+		{
+			description: "Update",
+			call: func() error { return bookDAO.Update(context.Background(), newBook()) },
+			expectedSpanNames: []string{"BookDAO.Update"},
+			expectedISBN: "00001",
+			expectedStatus: codes.Unset,
+			expectedEvents: 0,
+		},
+		{
+			description: "Delete",
+			call: func() error { return bookDAO.Delete(context.Background(), newBook()) },
+			expectedSpanNames: []string{"BookDAO.Delete"},
+			expectedISBN: "00001",
+			expectedStatus: codes.Unset,
+			expectedEvents: 0,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		exporter.Reset()
+
+		currentTestCase.call()
+
+		spans := exporter.GetSpans()
+		spanNames := make([]string, 0, len(spans))
+		for _, span := range spans {
+			spanNames = append(spanNames, span.Name)
+		}
+		if !assert.Equal(t, currentTestCase.expectedSpanNames, spanNames) {
+			continue
+		}
+
+		// The span of the DAO call is the last one to end
+		daoSpan := spans[len(spans) - 1]
+		assert.Equal(t, currentTestCase.expectedISBN, attributeValue(daoSpan.Attributes, isbnKey))
+		assert.Equal(t, "inmemory", attributeValue(daoSpan.Attributes, backendKey))
+		assert.Equal(t, currentTestCase.expectedStatus, daoSpan.Status.Code)
+		assert.Len(t, daoSpan.Events, currentTestCase.expectedEvents)
+	}
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"example/library_project/dao"
+	"example/library_project/models"
+
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fineIDKey is the span attribute holding the ID of the fine a DAO call is about. Customer IDs are left out of the spans, as the customer is already recorded by the authentication middleware
+const fineIDKey = attribute.Key("library.fine.id")
+
+// tracedFineDAO records a span for every call to the wrapped FineDAO
+type tracedFineDAO struct {
+	fineDAO dao.FineDAO
+	backend string
+	tracer trace.Tracer
+}
+
+// InstrumentFineDAO wraps fineDAO so that each of its calls is recorded as a span named after the method, such as "FineDAO.UpdateAtomically"
+func InstrumentFineDAO(fineDAO dao.FineDAO, tracerProvider trace.TracerProvider, backend string) dao.FineDAO {
+	return &tracedFineDAO{
+		fineDAO: fineDAO,
+		backend: backend,
+		tracer: tracerProvider.Tracer(instrumentationName),
+	}
+}
+
+// start starts the span of a call to operation
+func (d *tracedFineDAO) start(ctx context.Context, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	attributes = append(attributes, backendKey.String(d.backend))
+
+	return d.tracer.Start(ctx, "FineDAO." + operation, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attributes...))
+}
+
+// fineIDOf returns the fine ID attribute of fine, which may not have one
+func fineIDOf(fine *models.Fine) attribute.KeyValue {
+	if fine == nil || fine.ID == nil {
+		return fineIDKey.String("")
+	}
+
+	return fineIDKey.String(*fine.ID)
+}
+
+func (d *tracedFineDAO) Create(ctx context.Context, newFine *models.Fine) error {
+	ctx, span := d.start(ctx, "Create", fineIDOf(newFine))
+	err := d.fineDAO.Create(ctx, newFine)
+	end(span, err)
+
+	return err
+}
+
+func (d *tracedFineDAO) Read(ctx context.Context, id string) (*models.Fine, error) {
+	ctx, span := d.start(ctx, "Read", fineIDKey.String(id))
+	fine, err := d.fineDAO.Read(ctx, id)
+	span.SetAttributes(attribute.Bool("library.fine.found", fine != nil))
+	end(span, err)
+
+	return fine, err
+}
+
+func (d *tracedFineDAO) ReadByCustomer(ctx context.Context, customerID string) ([]*models.Fine, error) {
+	ctx, span := d.start(ctx, "ReadByCustomer")
+	fines, err := d.fineDAO.ReadByCustomer(ctx, customerID)
+	span.SetAttributes(attribute.Int("library.fines.count", len(fines)))
+	end(span, err)
+
+	return fines, err
+}
+
+func (d *tracedFineDAO) UpdateAtomically(ctx context.Context, id string, modify func(currentFine *models.Fine) (*models.Fine, error)) (*models.Fine, error) {
+	ctx, span := d.start(ctx, "UpdateAtomically", fineIDKey.String(id))
+
+	var modifyErr error
+	fine, err := d.fineDAO.UpdateAtomically(ctx, id, func(currentFine *models.Fine) (*models.Fine, error) {
+		_, modifySpan := d.tracer.Start(ctx, "FineDAO.UpdateAtomically.modify", trace.WithAttributes(fineIDKey.String(id)))
+		defer modifySpan.End()
+
+		modifiedFine, err := modify(currentFine)
+		modifyErr = err
+		if err != nil {
+			modifySpan.RecordError(err)
+		}
+		return modifiedFine, err
+	})
+
+	// Errors returned by modify are the caller's own decisions, such as a fine that was already paid, so they do not mark the span as failed
+	if err != nil && modifyErr != nil && errors.Is(err, modifyErr) {
+		span.RecordError(err)
+		span.End()
+	} else {
+		end(span, err)
+	}
+
+	return fine, err
+}
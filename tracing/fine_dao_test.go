@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestInstrumentFineDAO(t *testing.T) {
+	tracerProvider, exporter := newTestTracerProvider()
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer daoFactory.Close()
+
+	fineDAO := InstrumentFineDAO(daoFactory.FineDAO(), tracerProvider, "inmemory")
+
+	newFine := func() *models.Fine {
+		return &models.Fine{
+			ID: utils.ToPtr("f1"),
+			CustomerID: utils.ToPtr("01"),
+			ISBN: utils.ToPtr("00001"),
+			DaysOverdue: utils.ToPtr(1),
+			Amount: utils.ToPtr(25),
+			State: utils.ToPtr("outstanding"),
+			TimeCreated: utils.ToPtr(time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)),
+			TimeUpdated: nil,
+		}
+	}
+
+	tests := []struct{
+		description string
+		call func() error
+		expectedSpanNames []string
+		expectedFineID interface{}
+		expectedStatus codes.Code
+		expectedEvents int
+	}{
+		{
+			description: "Successful Create",
+			call: func() error { return fineDAO.Create(context.Background(), newFine()) },
+			expectedSpanNames: []string{"FineDAO.Create"},
+			expectedFineID: "f1",
+			expectedStatus: codes.Unset,
+			expectedEvents: 0,
+		},
+		{
+			description: "Create of an existing fine fails",
+			call: func() error { return fineDAO.Create(context.Background(), newFine()) },
+			expectedSpanNames: []string{"FineDAO.Create"},
+			expectedFineID: "f1",
+			expectedStatus: codes.Error,
+			expectedEvents: 1,
+		},
+		{
+			description: "ReadByCustomer does not record the customer",
+			call: func() error { _, err := fineDAO.ReadByCustomer(context.Background(), "01"); return err },
+			expectedSpanNames: []string{"FineDAO.ReadByCustomer"},
+			expectedFineID: nil,
+			expectedStatus: codes.Unset,
+			expectedEvents: 0,
+		},
+		{
+			description: "UpdateAtomically rejected by modify does not fail the span",
+			call: func() error {
+				_, err := fineDAO.UpdateAtomically(context.Background(), "f1", func(currentFine *models.Fine) (*models.Fine, error) {
+					return nil, errors.New("rejected by the caller")
+				})
+				return err
+			},
+			expectedSpanNames: []string{"FineDAO.UpdateAtomically.modify", "FineDAO.UpdateAtomically"},
+			expectedFineID: "f1",
+			expectedStatus: codes.Unset,
+			expectedEvents: 1,
+		},
+		{
+			description: "UpdateAtomically of a missing fine is not a failure",
+			call: func() error {
+				_, err := fineDAO.UpdateAtomically(context.Background(), "f2", func(currentFine *models.Fine) (*models.Fine, error) {
+					return currentFine, nil
+				})
+				return err
+			},
+			expectedSpanNames: []string{"FineDAO.UpdateAtomically"},
+			expectedFineID: "f2",
+			expectedStatus: codes.Unset,
+			expectedEvents: 1,
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		exporter.Reset()
+
+		currentTestCase.call()
+
+		spans := exporter.GetSpans()
+		spanNames := make([]string, 0, len(spans))
+		for _, span := range spans {
+			spanNames = append(spanNames, span.Name)
+		}
+		if !assert.Equal(t, currentTestCase.expectedSpanNames, spanNames) {
+			continue
+		}
+
+		daoSpan := spans[len(spans) - 1]
+		assert.Equal(t, currentTestCase.expectedFineID, attributeValue(daoSpan.Attributes, fineIDKey))
+		assert.Equal(t, "inmemory", attributeValue(daoSpan.Attributes, backendKey))
+		assert.Equal(t, currentTestCase.expectedStatus, daoSpan.Status.Code)
+		assert.Len(t, daoSpan.Events, currentTestCase.expectedEvents)
+	}
+}
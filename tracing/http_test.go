@@ -0,0 +1,234 @@
+package tracing
+
+import (
+	"example/library_project/dao/inmemorydao"
+	"example/library_project/handlers"
+	"example/library_project/models"
+	"example/library_project/utils"
+
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestTracerProvider returns a tracer provider recording every span in exporter as soon as it ends
+func newTestTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), "library-api-test", 1), exporter
+}
+
+// attributeValue returns the value of the attribute named key in attributes, or nil if there is none
+func attributeValue(attributes []attribute.KeyValue, key attribute.Key) interface{} {
+	for _, currentAttribute := range attributes {
+		if currentAttribute.Key == key {
+			return currentAttribute.Value.AsInterface()
+		}
+	}
+
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	tracerProvider, exporter := newTestTracerProvider()
+	propagator := propagation.TraceContext{}
+
+	r := gin.New()
+	r.Use(Middleware(tracerProvider, propagator))
+	r.GET("/books/:isbn", func(c *gin.Context) {
+		// Handlers find the request's span in its context
+		assert.True(t, trace.SpanContextFromContext(c.Request.Context()).IsValid())
+
+		if c.Param("isbn") == "broken" {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNotFound)
+	})
+
+	upstreamTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	upstreamSpanID := "00f067aa0ba902b7"
+
+	tests := []struct{
+		description string
+		path string
+		traceparent string
+		expectedName string
+		expectedRoute string
+		expectedStatusCode int
+		expectedStatus codes.Code
+		expectedTraceID string
+		expectedParentSpanID string
+	}{
+		{
+			description: "Request without trace context starts a new trace",
+			path: "/books/00001",
+			traceparent: "",
+			expectedName: "GET /books/:isbn",
+			expectedRoute: "/books/:isbn",
+			expectedStatusCode: 404,
+			expectedStatus: codes.Unset,
+			expectedTraceID: "",
+			expectedParentSpanID: "",
+		},
+		{
+			description: "Request with trace context continues the trace",
+			path: "/books/00001",
+			traceparent: "00-" + upstreamTraceID + "-" + upstreamSpanID + "-01",
+			expectedName: "GET /books/:isbn",
+			expectedRoute: "/books/:isbn",
+			expectedStatusCode: 404,
+			expectedStatus: codes.Unset,
+			expectedTraceID: upstreamTraceID,
+			expectedParentSpanID: upstreamSpanID,
+		},
+		{
+			description: "Server error marks the span as failed",
+			path: "/books/broken",
+			traceparent: "",
+			expectedName: "GET /books/:isbn",
+			expectedRoute: "/books/:isbn",
+			expectedStatusCode: 500,
+			expectedStatus: codes.Error,
+			expectedTraceID: "",
+			expectedParentSpanID: "",
+		},
+		{
+			description: "Unmatched path",
+			path: "/nowhere",
+			traceparent: "",
+			expectedName: "GET",
+			expectedRoute: "",
+			expectedStatusCode: 404,
+			expectedStatus: codes.Unset,
+			expectedTraceID: "",
+			expectedParentSpanID: "",
+		},
+	}
+
+	for _, currentTestCase := range tests {
+		fmt.Println(currentTestCase.description)
+		t.Log(currentTestCase.description)
+
+		exporter.Reset()
+
+		req, err := http.NewRequest("GET", currentTestCase.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if currentTestCase.traceparent != "" {
+			req.Header.Set("traceparent", currentTestCase.traceparent)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, currentTestCase.expectedStatusCode, w.Code)
+
+		spans := exporter.GetSpans()
+		if !assert.Len(t, spans, 1) {
+			continue
+		}
+		span := spans[0]
+
+		assert.Equal(t, currentTestCase.expectedName, span.Name)
+		assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+		assert.Equal(t, currentTestCase.expectedStatus, span.Status.Code)
+		assert.Equal(t, "GET", attributeValue(span.Attributes, "http.request.method"))
+		assert.Equal(t, currentTestCase.expectedRoute, attributeValue(span.Attributes, "http.route"))
+		assert.Equal(t, currentTestCase.path, attributeValue(span.Attributes, "url.path"))
+		assert.Equal(t, int64(currentTestCase.expectedStatusCode), attributeValue(span.Attributes, "http.response.status_code"))
+
+		if currentTestCase.expectedTraceID != "" {
+			assert.Equal(t, currentTestCase.expectedTraceID, span.SpanContext.TraceID().String())
+			assert.Equal(t, currentTestCase.expectedParentSpanID, span.Parent.SpanID().String())
+			assert.True(t, span.Parent.IsRemote())
+		} else {
+			assert.False(t, span.Parent.IsValid())
+		}
+	}
+}
+
+func TestMiddleware_UpdateBookSpans(t *testing.T) {
+	tracerProvider, exporter := newTestTracerProvider()
+
+	daoFactory := inmemorydao.NewInMemoryDAOFactory()
+	if err := daoFactory.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer daoFactory.Close()
+
+	bookDAO := InstrumentBookDAO(daoFactory.BookDAO(), tracerProvider, "inmemory")
+
+	existingBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("available"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: nil,
+		TimeCreated: utils.ToPtr(time.Date(2023, 2, 1, 1, 30, 0, 0, time.UTC)),
+		TimeUpdated: nil,
+	}
+	if err := daoFactory.BookDAO().Create(context.Background(), existingBook); err != nil {
+		t.Fatal(err)
+	}
+
+	fixedTimeProvider := &utils.TestingDateTimeProvider{
+		ArbitraryTime: time.Date(2023, 2, 2, 1, 30, 0, 0, time.UTC),
+	}
+	h := handlers.NewBooksHandler(bookDAO, fixedTimeProvider)
+	h.AuthenticationDisabled = true
+
+	r := gin.New()
+	r.Use(Middleware(tracerProvider, propagation.TraceContext{}))
+	r.PATCH("/books/:isbn", h.UpdateBook)
+
+	incomingBook := &models.Book{
+		ISBN: utils.ToPtr("00001"),
+		State: utils.ToPtr("checked-out"),
+		OnHoldCustomerID: nil,
+		CheckedOutCustomerID: utils.ToPtr("02"),
+		TimeCreated: nil,
+		TimeUpdated: nil,
+	}
+	bookJSON, _ := json.Marshal(incomingBook)
+
+	req, err := http.NewRequest("PATCH", "/books/00001", bytes.NewBuffer(bookJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The spans end innermost first: the transition, the DAO call, then the request
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+
+	modifySpan, daoSpan, requestSpan := spans[0], spans[1], spans[2]
+	assert.Equal(t, "BookDAO.UpdateAtomicallyWithFines.modify", modifySpan.Name)
+	assert.Equal(t, "BookDAO.UpdateAtomicallyWithFines", daoSpan.Name)
+	assert.Equal(t, "PATCH /books/:isbn", requestSpan.Name)
+
+	assert.Equal(t, daoSpan.SpanContext.SpanID(), modifySpan.Parent.SpanID())
+	assert.Equal(t, requestSpan.SpanContext.SpanID(), daoSpan.Parent.SpanID())
+	assert.Equal(t, requestSpan.SpanContext.TraceID(), modifySpan.SpanContext.TraceID())
+	assert.Equal(t, "00001", attributeValue(daoSpan.Attributes, "library.isbn"))
+	assert.Equal(t, "inmemory", attributeValue(daoSpan.Attributes, "library.dao.backend"))
+}
@@ -0,0 +1,66 @@
+// Package tracing records OpenTelemetry spans for every HTTP request and BookDAO call, and exports them as configured
+package tracing
+
+import (
+	"example/library_project/config"
+
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// instrumentationName names the tracers of this package
+const instrumentationName = "example/library_project/tracing"
+
+// Setup installs the global tracer provider and the W3C trace context and baggage propagators.
+// With the "none" exporter only the propagators are installed, so that trace context received from clients still reaches the spans of downstream services.
+// The returned function flushes the spans not yet exported, and must be called before exiting
+func Setup(ctx context.Context, tracingConfig config.TracingConfig) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch tracingConfig.Exporter {
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		var options []otlptracehttp.Option
+		if tracingConfig.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(tracingConfig.OTLPEndpoint))
+		}
+		if tracingConfig.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case "none":
+		return func(ctx context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("unexpected tracing exporter %q", tracingConfig.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s span exporter: %w", tracingConfig.Exporter, err)
+	}
+
+	tracerProvider := NewTracerProvider(sdktrace.NewBatchSpanProcessor(exporter), tracingConfig.ServiceName, tracingConfig.SampleRatio)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+// NewTracerProvider returns a tracer provider passing the spans of this service to spanProcessor.
+// A fraction sampleRatio of new traces is recorded, while requests that are part of a trace follow the sampling decision of the caller
+func NewTracerProvider(spanProcessor sdktrace.SpanProcessor, serviceName string, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(spanProcessor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+}
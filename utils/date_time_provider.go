@@ -10,8 +10,9 @@ type DateTimeProvider interface {
 
 type ProductionDateTimeProvider struct {}
 
+// GetCurrentTime returns the current time in UTC at microsecond precision, which every storage solution can represent exactly
 func (p *ProductionDateTimeProvider) GetCurrentTime() (*time.Time) {
-	return ToPtr(time.Now())
+	return ToPtr(time.Now().UTC().Truncate(time.Microsecond))
 }
 
 type TestingDateTimeProvider struct {
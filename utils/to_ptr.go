@@ -4,7 +4,7 @@ import (
 	"time"
 )
 
-// ToPtr is a generic function that converts string and time.Time literals to pointers
-func ToPtr[T string|time.Time](v T) *T {
+// ToPtr is a generic function that converts string, time.Time and int literals to pointers
+func ToPtr[T string|time.Time|int](v T) *T {
     return &v
 }
\ No newline at end of file